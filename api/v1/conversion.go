@@ -0,0 +1,4 @@
+package v1
+
+// Hub marks v1 as the version every other NamespaceClass version converts through
+func (*NamespaceClass) Hub() {}
@@ -1,15 +1,60 @@
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// UpdatePolicy controls whether an existing resource is overwritten on subsequent reconciles
+type UpdatePolicy string
+
+const (
+	// UpdatePolicyAlways re-applies the resource on every reconcile
+	UpdatePolicyAlways UpdatePolicy = "Always"
+	// UpdatePolicyIfNotPresent creates the resource once and leaves later edits alone
+	UpdatePolicyIfNotPresent UpdatePolicy = "IfNotPresent"
+	// UpdatePolicyNever never writes the resource; it is only tracked once someone else creates it
+	UpdatePolicyNever UpdatePolicy = "Never"
+)
+
 // ResourceTemplate represents one item in NamespaceClass.spec.resources
 type ResourceTemplate struct {
 	// Template is the K8s resource object (any GVK)
 	// +kubebuilder:pruning:PreserveUnknownFields
 	Template runtime.RawExtension `json:"template"`
+	// UpdatePolicy controls whether the resource is re-applied once it exists.
+	// Accepted values: Always (default), IfNotPresent or Never.
+	// +optional
+	UpdatePolicy UpdatePolicy `json:"updatePolicy,omitempty"`
+	// AppendHash appends a hash of the data to the name of a ConfigMap or Secret and rewrites references to it in
+	// the pod templates of the same class, so content changes roll the dependent workloads.
+	// +optional
+	AppendHash bool `json:"appendHash,omitempty"`
+	// DeletionProtection marks the resource as holding data that must not be lost. The namespace webhook warns about,
+	// or denies, deleting a namespace that contains protected resources.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+	// OmitOwnerReference leaves the Namespace ownerReference off the resource, for objects that must not or cannot
+	// be garbage collected with the namespace, e.g. cluster-scoped companions. The operator holds a finalizer on
+	// the namespace and deletes such resources itself when the namespace is deleted.
+	// +optional
+	OmitOwnerReference bool `json:"omitOwnerReference,omitempty"`
+	// Condition is a CEL expression deciding whether the resource is rendered for a namespace. It can reference
+	// profile (the cluster profile key/values, e.g. profile.env == "prod") and namespace (name, labels and
+	// annotations). Empty means always.
+	// +optional
+	Condition string `json:"condition,omitempty"`
+	// IgnoreFields are JSON pointers (RFC 6901, e.g. /spec/replicas) removed from the template before it is applied,
+	// so fields owned by another actor such as an HPA or a mutating webhook are not fought over.
+	// +optional
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// GoTemplate executes every string of the template as a Go text/template before it is applied, with
+	// .Namespace.Name, .Namespace.Labels, .Namespace.Annotations and .Values (spec.values with the namespace's
+	// overrides), e.g. name: quota-{{ .Namespace.Labels.team }}. Off by default, so templates carrying {{ }} for
+	// other tools, e.g. alerting rules, are applied as written.
+	// +optional
+	GoTemplate bool `json:"goTemplate,omitempty"`
 }
 
 // DeletionPolicy controls behavior when a NamespaceClass is deleted
@@ -20,25 +65,317 @@ const (
 	DeletionPolicyOrphan  DeletionPolicy = "Orphan"
 )
 
+// ClassUpdatePolicy controls whether changes to a class spec are rolled out to its namespaces
+type ClassUpdatePolicy string
+
+const (
+	// ClassUpdatePolicyAlways rolls out every spec change
+	ClassUpdatePolicyAlways ClassUpdatePolicy = "Always"
+	// ClassUpdatePolicyFrozen keeps enforcing the revision applied when the class was frozen and holds back
+	// later spec changes until the policy is switched back
+	ClassUpdatePolicyFrozen ClassUpdatePolicy = "Frozen"
+)
+
+// ResourceBudget caps what a class may render into a single namespace
+type ResourceBudget struct {
+	// MaxObjects is the maximum number of resources rendered per namespace.
+	// +optional
+	MaxObjects *int32 `json:"maxObjects,omitempty"`
+	// MaxTotalSize is the maximum combined serialized size of the resources rendered per namespace, e.g. 512Ki.
+	// +optional
+	MaxTotalSize *resource.Quantity `json:"maxTotalSize,omitempty"`
+}
+
+// NamespaceMetadata are labels and annotations a class sets on the namespaces it is attached to. When they change,
+// the resources of the class wait for SettleTime, so admission controllers that read namespaces from a cache, like
+// Pod Security Admission, judge new pods by the updated labels.
+type NamespaceMetadata struct {
+	// Labels are set on the namespace, e.g. pod-security.kubernetes.io/enforce: restricted
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are set on the namespace
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// SettleTime is how long the resources of the class wait after the namespace metadata changed. Defaults to 5s.
+	// +optional
+	SettleTime *metav1.Duration `json:"settleTime,omitempty"`
+	// RemoveLast keeps the metadata on detach until the resources of the class are removed, the reverse of the
+	// order it was applied in. By default it is removed first.
+	// +optional
+	RemoveLast bool `json:"removeLast,omitempty"`
+}
+
+// AllowedNamespaces restricts which namespaces may attach a class.
+// A namespace is allowed when its name matches any of Names or its labels match Selector.
+type AllowedNamespaces struct {
+	// Names are namespace name patterns in shell glob syntax, e.g. team-a-*.
+	// +optional
+	Names []string `json:"names,omitempty"`
+	// Selector matches namespace labels. Prefer Names when tenants can edit their own namespace labels.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
 // NamespaceClassSpec defines the desired state of NamespaceClass
 type NamespaceClassSpec struct {
 	// Resources is a list of resource templates to be created in the target namespace.
 	Resources []ResourceTemplate `json:"resources,omitempty"`
+	// InheritFrom names parent classes whose resources and values this class builds on, e.g. a platform baseline
+	// that team classes add to. Parents are merged in order and may inherit in turn. A resource of this class
+	// replaces a parent resource with the same group, kind and name, and its values replace parent values with the
+	// same key. Resolved each time a namespace is synced; cycles fail the sync.
+	// +optional
+	InheritFrom []string `json:"inheritFrom,omitempty"`
 	// DeletionPolicy determines behavior when this NamespaceClass is deleted.
 	// Accepted values: Cascade (default) or Orphan.
 	// +optional
 	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// UpdatePolicy controls whether spec changes are rolled out. Accepted values: Always (default) or Frozen,
+	// which keeps drift-correcting namespaces against the revision applied when the class was frozen.
+	// +optional
+	UpdatePolicy ClassUpdatePolicy `json:"updatePolicy,omitempty"`
+	// ClaimApproval controls how NamespaceClassClaims for this class are bound: Manual (default) waits for an
+	// approver, Automatic binds claims from any namespace allowedNamespaces admits
+	// +optional
+	ClaimApproval ClaimApproval `json:"claimApproval,omitempty"`
+	// CommonLabels are added to every rendered resource. Labels set in a template take precedence.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to every rendered resource. Annotations set in a template take precedence.
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// NamespaceMetadata are labels and annotations set on the attached namespaces themselves, e.g. the Pod Security
+	// Admission level. They are applied before the resources of the class.
+	// +optional
+	NamespaceMetadata *NamespaceMetadata `json:"namespaceMetadata,omitempty"`
+	// ResourceBudget limits how many objects, and how much data, the class may render into each namespace.
+	// Exceeding it fails the reconcile instead of flooding the namespace.
+	// +optional
+	ResourceBudget *ResourceBudget `json:"resourceBudget,omitempty"`
+	// DriftCheckInterval re-applies the class to every attached namespace this often, reverting out-of-band changes
+	// sooner than the global resync. Values below 30s are raised to 30s.
+	// +optional
+	DriftCheckInterval *metav1.Duration `json:"driftCheckInterval,omitempty"`
+	// DeletionProtection blocks deletion of the class unless it carries the annotation
+	// namespaceclass.akuity.io/allow-deletion set to the class name. Enforced by the NamespaceClass webhook and
+	// by the finalizer, which keeps a protected class and its resources in place.
+	// +optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+	// AllowedNamespaces restricts which namespaces may attach this class. When unset, any namespace may.
+	// Enforced by the namespace webhook and the reconciler, which cleans up namespaces that are not allowed.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+	// NamespaceSelector attaches the class to every namespace whose labels match, e.g. env=prod, besides those
+	// labeled namespaceclass.akuity.io/name. Namespaces labeled by hand, by a policy or by a claim keep their class.
+	// When several classes select a namespace, the one with the highest priority wins, then the first by name.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Delegation binds ClusterRoles to subjects derived from each attached namespace, e.g. the group
+	// ns-admins-{{ .Namespace.Name }}, through a RoleBinding in that namespace
+	// +optional
+	Delegation []Delegation `json:"delegation,omitempty"`
+	// SkippableKinds lists the kinds a namespace may opt out of with the namespaceclass.akuity.io/skip-kinds
+	// annotation; "*" allows any kind. When empty the annotation is ignored.
+	// +optional
+	SkippableKinds []string `json:"skippableKinds,omitempty"`
+	// ForceOwnershipKinds lists kinds, e.g. ResourceQuota and LimitRange, whose fields the operator takes over from
+	// other field managers even when it runs with --field-conflicts=Respect, so tenants cannot override quota
+	// enforcement by owning the same fields.
+	// +optional
+	ForceOwnershipKinds []string `json:"forceOwnershipKinds,omitempty"`
+	// FallbackClass is applied instead when this class cannot be rendered for a namespace, e.g. because of a
+	// template error or an unavailable cluster profile, so the namespace degrades to a known-good baseline. A
+	// fallback's own fallback is followed in turn.
+	// +optional
+	FallbackClass string `json:"fallbackClass,omitempty"`
+	// Priority orders reconciles when requests queue up, e.g. after an operator restart: namespaces of classes with
+	// a higher priority, such as RBAC or NetworkPolicy baselines, are synced first. Defaults to 0; only honored
+	// when the operator runs with --class-priorities.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// Values are defaults that templates reference as $(values.<key>) in any string field, so image registries,
+	// proxy addresses and other org defaults are defined once instead of repeated in each template. A namespace
+	// overrides a value with the values.namespaceclass.akuity.io/<key> annotation; only declared keys can be
+	// overridden.
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
+	// Renderer names an external renderer, configured on the operator with --external-renderers, that renders this
+	// class instead of the operator's templating. It receives the class and the namespace and returns the objects
+	// to apply, which replace spec.resources.
+	// +optional
+	Renderer string `json:"renderer,omitempty"`
+	// Tests render the class for sample namespaces and check the result, so a class change that breaks them is
+	// rejected by the webhook and by kubectl nsclass validate before it reaches any namespace
+	// +optional
+	Tests []ClassTest `json:"tests,omitempty"`
+}
+
+// ClassTest renders the class for one sample namespace and checks what it renders
+type ClassTest struct {
+	// Name identifies the test in failures
+	Name string `json:"name"`
+	// Namespace is the metadata of the sample namespace; its name defaults to the test name
+	// +optional
+	Namespace ClassTestNamespace `json:"namespace,omitempty"`
+	// Profile is the cluster profile template conditions see
+	// +optional
+	Profile map[string]string `json:"profile,omitempty"`
+	// Expect lists the assertions on the rendered resources
+	Expect ClassTestExpectations `json:"expect"`
+}
+
+// ClassTestNamespace is the metadata of a sample namespace
+type ClassTestNamespace struct {
+	// +optional
+	Name string `json:"name,omitempty"`
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ClassTestExpectations are the assertions of a ClassTest; all of them must hold
+type ClassTestExpectations struct {
+	// ResourceCount is the exact number of resources rendered
+	// +optional
+	ResourceCount *int32 `json:"resourceCount,omitempty"`
+	// Contains lists resources that must be rendered
+	// +optional
+	Contains []ClassTestResource `json:"contains,omitempty"`
+	// Excludes lists resources that must not be rendered
+	// +optional
+	Excludes []ClassTestResource `json:"excludes,omitempty"`
+}
+
+// ClassTestResource matches rendered resources by kind and, optionally, name
+type ClassTestResource struct {
+	Kind string `json:"kind"`
+	// Name matches any name when empty
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// Delegation grants subjects derived from each attached namespace a ClusterRole within that namespace
+type Delegation struct {
+	// ClusterRole is bound in every attached namespace
+	ClusterRole string `json:"clusterRole"`
+	// Name of the RoleBinding; defaults to namespaceclass-<clusterRole>
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Subjects receive the role
+	Subjects []DelegationSubject `json:"subjects"`
+}
+
+// DelegationSubject is an RBAC subject whose name is a Go template over .Namespace (Name, Labels, Annotations)
+type DelegationSubject struct {
+	// Kind is User, Group or ServiceAccount
+	Kind string `json:"kind"`
+	// Name of the subject, e.g. ns-admins-{{ .Namespace.Name }}. Names starting with system: are rejected.
+	Name string `json:"name"`
+	// Namespace of a ServiceAccount subject, templated like Name; defaults to the attached namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// DeletionStatus reports the progress of a Cascade deletion
+type DeletionStatus struct {
+	// CleanedNamespaces is the number of namespaces whose resources have been removed
+	CleanedNamespaces int32 `json:"cleanedNamespaces"`
+	// Total is the number of namespaces that had to be cleaned up when the deletion started
+	Total int32 `json:"total"`
 }
 
 // NamespaceClassStatus defines the observed state of NamespaceClass
 type NamespaceClassStatus struct {
 	SyncedNamespaces []string    `json:"syncedNamespaces,omitempty"`
 	LastSyncTime     metav1.Time `json:"lastSyncTime,omitempty"`
+	// AttachedNamespaces is the number of namespaces the class is attached to
+	// +optional
+	AttachedNamespaces int32 `json:"attachedNamespaces,omitempty"`
+	// FailedNamespaces are the attached namespaces whose last sync failed
+	// +optional
+	FailedNamespaces []string `json:"failedNamespaces,omitempty"`
+	// FailedNamespaceCount is the number of FailedNamespaces
+	// +optional
+	FailedNamespaceCount int32 `json:"failedNamespaceCount,omitempty"`
+	// ObservedGeneration is the class generation the aggregated status and the Ready and Degraded conditions were
+	// last computed for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ExcludedNamespaces match a NamespaceClassPolicy rule assigning the class or the class's namespaceSelector but
+	// opted out of it with the namespaceclass.akuity.io/exclude annotation
+	// +optional
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// Deletion is set while a Cascade deletion waits for namespaces to clean up
+	// +optional
+	Deletion *DeletionStatus `json:"deletion,omitempty"`
+	// LastAppliedGeneration is the last generation every attached namespace synced successfully
+	// +optional
+	LastAppliedGeneration int64 `json:"lastAppliedGeneration,omitempty"`
+	// LastAppliedSpecHash is a hash of the spec at LastAppliedGeneration
+	// +optional
+	LastAppliedSpecHash string `json:"lastAppliedSpecHash,omitempty"`
+	// LastAppliedSpec is the gzip-compressed, base64-encoded JSON spec at LastAppliedGeneration, recorded when the
+	// operator runs with --record-last-applied-spec so a failing generation can be diffed against it
+	// +optional
+	LastAppliedSpec string `json:"lastAppliedSpec,omitempty"`
+	// FrozenGeneration is the generation namespaces are held at while spec.updatePolicy is Frozen
+	// +optional
+	FrozenGeneration int64 `json:"frozenGeneration,omitempty"`
+	// FrozenSpec is the gzip-compressed, base64-encoded JSON spec namespaces are held at while frozen
+	// +optional
+	FrozenSpec string `json:"frozenSpec,omitempty"`
+	// History records the rollouts of the most recent generations, oldest first
+	// +optional
+	History []RevisionHistory `json:"history,omitempty"`
+	// Conditions report the health of the class, Ready and Degraded, and cluster-wide findings about it, such as
+	// TemplatesCollide
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RevisionHistory records the rollout of one class generation to the attached namespaces
+type RevisionHistory struct {
+	// Revision is the class generation rolled out
+	Revision int64 `json:"revision"`
+	// StartedAt is when the operator first saw the generation
+	StartedAt metav1.Time `json:"startedAt"`
+	// CompletedAt is when every attached namespace had synced the generation; unset while it rolls out or if a
+	// later generation superseded it first
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// FailedNamespaces are the namespaces that failed to sync while the generation rolled out
+	// +optional
+	FailedNamespaces []string `json:"failedNamespaces,omitempty"`
+	// Summary totals the work of the rollout; set once it completed
+	// +optional
+	Summary *RolloutSummary `json:"summary,omitempty"`
+}
+
+// RolloutSummary totals the namespace syncs of one class generation. Syncs are counted in memory by the operator
+// leading the rollout, so a restart while it is under way leaves out the syncs before it.
+type RolloutSummary struct {
+	// NamespacesTouched is how many namespaces a sync wrote to, pruned from or failed in
+	NamespacesTouched int32 `json:"namespacesTouched"`
+	// Applied is how many resources were created
+	Applied int32 `json:"applied"`
+	// Changed is how many existing resources were rewritten
+	Changed int32 `json:"changed"`
+	// Pruned is how many resources were deleted
+	Pruned int32 `json:"pruned"`
+	// Failures is how many syncs failed
+	Failures int32 `json:"failures"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Attached",type=integer,JSONPath=`.status.attachedNamespaces`
+// +kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failedNamespaceCount`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // NamespaceClass is the Schema for the namespaceclasses API
 type NamespaceClass struct {
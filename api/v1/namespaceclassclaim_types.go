@@ -0,0 +1,84 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClaimApproval controls whether claims for a class need an approver
+type ClaimApproval string
+
+const (
+	// ClaimApprovalManual binds a claim only after an approver sets status.approval to Approved
+	ClaimApprovalManual ClaimApproval = "Manual"
+	// ClaimApprovalAutomatic binds every claim from a namespace the class allows
+	ClaimApprovalAutomatic ClaimApproval = "Automatic"
+)
+
+// ClaimDecision is an approver's decision on a claim
+type ClaimDecision string
+
+const (
+	ClaimApproved ClaimDecision = "Approved"
+	ClaimDenied   ClaimDecision = "Denied"
+)
+
+// ClaimPhase is the state of a claim
+type ClaimPhase string
+
+const (
+	// ClaimPending waits for approval
+	ClaimPending ClaimPhase = "Pending"
+	// ClaimBound has attached the class to the namespace
+	ClaimBound ClaimPhase = "Bound"
+	// ClaimRejected was denied or cannot be bound; Message says why
+	ClaimRejected ClaimPhase = "Rejected"
+)
+
+// NamespaceClassClaimSpec requests a class for the claim's namespace
+type NamespaceClassClaimSpec struct {
+	// Class is the NamespaceClass requested for the namespace
+	Class string `json:"class"`
+	// Values are copied to the namespace as claim.namespaceclass.akuity.io/<key> annotations, where template
+	// conditions can read them
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// NamespaceClassClaimStatus reports the approval and binding state of a claim
+type NamespaceClassClaimStatus struct {
+	// Approval is set by an approver through the status subresource, e.g. with kubectl nsclass approve
+	// +optional
+	Approval ClaimDecision `json:"approval,omitempty"`
+	// Phase is Pending, Bound or Rejected
+	// +optional
+	Phase ClaimPhase `json:"phase,omitempty"`
+	// Message explains the phase
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// NamespaceClassClaim lets namespace admins request a NamespaceClass for their namespace without permission to
+// edit the namespace itself
+type NamespaceClassClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceClassClaimSpec   `json:"spec,omitempty"`
+	Status NamespaceClassClaimStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceClassClaimList contains a list of NamespaceClassClaim
+type NamespaceClassClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceClassClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceClassClaim{}, &NamespaceClassClaimList{})
+}
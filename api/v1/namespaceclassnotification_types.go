@@ -0,0 +1,101 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationTriggerType names a condition that sends a notification
+type NotificationTriggerType string
+
+const (
+	// TriggerRolloutComplete fires once every namespace attached to a class has synced its current generation
+	TriggerRolloutComplete NotificationTriggerType = "RolloutComplete"
+	// TriggerNamespaceDegraded fires when a namespace has failed to sync for longer than the trigger's duration
+	TriggerNamespaceDegraded NotificationTriggerType = "NamespaceDegraded"
+	// TriggerDriftDetected fires when the controller reverted out-of-band changes to a managed resource
+	TriggerDriftDetected NotificationTriggerType = "DriftDetected"
+)
+
+// NotificationProviderType names where a notification is delivered
+type NotificationProviderType string
+
+const (
+	// ProviderWebhook POSTs the notification as JSON to a URL
+	ProviderWebhook NotificationProviderType = "Webhook"
+	// ProviderSlack posts the notification text to a Slack incoming webhook URL
+	ProviderSlack NotificationProviderType = "Slack"
+	// ProviderPagerDuty sends the notification to the PagerDuty Events API v2
+	ProviderPagerDuty NotificationProviderType = "PagerDuty"
+)
+
+// NotificationTrigger selects when to notify
+type NotificationTrigger struct {
+	// Type is RolloutComplete, NamespaceDegraded or DriftDetected
+	Type NotificationTriggerType `json:"type"`
+	// For is how long a namespace must stay degraded before NamespaceDegraded fires. Defaults to 10m.
+	// +optional
+	For *metav1.Duration `json:"for,omitempty"`
+}
+
+// NotificationProvider is a destination for notifications
+type NotificationProvider struct {
+	// Name identifies the provider in status and events
+	Name string `json:"name"`
+	// Type is Webhook, Slack or PagerDuty
+	Type NotificationProviderType `json:"type"`
+	// URL of the webhook or Slack incoming webhook. Prefer SecretRef for URLs that embed credentials.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// SecretRef names a Secret key in the operator namespace holding the URL (Webhook, Slack) or the routing key
+	// (PagerDuty)
+	// +optional
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+}
+
+// NamespaceClassNotificationSpec defines which class events are sent where
+type NamespaceClassNotificationSpec struct {
+	// Classes restricts the notifications to these NamespaceClasses. Empty means all.
+	// +optional
+	Classes []string `json:"classes,omitempty"`
+	// Triggers select the events to notify about
+	Triggers []NotificationTrigger `json:"triggers"`
+	// Providers receive every triggered notification
+	Providers []NotificationProvider `json:"providers"`
+}
+
+// NamespaceClassNotificationStatus records what was delivered so each event is sent once
+type NamespaceClassNotificationStatus struct {
+	// Delivered maps an event key (trigger/class/namespace) to the identity of the last event sent for it
+	// +optional
+	Delivered map[string]string `json:"delivered,omitempty"`
+	// LastError is the last delivery failure, cleared once every provider accepts a notification
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// NamespaceClassNotification sends notifications about NamespaceClass rollouts and namespace health
+type NamespaceClassNotification struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceClassNotificationSpec   `json:"spec,omitempty"`
+	Status NamespaceClassNotificationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceClassNotificationList contains a list of NamespaceClassNotification
+type NamespaceClassNotificationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceClassNotification `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceClassNotification{}, &NamespaceClassNotificationList{})
+}
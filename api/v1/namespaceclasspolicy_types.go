@@ -0,0 +1,60 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AssignmentRule maps matching namespaces to a class. A namespace matches when it satisfies every criterion set.
+type AssignmentRule struct {
+	// NameRegex is an RE2 expression the whole namespace name must match, e.g. team-.*-prod
+	// +optional
+	NameRegex string `json:"nameRegex,omitempty"`
+	// Selector matches namespace labels
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Class is the NamespaceClass assigned to matching namespaces
+	Class string `json:"class"`
+}
+
+// NamespaceClassPolicySpec defines how namespaces are assigned to classes
+type NamespaceClassPolicySpec struct {
+	// Rules are evaluated in order; the first matching rule assigns the class
+	Rules []AssignmentRule `json:"rules,omitempty"`
+	// DefaultClass is assigned to namespaces no rule matches. Empty leaves them alone.
+	// +optional
+	DefaultClass string `json:"defaultClass,omitempty"`
+}
+
+// NamespaceClassPolicyStatus reports what the policy assigned
+type NamespaceClassPolicyStatus struct {
+	// AssignedNamespaces is the number of namespaces whose class was set by this policy
+	// +optional
+	AssignedNamespaces int32 `json:"assignedNamespaces,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// NamespaceClassPolicy assigns NamespaceClasses to namespaces by name or labels, replacing per-namespace labeling
+// in provisioning scripts
+type NamespaceClassPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceClassPolicySpec   `json:"spec,omitempty"`
+	Status NamespaceClassPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceClassPolicyList contains a list of NamespaceClassPolicy
+type NamespaceClassPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceClassPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceClassPolicy{}, &NamespaceClassPolicyList{})
+}
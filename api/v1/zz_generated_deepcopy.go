@@ -22,6 +22,7 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -29,18 +30,206 @@ import (
 func (in *NamespaceClass) DeepCopyInto(out *NamespaceClass) {
 	*out = *in
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	if in.Spec.Resources != nil {
-		out.Spec.Resources = make([]ResourceTemplate, len(in.Spec.Resources))
-		for i := range in.Spec.Resources {
-			// deep copy RawExtension.Raw bytes if present
-			out.Spec.Resources[i].Template = runtime.RawExtension{}
-			if in.Spec.Resources[i].Template.Raw != nil {
-				out.Spec.Resources[i].Template.Raw = make([]byte, len(in.Spec.Resources[i].Template.Raw))
-				copy(out.Spec.Resources[i].Template.Raw, in.Spec.Resources[i].Template.Raw)
-			}
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy copies the receiver, creating a new NamespaceClass
+func (in *NamespaceClass) DeepCopy() *NamespaceClass {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the status, including the synced namespace list and deletion progress
+func (in *NamespaceClassStatus) DeepCopyInto(out *NamespaceClassStatus) {
+	*out = *in
+	if in.SyncedNamespaces != nil {
+		out.SyncedNamespaces = make([]string, len(in.SyncedNamespaces))
+		copy(out.SyncedNamespaces, in.SyncedNamespaces)
+	}
+	in.LastSyncTime.DeepCopyInto(&out.LastSyncTime)
+	if in.FailedNamespaces != nil {
+		out.FailedNamespaces = make([]string, len(in.FailedNamespaces))
+		copy(out.FailedNamespaces, in.FailedNamespaces)
+	}
+	if in.ExcludedNamespaces != nil {
+		out.ExcludedNamespaces = make([]string, len(in.ExcludedNamespaces))
+		copy(out.ExcludedNamespaces, in.ExcludedNamespaces)
+	}
+	if in.Deletion != nil {
+		out.Deletion = new(DeletionStatus)
+		*out.Deletion = *in.Deletion
+	}
+	if in.History != nil {
+		out.History = make([]RevisionHistory, len(in.History))
+		for i := range in.History {
+			in.History[i].DeepCopyInto(&out.History[i])
 		}
 	}
-	// Status is simple struct, shallow copy is fine
+	if in.Conditions != nil {
+		out.Conditions = make([]metav1.Condition, len(in.Conditions))
+		for i := range in.Conditions {
+			in.Conditions[i].DeepCopyInto(&out.Conditions[i])
+		}
+	}
+}
+
+// DeepCopyInto copies the entry, including its completion time, failed namespaces and summary
+func (in *RevisionHistory) DeepCopyInto(out *RevisionHistory) {
+	*out = *in
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	if in.CompletedAt != nil {
+		out.CompletedAt = in.CompletedAt.DeepCopy()
+	}
+	if in.FailedNamespaces != nil {
+		out.FailedNamespaces = make([]string, len(in.FailedNamespaces))
+		copy(out.FailedNamespaces, in.FailedNamespaces)
+	}
+	if in.Summary != nil {
+		summary := *in.Summary
+		out.Summary = &summary
+	}
+}
+
+// DeepCopyInto copies the spec, including templates and metadata maps
+func (in *NamespaceClassSpec) DeepCopyInto(out *NamespaceClassSpec) {
+	*out = *in
+	if in.Resources != nil {
+		out.Resources = make([]ResourceTemplate, len(in.Resources))
+		for i := range in.Resources {
+			in.Resources[i].DeepCopyInto(&out.Resources[i])
+		}
+	}
+	out.CommonLabels = copyStringMap(in.CommonLabels)
+	out.CommonAnnotations = copyStringMap(in.CommonAnnotations)
+	out.Values = copyStringMap(in.Values)
+	if in.NamespaceMetadata != nil {
+		out.NamespaceMetadata = new(NamespaceMetadata)
+		in.NamespaceMetadata.DeepCopyInto(out.NamespaceMetadata)
+	}
+	if in.ResourceBudget != nil {
+		out.ResourceBudget = new(ResourceBudget)
+		in.ResourceBudget.DeepCopyInto(out.ResourceBudget)
+	}
+	if in.DriftCheckInterval != nil {
+		out.DriftCheckInterval = new(metav1.Duration)
+		*out.DriftCheckInterval = *in.DriftCheckInterval
+	}
+	if in.AllowedNamespaces != nil {
+		out.AllowedNamespaces = new(AllowedNamespaces)
+		in.AllowedNamespaces.DeepCopyInto(out.AllowedNamespaces)
+	}
+	if in.NamespaceSelector != nil {
+		out.NamespaceSelector = in.NamespaceSelector.DeepCopy()
+	}
+	if in.SkippableKinds != nil {
+		out.SkippableKinds = make([]string, len(in.SkippableKinds))
+		copy(out.SkippableKinds, in.SkippableKinds)
+	}
+	if in.InheritFrom != nil {
+		out.InheritFrom = make([]string, len(in.InheritFrom))
+		copy(out.InheritFrom, in.InheritFrom)
+	}
+	if in.ForceOwnershipKinds != nil {
+		out.ForceOwnershipKinds = make([]string, len(in.ForceOwnershipKinds))
+		copy(out.ForceOwnershipKinds, in.ForceOwnershipKinds)
+	}
+	if in.Tests != nil {
+		out.Tests = make([]ClassTest, len(in.Tests))
+		for i := range in.Tests {
+			in.Tests[i].DeepCopyInto(&out.Tests[i])
+		}
+	}
+	if in.Delegation != nil {
+		out.Delegation = make([]Delegation, len(in.Delegation))
+		for i := range in.Delegation {
+			in.Delegation[i].DeepCopyInto(&out.Delegation[i])
+		}
+	}
+}
+
+// DeepCopyInto copies the delegation and its subjects
+func (in *Delegation) DeepCopyInto(out *Delegation) {
+	*out = *in
+	if in.Subjects != nil {
+		out.Subjects = make([]DelegationSubject, len(in.Subjects))
+		copy(out.Subjects, in.Subjects)
+	}
+}
+
+// DeepCopyInto copies the sample namespace, profile and expectations
+func (in *ClassTest) DeepCopyInto(out *ClassTest) {
+	*out = *in
+	out.Namespace.Labels = copyStringMap(in.Namespace.Labels)
+	out.Namespace.Annotations = copyStringMap(in.Namespace.Annotations)
+	out.Profile = copyStringMap(in.Profile)
+	if in.Expect.ResourceCount != nil {
+		out.Expect.ResourceCount = new(int32)
+		*out.Expect.ResourceCount = *in.Expect.ResourceCount
+	}
+	if in.Expect.Contains != nil {
+		out.Expect.Contains = make([]ClassTestResource, len(in.Expect.Contains))
+		copy(out.Expect.Contains, in.Expect.Contains)
+	}
+	if in.Expect.Excludes != nil {
+		out.Expect.Excludes = make([]ClassTestResource, len(in.Expect.Excludes))
+		copy(out.Expect.Excludes, in.Expect.Excludes)
+	}
+}
+
+// DeepCopyInto copies the labels, annotations and settle time
+func (in *NamespaceMetadata) DeepCopyInto(out *NamespaceMetadata) {
+	*out = *in
+	out.Labels = copyStringMap(in.Labels)
+	out.Annotations = copyStringMap(in.Annotations)
+	if in.SettleTime != nil {
+		out.SettleTime = new(metav1.Duration)
+		*out.SettleTime = *in.SettleTime
+	}
+}
+
+// DeepCopyInto copies the name patterns and selector
+func (in *AllowedNamespaces) DeepCopyInto(out *AllowedNamespaces) {
+	*out = *in
+	if in.Names != nil {
+		out.Names = make([]string, len(in.Names))
+		copy(out.Names, in.Names)
+	}
+	if in.Selector != nil {
+		out.Selector = in.Selector.DeepCopy()
+	}
+}
+
+// DeepCopyInto copies the budget limits
+func (in *ResourceBudget) DeepCopyInto(out *ResourceBudget) {
+	*out = *in
+	if in.MaxObjects != nil {
+		out.MaxObjects = new(int32)
+		*out.MaxObjects = *in.MaxObjects
+	}
+	if in.MaxTotalSize != nil {
+		q := in.MaxTotalSize.DeepCopy()
+		out.MaxTotalSize = &q
+	}
+}
+
+// DeepCopyInto copies a resource template, including the raw template bytes
+func (in *ResourceTemplate) DeepCopyInto(out *ResourceTemplate) {
+	*out = *in
+	// deep copy RawExtension.Raw bytes if present
+	out.Template = runtime.RawExtension{}
+	if in.Template.Raw != nil {
+		out.Template.Raw = make([]byte, len(in.Template.Raw))
+		copy(out.Template.Raw, in.Template.Raw)
+	}
+	if in.IgnoreFields != nil {
+		out.IgnoreFields = make([]string, len(in.IgnoreFields))
+		copy(out.IgnoreFields, in.IgnoreFields)
+	}
 }
 
 // DeepCopyObject implements runtime.Object
@@ -74,3 +263,205 @@ func (in *NamespaceClassList) DeepCopyObject() runtime.Object {
 	in.DeepCopyInto(out)
 	return out
 }
+
+func copyStringMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+// DeepCopyInto copies the notification, including its triggers, providers and delivery record
+func (in *NamespaceClassNotification) DeepCopyInto(out *NamespaceClassNotification) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status.Delivered = copyStringMap(in.Status.Delivered)
+}
+
+// DeepCopy copies the receiver, creating a new NamespaceClassNotification
+func (in *NamespaceClassNotification) DeepCopy() *NamespaceClassNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *NamespaceClassNotification) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the class filter, triggers and providers
+func (in *NamespaceClassNotificationSpec) DeepCopyInto(out *NamespaceClassNotificationSpec) {
+	*out = *in
+	if in.Classes != nil {
+		out.Classes = make([]string, len(in.Classes))
+		copy(out.Classes, in.Classes)
+	}
+	if in.Triggers != nil {
+		out.Triggers = make([]NotificationTrigger, len(in.Triggers))
+		for i := range in.Triggers {
+			out.Triggers[i] = in.Triggers[i]
+			if in.Triggers[i].For != nil {
+				out.Triggers[i].For = new(metav1.Duration)
+				*out.Triggers[i].For = *in.Triggers[i].For
+			}
+		}
+	}
+	if in.Providers != nil {
+		out.Providers = make([]NotificationProvider, len(in.Providers))
+		for i := range in.Providers {
+			out.Providers[i] = in.Providers[i]
+			if in.Providers[i].SecretRef != nil {
+				out.Providers[i].SecretRef = in.Providers[i].SecretRef.DeepCopy()
+			}
+		}
+	}
+}
+
+// DeepCopyInto for list
+func (in *NamespaceClassNotificationList) DeepCopyInto(out *NamespaceClassNotificationList) {
+	*out = *in
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]NamespaceClassNotification, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopyObject implements runtime.Object for the list
+func (in *NamespaceClassNotificationList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassNotificationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the policy, including its rules
+func (in *NamespaceClassPolicy) DeepCopyInto(out *NamespaceClassPolicy) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy copies the receiver, creating a new NamespaceClassPolicy
+func (in *NamespaceClassPolicy) DeepCopy() *NamespaceClassPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *NamespaceClassPolicy) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the rules and their selectors
+func (in *NamespaceClassPolicySpec) DeepCopyInto(out *NamespaceClassPolicySpec) {
+	*out = *in
+	if in.Rules != nil {
+		out.Rules = make([]AssignmentRule, len(in.Rules))
+		for i := range in.Rules {
+			out.Rules[i] = in.Rules[i]
+			if in.Rules[i].Selector != nil {
+				out.Rules[i].Selector = in.Rules[i].Selector.DeepCopy()
+			}
+		}
+	}
+}
+
+// DeepCopyInto for list
+func (in *NamespaceClassPolicyList) DeepCopyInto(out *NamespaceClassPolicyList) {
+	*out = *in
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]NamespaceClassPolicy, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopyObject implements runtime.Object for the list
+func (in *NamespaceClassPolicyList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the claim, including its values
+func (in *NamespaceClassClaim) DeepCopyInto(out *NamespaceClassClaim) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec.Values = copyStringMap(in.Spec.Values)
+}
+
+// DeepCopy copies the receiver, creating a new NamespaceClassClaim
+func (in *NamespaceClassClaim) DeepCopy() *NamespaceClassClaim {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *NamespaceClassClaim) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto for list
+func (in *NamespaceClassClaimList) DeepCopyInto(out *NamespaceClassClaimList) {
+	*out = *in
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]NamespaceClassClaim, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopyObject implements runtime.Object for the list
+func (in *NamespaceClassClaimList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceClassClaimList)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,88 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// V1SpecAnnotation holds the full v1 spec of an object served as v1beta1, so fields v1beta1 cannot express
+// survive a read-modify-write through the older version
+const V1SpecAnnotation = "namespaceclass.akuity.io/v1-spec"
+
+// ConvertTo converts this NamespaceClass to the v1 hub
+func (src *NamespaceClass) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.NamespaceClass)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	// Restore the v1-only fields first; what v1beta1 can express always wins
+	if raw, ok := src.Annotations[V1SpecAnnotation]; ok {
+		if err := json.Unmarshal([]byte(raw), &dst.Spec); err != nil {
+			return fmt.Errorf("failed to decode %s annotation: %w", V1SpecAnnotation, err)
+		}
+		delete(dst.Annotations, V1SpecAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	// Per-template options are kept only while the template list keeps its length, since items have no identity
+	if len(dst.Spec.Resources) != len(src.Spec.Resources) {
+		dst.Spec.Resources = make([]v1.ResourceTemplate, len(src.Spec.Resources))
+	}
+	for i, tmpl := range src.Spec.Resources {
+		dst.Spec.Resources[i].Template = *tmpl.Template.DeepCopy()
+	}
+	dst.Spec.DeletionPolicy = v1.DeletionPolicy(src.Spec.DeletionPolicy)
+
+	dst.Status.SyncedNamespaces = src.Status.SyncedNamespaces
+	dst.Status.LastSyncTime = src.Status.LastSyncTime
+	return nil
+}
+
+// ConvertFrom converts from the v1 hub to this version
+func (dst *NamespaceClass) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.NamespaceClass)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	dst.Spec.Resources = make([]ResourceTemplate, len(src.Spec.Resources))
+	for i, tmpl := range src.Spec.Resources {
+		dst.Spec.Resources[i].Template = *tmpl.Template.DeepCopy()
+	}
+	dst.Spec.DeletionPolicy = DeletionPolicy(src.Spec.DeletionPolicy)
+
+	if hasV1OnlyFields(&src.Spec) {
+		raw, err := json.Marshal(src.Spec)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[V1SpecAnnotation] = string(raw)
+	}
+
+	dst.Status.SyncedNamespaces = src.Status.SyncedNamespaces
+	dst.Status.LastSyncTime = src.Status.LastSyncTime
+	return nil
+}
+
+// hasV1OnlyFields reports whether spec uses anything v1beta1 cannot represent
+func hasV1OnlyFields(spec *v1.NamespaceClassSpec) bool {
+	if len(spec.CommonLabels) > 0 || len(spec.CommonAnnotations) > 0 || spec.NamespaceMetadata != nil || spec.ResourceBudget != nil || spec.AllowedNamespaces != nil ||
+		spec.DriftCheckInterval != nil || spec.DeletionProtection || spec.UpdatePolicy != "" || spec.ClaimApproval != "" || len(spec.Delegation) > 0 ||
+		len(spec.SkippableKinds) > 0 || spec.FallbackClass != "" || spec.Priority != 0 || len(spec.Values) > 0 ||
+		len(spec.ForceOwnershipKinds) > 0 || len(spec.Tests) > 0 || spec.Renderer != "" || len(spec.InheritFrom) > 0 ||
+		spec.NamespaceSelector != nil {
+		return true
+	}
+	for _, tmpl := range spec.Resources {
+		if tmpl.UpdatePolicy != "" || tmpl.AppendHash || len(tmpl.IgnoreFields) > 0 || tmpl.Condition != "" || tmpl.DeletionProtection ||
+			tmpl.OmitOwnerReference || tmpl.GoTemplate {
+			return true
+		}
+	}
+	return false
+}
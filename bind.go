@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Endpoints accepted by --localhost-only
+const (
+	endpointProbes  = "probes"
+	endpointMetrics = "metrics"
+	endpointPprof   = "pprof"
+)
+
+// bindOptions rewrites the listen addresses of the operator's serving endpoints
+type bindOptions struct {
+	// localhostOnly holds the endpoints restricted to the loopback interface
+	localhostOnly map[string]bool
+	// loopback is the loopback host of the selected IP family
+	loopback string
+}
+
+// newBindOptions parses --localhost-only and --ip-family
+func newBindOptions(localhostOnly, ipFamily string) (*bindOptions, error) {
+	opts := &bindOptions{localhostOnly: map[string]bool{}}
+	for _, endpoint := range strings.Split(localhostOnly, ",") {
+		switch endpoint = strings.TrimSpace(endpoint); endpoint {
+		case "":
+		case endpointProbes, endpointMetrics, endpointPprof:
+			opts.localhostOnly[endpoint] = true
+		default:
+			return nil, fmt.Errorf("unknown endpoint %q in --localhost-only, expected %s, %s or %s",
+				endpoint, endpointProbes, endpointMetrics, endpointPprof)
+		}
+	}
+	switch ipFamily {
+	case "ipv4":
+		opts.loopback = "127.0.0.1"
+	case "ipv6":
+		opts.loopback = "::1"
+	case "", "auto":
+		opts.loopback = detectLoopback()
+	default:
+		return nil, fmt.Errorf("unknown --ip-family %q, expected auto, ipv4 or ipv6", ipFamily)
+	}
+	return opts, nil
+}
+
+// address validates addr as host:port and, for localhost-only endpoints, moves a wildcard host to the loopback
+// address. Empty addresses and "0" disable an endpoint and are returned unchanged.
+func (o *bindOptions) address(endpoint, addr string) (string, error) {
+	if addr == "" || addr == "0" {
+		return addr, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s bind address %q: %w", endpoint, addr, err)
+	}
+	ip := net.ParseIP(host)
+	if host != "" && host != "localhost" && ip == nil {
+		return "", fmt.Errorf("invalid %s bind address %q: host must be an IP address or localhost", endpoint, addr)
+	}
+	if !o.localhostOnly[endpoint] {
+		return addr, nil
+	}
+	switch {
+	case host == "" || host == "localhost" || ip.IsUnspecified():
+		// "localhost" may resolve to both families, and Go only listens on one of them
+		return net.JoinHostPort(o.loopback, port), nil
+	case ip.IsLoopback():
+		return addr, nil
+	default:
+		return "", fmt.Errorf("%s bind address %q is not a loopback address, but --localhost-only includes %s", endpoint, addr, endpoint)
+	}
+}
+
+// detectLoopback returns the IPv4 loopback address unless the node has only IPv6 loopback configured
+func detectLoopback() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+	v6 := false
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsLoopback() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return "127.0.0.1"
+		}
+		v6 = true
+	}
+	if v6 {
+		return "::1"
+	}
+	return "127.0.0.1"
+}
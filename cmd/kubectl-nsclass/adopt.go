@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runAdopt labels an existing resource as managed by the namespace's class and records it in the namespace inventory,
+// so that detaching the class cleans it up like any rendered resource
+func runAdopt(args []string) error {
+	var opts kubeOptions
+	var dryRun bool
+	fs := flag.NewFlagSet("adopt", flag.ExitOnError)
+	opts.bind(fs)
+	fs.BoolVar(&dryRun, "dry-run", false, "Print what would be adopted without changing anything.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: kubectl nsclass adopt <type>/<name> [-n namespace] [--dry-run]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one <type>/<name> argument")
+	}
+	resourceType, name, ok := strings.Cut(fs.Arg(0), "/")
+	if !ok || resourceType == "" || name == "" {
+		return fmt.Errorf("resource must be given as <type>/<name>, got %q", fs.Arg(0))
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	namespace, err := opts.targetNamespace()
+	if err != nil {
+		return err
+	}
+	gvk, err := resolveKind(&opts, resourceType)
+	if err != nil {
+		return err
+	}
+
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	class := ns.Labels[controllers.NamespaceClassLabel]
+	if class == "" {
+		return fmt.Errorf("namespace %s has no %s label; attach a class before adopting resources", namespace, controllers.NamespaceClassLabel)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		return fmt.Errorf("failed to get %s %s/%s: %w", gvk.Kind, namespace, name, err)
+	}
+
+	// Namespaces synced by an operator with --inventory-store=configmap keep the inventory in a ConfigMap
+	var cm *corev1.ConfigMap
+	if ns.Annotations[engine.InventoryAnnotation] == "" {
+		if cm, err = engine.InventoryConfigMap(ctx, c, namespace); err != nil {
+			return err
+		}
+	}
+	inventory, err := engine.NamespaceInventory(&ns)
+	if cm != nil {
+		inventory, err = engine.ConfigMapInventory(cm)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read inventory of namespace %s: %w", namespace, err)
+	}
+	now := metav1.Now()
+	item := engine.InventoryItem{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+		Adopted:    true,
+		Created:    &now,
+	}
+	for _, existing := range inventory {
+		if existing.Key() == item.Key() {
+			return fmt.Errorf("%s %s/%s is already in the inventory of class %s", item.Kind, namespace, name, class)
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("%s %s/%s would be adopted by class %s (dry run)\n", item.Kind, namespace, name, class)
+		return nil
+	}
+
+	objPatch := client.MergeFrom(obj.DeepCopy())
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[engine.ManagedByLabel] = engine.ManagerName
+	labels[engine.SourceClassLabel] = class
+	obj.SetLabels(labels)
+	if err := c.Patch(ctx, obj, objPatch); err != nil {
+		return fmt.Errorf("failed to label %s %s/%s: %w", item.Kind, namespace, name, err)
+	}
+
+	if cm != nil {
+		err = updateConfigMapInventory(ctx, c, cm, append(inventory, item))
+	} else {
+		err = updateAnnotationInventory(ctx, c, &ns, class, append(inventory, item))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update inventory of namespace %s: %w", namespace, err)
+	}
+
+	fmt.Printf("%s %s/%s adopted by class %s\n", item.Kind, namespace, name, class)
+	return nil
+}
+
+// updateConfigMapInventory writes items to the inventory ConfigMap cm. Update fails on a concurrent controller write
+// to the inventory instead of losing it.
+func updateConfigMapInventory(ctx context.Context, c client.Client, cm *corev1.ConfigMap, items []engine.InventoryItem) error {
+	if err := engine.SetConfigMapInventory(cm, items); err != nil {
+		return err
+	}
+	return c.Update(ctx, cm)
+}
+
+// updateAnnotationInventory writes items to the inventory annotation of ns
+func updateAnnotationInventory(ctx context.Context, c client.Client, ns *corev1.Namespace, class string, items []engine.InventoryItem) error {
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	// Optimistic lock so a concurrent controller write to the inventory is not lost
+	nsPatch := client.MergeFromWithOptions(ns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string)
+	}
+	ns.Annotations[engine.InventoryAnnotation] = string(raw)
+	ns.Annotations[engine.AttachedClassAnnotation] = class
+	return c.Patch(ctx, ns, nsPatch)
+}
+
+// resolveKind maps a kubectl-style resource type (e.g. "cm", "deployments.apps", "networkpolicy") to a namespaced kind
+func resolveKind(opts *kubeOptions, resourceType string) (schema.GroupVersionKind, error) {
+	mapper, err := opts.restMapper()
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+
+	fullySpecified, groupResource := schema.ParseResourceArg(resourceType)
+	gvr := groupResource.WithVersion("")
+	if fullySpecified != nil {
+		gvr = *fullySpecified
+	}
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("unknown resource type %q: %w", resourceType, err)
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return schema.GroupVersionKind{}, fmt.Errorf("%s is cluster-scoped; only namespaced resources can be adopted", gvk.Kind)
+	}
+	return gvk, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// runBaseline compares the spec of a class with the last spec every attached namespace synced
+func runBaseline(args []string) error {
+	var opts kubeOptions
+	var showDiff bool
+	fs := flag.NewFlagSet("baseline", flag.ExitOnError)
+	opts.bind(fs)
+	fs.BoolVar(&showDiff, "diff", false, "Print a unified diff of the spec instead of the changed resources.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl nsclass baseline <class> [--diff]")
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	var nsClass v1.NamespaceClass
+	if err := c.Get(ctx, types.NamespacedName{Name: fs.Arg(0)}, &nsClass); err != nil {
+		return fmt.Errorf("failed to get class %s: %w", fs.Arg(0), err)
+	}
+
+	status := nsClass.Status
+	if status.LastAppliedGeneration == 0 {
+		fmt.Printf("NamespaceClass %s has no fully rolled out generation yet (current generation %d)\n", nsClass.Name, nsClass.Generation)
+		return nil
+	}
+	hash, err := controllers.SpecHash(&nsClass.Spec)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Last applied generation: %d (spec %s)\n", status.LastAppliedGeneration, status.LastAppliedSpecHash)
+	fmt.Printf("Current generation:      %d (spec %s)\n", nsClass.Generation, hash)
+	if hash == status.LastAppliedSpecHash {
+		fmt.Println("The current spec matches the last applied spec.")
+		return nil
+	}
+	if status.LastAppliedSpec == "" {
+		fmt.Println("The spec changed; start the operator with --record-last-applied-spec to see how.")
+		return nil
+	}
+
+	base, err := controllers.DecodeSpec(status.LastAppliedSpec)
+	if err != nil {
+		return err
+	}
+	if !showDiff {
+		changes, err := controllers.SpecChanges(base, &nsClass.Spec)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		return nil
+	}
+
+	before, err := yaml.Marshal(base)
+	if err != nil {
+		return err
+	}
+	after, err := yaml.Marshal(nsClass.Spec)
+	if err != nil {
+		return err
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: fmt.Sprintf("generation %d", status.LastAppliedGeneration),
+		ToFile:   fmt.Sprintf("generation %d", nsClass.Generation),
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	return nil
+}
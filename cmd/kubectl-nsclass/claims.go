@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runDecide records an approver's decision on a NamespaceClassClaim through its status subresource, which
+// namespace admins who create claims are not expected to be able to write
+func runDecide(cmd string, decision v1.ClaimDecision, args []string) error {
+	var opts kubeOptions
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	opts.bind(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl nsclass %s <claim> [-n namespace]\n", cmd)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one claim name")
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	namespace, err := opts.targetNamespace()
+	if err != nil {
+		return err
+	}
+
+	var claim v1.NamespaceClassClaim
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: fs.Arg(0)}, &claim); err != nil {
+		return fmt.Errorf("failed to get claim: %w", err)
+	}
+	if claim.Status.Approval == decision {
+		fmt.Printf("namespaceclassclaim %s/%s already %s\n", namespace, claim.Name, decision)
+		return nil
+	}
+	patch := client.MergeFrom(claim.DeepCopy())
+	claim.Status.Approval = decision
+	if err := c.Status().Patch(ctx, &claim, patch); err != nil {
+		return fmt.Errorf("failed to %s claim: %w", cmd, err)
+	}
+	fmt.Printf("namespaceclassclaim %s/%s %s (class %s)\n", namespace, claim.Name, decision, claim.Spec.Class)
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// detachPlan lists what detaching the class does to one namespace
+type detachPlan struct {
+	namespace string
+	// policy is the deletion policy annotation of the namespace before the detach, "" when it has none
+	policy    string
+	resources []string
+	protected []string
+	// skipped explains why the namespace is left attached, "" when it is detached
+	skipped string
+}
+
+// runDetach removes a class from namespaces in stages, deleting or orphaning its resources, after printing the
+// per-namespace plan
+func runDetach(args []string) error {
+	var opts kubeOptions
+	var selector string
+	var orphan, cascade, force, dryRun bool
+	var batchSize int
+	var timeout time.Duration
+	fs := flag.NewFlagSet("detach", flag.ExitOnError)
+	opts.bind(fs)
+	fs.StringVar(&selector, "namespaces", "", "Label selector restricting which namespaces are detached.")
+	fs.BoolVar(&orphan, "orphan", false, "Keep the class resources in place and stop managing them.")
+	fs.BoolVar(&cascade, "cascade", false, "Delete the class resources.")
+	fs.BoolVar(&force, "force", false, "With --cascade, also delete resources whose templates set deletionProtection.")
+	fs.IntVar(&batchSize, "batch-size", 5, "Number of namespaces detached per stage.")
+	fs.DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for a stage to be cleaned up before aborting.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the plan without detaching any namespace.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl nsclass detach <class> --orphan|--cascade [--namespaces <selector>]")
+	}
+	if orphan == cascade {
+		return fmt.Errorf("exactly one of --orphan and --cascade is required")
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return fmt.Errorf("invalid --namespaces selector: %w", err)
+	}
+	class := fs.Arg(0)
+	policy := v1.DeletionPolicyCascade
+	if orphan {
+		policy = v1.DeletionPolicyOrphan
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList, client.MatchingLabels{controllers.NamespaceClassLabel: class}); err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	var plans []detachPlan
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		if !sel.Matches(labels.Set(ns.Labels)) || !ns.DeletionTimestamp.IsZero() {
+			continue
+		}
+		plan, err := planDetach(ctx, c, ns)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].namespace < plans[j].namespace })
+	if len(plans) == 0 {
+		fmt.Printf("No namespaces attached to class %s match the selector\n", class)
+		return nil
+	}
+
+	var detach []detachPlan
+	var protected int
+	for _, p := range plans {
+		if p.skipped != "" {
+			fmt.Printf("namespace %s: skipped, %s\n", p.namespace, p.skipped)
+			continue
+		}
+		fmt.Printf("namespace %s:\n", p.namespace)
+		prefix := "-"
+		if orphan {
+			prefix = "="
+		}
+		printPlanLines(prefix, p.resources)
+		printPlanLines("!", p.protected)
+		if cascade {
+			protected += len(p.protected)
+		}
+		detach = append(detach, p)
+	}
+	if protected > 0 && !force {
+		return fmt.Errorf("%d resources marked ! are protected by deletionProtection; use --orphan, or --force to delete them", protected)
+	}
+	if dryRun || len(detach) == 0 {
+		return nil
+	}
+
+	for start := 0; start < len(detach); start += batchSize {
+		end := min(start+batchSize, len(detach))
+		batch := detach[start:end]
+		for _, p := range batch {
+			if err := removeClass(ctx, c, p.namespace, class, policy); err != nil {
+				return err
+			}
+		}
+		for _, p := range batch {
+			if err := waitForDetach(ctx, c, p.namespace, timeout); err != nil {
+				return fmt.Errorf("namespace %s was not cleaned up: %w", p.namespace, err)
+			}
+			if err := restorePolicy(ctx, c, p.namespace, policy, p.policy); err != nil {
+				return err
+			}
+		}
+		names := make([]string, len(batch))
+		for i, p := range batch {
+			names[i] = p.namespace
+		}
+		fmt.Printf("[%d/%d] detached %s\n", end, len(detach), names)
+	}
+	return nil
+}
+
+// planDetach lists the resources detaching ns touches, or why ns is skipped: a policy, selector or claim that attached
+// the class would attach it again right away
+func planDetach(ctx context.Context, c client.Reader, ns *corev1.Namespace) (detachPlan, error) {
+	plan := detachPlan{namespace: ns.Name, policy: ns.Annotations[controllers.DeletionPolicyAnnotation]}
+	switch {
+	case ns.Annotations[controllers.AssignedByAnnotation] != "":
+		plan.skipped = fmt.Sprintf("assigned by NamespaceClassPolicy %s; exclude the class with the %s annotation instead",
+			ns.Annotations[controllers.AssignedByAnnotation], controllers.ExcludeAnnotation)
+		return plan, nil
+	case ns.Annotations[controllers.SelectedByAnnotation] != "" && ns.Annotations[controllers.SelectedByAnnotation] == ns.Labels[controllers.NamespaceClassLabel]:
+		plan.skipped = fmt.Sprintf("selected by the namespaceSelector of NamespaceClass %s; change the namespace labels or exclude the class with the %s annotation instead",
+			ns.Annotations[controllers.SelectedByAnnotation], controllers.ExcludeAnnotation)
+		return plan, nil
+	case ns.Annotations[controllers.ClaimedByAnnotation] != "":
+		plan.skipped = fmt.Sprintf("bound by NamespaceClassClaim %s; delete the claim instead", ns.Annotations[controllers.ClaimedByAnnotation])
+		return plan, nil
+	}
+	inventory, err := engine.ReadInventory(ctx, c, ns)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read inventory of namespace %s: %w", ns.Name, err)
+	}
+	for _, item := range inventory {
+		name := item.Kind + "/" + item.Name
+		if item.Protected && !controllers.NamespaceDeletionAllowed(ns) {
+			plan.protected = append(plan.protected, name)
+			continue
+		}
+		plan.resources = append(plan.resources, name)
+	}
+	sort.Strings(plan.resources)
+	sort.Strings(plan.protected)
+	return plan, nil
+}
+
+// removeClass drops the class label of a namespace and sets the deletion policy the controller cleans up with, in
+// one patch that fails if the namespace changed since it was read
+func removeClass(ctx context.Context, c client.Client, namespace, class string, policy v1.DeletionPolicy) error {
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	if ns.Labels[controllers.NamespaceClassLabel] != class {
+		return fmt.Errorf("namespace %s is no longer attached to class %s", namespace, class)
+	}
+	patch := client.MergeFromWithOptions(ns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	delete(ns.Labels, controllers.NamespaceClassLabel)
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[controllers.DeletionPolicyAnnotation] = string(policy)
+	if err := c.Patch(ctx, &ns, patch); err != nil {
+		return fmt.Errorf("failed to detach namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// waitForDetach polls until the controller has cleaned up the namespace and cleared its inventory
+func waitForDetach(ctx context.Context, c client.Client, namespace string, timeout time.Duration) error {
+	reported := ""
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		var ns corev1.Namespace
+		if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+			return false, err
+		}
+		if ns.Labels[controllers.NamespaceClassLabel] != "" {
+			return false, fmt.Errorf("namespace %s was attached to class %s again", namespace, ns.Labels[controllers.NamespaceClassLabel])
+		}
+		if attached := ns.Annotations[engine.AttachedClassAnnotation]; attached != "" {
+			if reported == "" {
+				reported = attached
+				fmt.Fprintf(os.Stderr, "  %s: waiting for the resources of class %s to be cleaned up\n", namespace, attached)
+			}
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// restorePolicy puts back the deletion policy annotation a namespace had before it was detached with policy
+func restorePolicy(ctx context.Context, c client.Client, namespace string, policy v1.DeletionPolicy, previous string) error {
+	if previous == string(policy) {
+		return nil
+	}
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	if previous == "" {
+		delete(ns.Annotations, controllers.DeletionPolicyAnnotation)
+	} else {
+		ns.Annotations[controllers.DeletionPolicyAnnotation] = previous
+	}
+	if err := c.Patch(ctx, &ns, patch); err != nil {
+		return fmt.Errorf("failed to restore the deletion policy of namespace %s: %w", namespace, err)
+	}
+	return nil
+}
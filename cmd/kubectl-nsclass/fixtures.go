@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	nsclasstesting "github.com/lixu/namespaceclass-operator/pkg/testing"
+	"github.com/pmezard/go-difflib/difflib"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// runFixtures writes the resources a class renders for sample namespaces as golden files, one directory per
+// namespace, or with --verify diffs previously written golden files against the live objects in the cluster
+func runFixtures(args []string) error {
+	var opts kubeOptions
+	var classFile, namespacesFile, profile, out string
+	var verify bool
+	fs := flag.NewFlagSet("fixtures", flag.ExitOnError)
+	opts.bind(fs)
+	fs.StringVar(&classFile, "f", "", "Read the class from this manifest instead of the cluster.")
+	fs.StringVar(&namespacesFile, "namespaces", "", "YAML file of sample Namespace manifests. Defaults to a namespace named sample.")
+	fs.StringVar(&profile, "profile", "", "Comma-separated key=value pairs of the cluster profile template conditions see.")
+	fs.StringVar(&out, "out", "fixtures", "Directory holding the golden files.")
+	fs.BoolVar(&verify, "verify", false, "Diff the golden files in --out against the cluster instead of writing them.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if verify {
+		if fs.NArg() != 0 {
+			return fmt.Errorf("usage: kubectl nsclass fixtures --verify [--out <dir>]")
+		}
+		c, err := opts.client()
+		if err != nil {
+			return err
+		}
+		return verifyFixtures(ctx, c, out)
+	}
+
+	if (classFile == "") == (fs.NArg() == 0) {
+		return fmt.Errorf("usage: kubectl nsclass fixtures (<class> | -f <class.yaml>) [--namespaces <file>] [--out <dir>]")
+	}
+	var nsClass *v1.NamespaceClass
+	if classFile != "" {
+		var err error
+		if nsClass, err = nsclasstesting.LoadClass(classFile); err != nil {
+			return err
+		}
+	} else {
+		c, err := opts.client()
+		if err != nil {
+			return err
+		}
+		nsClass = &v1.NamespaceClass{}
+		if err := c.Get(ctx, types.NamespacedName{Name: fs.Arg(0)}, nsClass); err != nil {
+			return fmt.Errorf("failed to get class %s: %w", fs.Arg(0), err)
+		}
+	}
+
+	namespaces := []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}}
+	if namespacesFile != "" {
+		var err error
+		if namespaces, err = loadNamespaces(namespacesFile); err != nil {
+			return err
+		}
+	}
+	values, err := parseProfile(profile)
+	if err != nil {
+		return err
+	}
+	return writeFixtures(ctx, nsClass, namespaces, values, out)
+}
+
+// writeFixtures renders nsClass for every namespace into <out>/<namespace>/<kind>-<name>.yaml, replacing what the
+// namespace directories held before so removed resources disappear from the goldens
+func writeFixtures(ctx context.Context, nsClass *v1.NamespaceClass, namespaces []corev1.Namespace, profile engine.StaticProfile, out string) error {
+	renderer := &engine.TemplateRenderer{Profile: profile}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		rendered, err := renderer.Render(ctx, ns, nsClass)
+		if err != nil {
+			return fmt.Errorf("failed to render class %s for namespace %s: %w", nsClass.Name, ns.Name, err)
+		}
+		dir := filepath.Join(out, ns.Name)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		for _, res := range rendered {
+			obj := res.Object.DeepCopy()
+			// The owner reference carries the UID of the live namespace, which a sample does not have
+			obj.SetOwnerReferences(nil)
+			data, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return err
+			}
+			name := strings.ToLower(obj.GetKind()) + "-" + obj.GetName() + ".yaml"
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s: %d resources\n", dir, len(rendered))
+	}
+	return nil
+}
+
+// verifyFixtures compares every golden file under dir with the live object it describes, considering only the
+// fields the golden file sets, and fails when any differ or are missing
+func verifyFixtures(ctx context.Context, c client.Client, dir string) error {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".yaml") {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	mismatches := 0
+	for _, path := range files {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		want := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(raw, &want.Object); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(want.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(want), live); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get %s/%s in %s: %w", want.GetKind(), want.GetName(), want.GetNamespace(), err)
+			}
+			fmt.Printf("MISSING %s: %s/%s not found in namespace %s\n", path, want.GetKind(), want.GetName(), want.GetNamespace())
+			mismatches++
+			continue
+		}
+		// Compare both sides as re-encoded YAML, so hand edits to the formatting of a golden file do not matter
+		expected, err := yaml.Marshal(want.Object)
+		if err != nil {
+			return err
+		}
+		got, err := yaml.Marshal(restrictTo(live.Object, want.Object))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(got, expected) {
+			continue
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(expected)),
+			B:        difflib.SplitLines(string(got)),
+			FromFile: path,
+			ToFile:   "cluster",
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			continue
+		}
+		fmt.Print(diff)
+		mismatches++
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d fixtures differ from the cluster", mismatches, len(files))
+	}
+	fmt.Printf("%d fixtures match the cluster\n", len(files))
+	return nil
+}
+
+// restrictTo returns the parts of live at the map keys want sets, so fields defaulted or added by the cluster do not
+// count as differences. Lists and scalars are compared whole.
+func restrictTo(live, want map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(want))
+	for key, w := range want {
+		l, ok := live[key]
+		if !ok {
+			continue
+		}
+		wm, wok := w.(map[string]interface{})
+		lm, lok := l.(map[string]interface{})
+		if wok && lok {
+			out[key] = restrictTo(lm, wm)
+			continue
+		}
+		out[key] = l
+	}
+	return out
+}
+
+// loadNamespaces reads the Namespace manifests of a multi-document YAML file
+func loadNamespaces(path string) ([]corev1.Namespace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var namespaces []corev1.Namespace
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var ns corev1.Namespace
+		if err := decoder.Decode(&ns); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode namespaces in %s: %w", path, err)
+		}
+		if ns.Name == "" {
+			continue
+		}
+		namespaces = append(namespaces, ns)
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("no namespaces in %s", path)
+	}
+	return namespaces, nil
+}
+
+// parseProfile parses comma-separated key=value pairs
+func parseProfile(s string) (engine.StaticProfile, error) {
+	profile := engine.StaticProfile{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid profile entry %q, expected key=value", pair)
+		}
+		profile[key] = value
+	}
+	return profile, nil
+}
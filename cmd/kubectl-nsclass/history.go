@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// runHistory prints the rollouts of the last generations of a class recorded in status.history, newest first
+func runHistory(args []string) error {
+	var opts kubeOptions
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	opts.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl nsclass history <class>")
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	var nsClass v1.NamespaceClass
+	if err := c.Get(ctx, types.NamespacedName{Name: fs.Arg(0)}, &nsClass); err != nil {
+		return fmt.Errorf("failed to get class %s: %w", fs.Arg(0), err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tSTARTED\tCOMPLETED\tSUMMARY\tFAILED NAMESPACES")
+	for i := len(nsClass.Status.History) - 1; i >= 0; i-- {
+		entry := nsClass.Status.History[i]
+		completed := "<in progress>"
+		if entry.CompletedAt != nil {
+			completed = entry.CompletedAt.Format(time.RFC3339)
+		}
+		summary := "<none>"
+		if s := entry.Summary; s != nil {
+			summary = fmt.Sprintf("%d namespaces, +%d ~%d -%d, %d failures", s.NamespacesTouched, s.Applied, s.Changed, s.Pruned, s.Failures)
+		}
+		failed := "<none>"
+		if len(entry.FailedNamespaces) > 0 {
+			failed = strings.Join(entry.FailedNamespaces, ",")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", entry.Revision, entry.StartedAt.Format(time.RFC3339), completed, summary, failed)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,165 @@
+// Command kubectl-nsclass is a kubectl plugin for inspecting and maintaining NamespaceClass state.
+// Install it on the PATH and invoke it as `kubectl nsclass <command>`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const usage = `Usage: kubectl nsclass <command> [flags]
+
+Commands:
+  orphans    List managed resources missing from every inventory and classes no namespace references
+  adopt      Add an existing resource to the inventory of its namespace's class
+  migrate    Plan and perform a staged switch of namespaces from one class to another
+  detach     Plan and perform a staged removal of a class from namespaces, deleting or orphaning its resources
+  baseline   Show how a class changed since the last generation every namespace synced
+  history    List the rollouts of the last generations of a class
+  resources  List the namespaces holding resources a class manages, optionally of one kind or name
+  validate   Check manifests against the schemas and class rules of this version, or print a JSON Schema
+  test       Run the tests embedded in a class and report each result
+  fixtures   Write golden files of what a class renders for sample namespaces, or verify them against the cluster
+  preview    Show what a class would create, change and prune in a namespace, with a diff of every change
+  approve    Approve a NamespaceClassClaim
+  deny       Deny a NamespaceClassClaim
+
+Run "kubectl nsclass <command> -h" for command flags.
+`
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = corev1.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "orphans":
+		err = runOrphans(args)
+	case "adopt":
+		err = runAdopt(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "detach":
+		err = runDetach(args)
+	case "baseline":
+		err = runBaseline(args)
+	case "history":
+		err = runHistory(args)
+	case "resources":
+		err = runResources(args)
+	case "validate":
+		err = runValidate(args)
+	case "test":
+		err = runTest(args)
+	case "fixtures":
+		err = runFixtures(args)
+	case "preview":
+		err = runPreview(args)
+	case "approve":
+		err = runDecide("approve", v1.ClaimApproved, args)
+	case "deny":
+		err = runDecide("deny", v1.ClaimDenied, args)
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// kubeOptions holds the connection flags shared by every command
+type kubeOptions struct {
+	kubeconfig string
+	context    string
+	namespace  string
+
+	clientConfig clientcmd.ClientConfig
+	restConfig   *rest.Config
+}
+
+func (o *kubeOptions) bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	fs.StringVar(&o.context, "context", "", "The name of the kubeconfig context to use.")
+	fs.StringVar(&o.namespace, "namespace", "", "Namespace to operate on. Defaults to the context namespace.")
+	fs.StringVar(&o.namespace, "n", "", "Shorthand for --namespace.")
+}
+
+func (o *kubeOptions) config() (*rest.Config, error) {
+	if o.restConfig != nil {
+		return o.restConfig, nil
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	o.clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: o.context})
+	cfg, err := o.clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	o.restConfig = cfg
+	return cfg, nil
+}
+
+// targetNamespace returns --namespace or the namespace of the current context
+func (o *kubeOptions) targetNamespace() (string, error) {
+	if o.namespace != "" {
+		return o.namespace, nil
+	}
+	if _, err := o.config(); err != nil {
+		return "", err
+	}
+	ns, _, err := o.clientConfig.Namespace()
+	return ns, err
+}
+
+func (o *kubeOptions) client() (client.Client, error) {
+	cfg, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+func (o *kubeOptions) discovery() (discovery.DiscoveryInterface, error) {
+	cfg, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewDiscoveryClientForConfig(cfg)
+}
+
+// restMapper returns a mapper that also understands short names such as "cm"
+func (o *kubeOptions) restMapper() (meta.RESTMapper, error) {
+	dc, err := o.discovery()
+	if err != nil {
+		return nil, err
+	}
+	cached := memory.NewMemCacheClient(dc)
+	return restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil), nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespacePlan lists how switching one namespace between classes changes its resources
+type namespacePlan struct {
+	namespace string
+	added     []string
+	removed   []string
+	changed   []string
+}
+
+// runMigrate switches namespaces from one class to another in stages, after printing the per-namespace plan
+func runMigrate(args []string) error {
+	var opts kubeOptions
+	var from, to, selector string
+	var batchSize int
+	var timeout time.Duration
+	var dryRun bool
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	opts.bind(fs)
+	fs.StringVar(&from, "from", "", "Class the namespaces are currently attached to (required).")
+	fs.StringVar(&to, "to", "", "Class to attach the namespaces to (required).")
+	fs.StringVar(&selector, "namespaces", "", "Label selector restricting which namespaces are migrated.")
+	fs.IntVar(&batchSize, "batch-size", 5, "Number of namespaces switched per stage.")
+	fs.DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for a stage to converge before aborting.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the plan without switching any namespace.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if from == "" || to == "" {
+		return fmt.Errorf("--from and --to are required")
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return fmt.Errorf("invalid --namespaces selector: %w", err)
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	var fromClass, toClass v1.NamespaceClass
+	if err := c.Get(ctx, types.NamespacedName{Name: from}, &fromClass); err != nil {
+		return fmt.Errorf("failed to get class %s: %w", from, err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: to}, &toClass); err != nil {
+		return fmt.Errorf("failed to get class %s: %w", to, err)
+	}
+	fromObjects, err := templateObjects(&fromClass)
+	if err != nil {
+		return err
+	}
+	toObjects, err := templateObjects(&toClass)
+	if err != nil {
+		return err
+	}
+
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList, client.MatchingLabels{controllers.NamespaceClassLabel: from}); err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	var plans []namespacePlan
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		if !sel.Matches(labels.Set(ns.Labels)) {
+			continue
+		}
+		inventory, err := engine.ReadInventory(ctx, c, ns)
+		if err != nil {
+			return fmt.Errorf("failed to read inventory of namespace %s: %w", ns.Name, err)
+		}
+		plans = append(plans, planNamespace(ns.Name, inventory, fromObjects, toObjects))
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].namespace < plans[j].namespace })
+
+	if len(plans) == 0 {
+		fmt.Printf("No namespaces attached to class %s match the selector\n", from)
+		return nil
+	}
+	for _, p := range plans {
+		fmt.Printf("namespace %s:\n", p.namespace)
+		printPlanLines("+", p.added)
+		printPlanLines("~", p.changed)
+		printPlanLines("-", p.removed)
+	}
+	if dryRun {
+		return nil
+	}
+
+	for start := 0; start < len(plans); start += batchSize {
+		end := min(start+batchSize, len(plans))
+		batch := plans[start:end]
+		for _, p := range batch {
+			if err := switchClass(ctx, c, p.namespace, from, to); err != nil {
+				return err
+			}
+		}
+		for _, p := range batch {
+			if err := waitForClass(ctx, c, p.namespace, to, timeout); err != nil {
+				return fmt.Errorf("namespace %s did not converge on class %s: %w", p.namespace, to, err)
+			}
+		}
+		fmt.Printf("[%d/%d] switched %s\n", end, len(plans), namespaceNames(batch))
+	}
+	return nil
+}
+
+// planNamespace compares the namespace inventory with the target class templates
+func planNamespace(namespace string, inventory []engine.InventoryItem, fromObjects, toObjects map[string]*unstructured.Unstructured) namespacePlan {
+	plan := namespacePlan{namespace: namespace}
+	current := make(map[string]bool)
+	for _, item := range inventory {
+		id := identity(item.APIVersion, item.Kind, "", item.Name)
+		current[id] = true
+		// Adopted resources are carried over regardless of the class
+		if _, ok := toObjects[id]; !ok && !item.Adopted {
+			plan.removed = append(plan.removed, item.Kind+"/"+item.Name)
+		}
+	}
+	for id, obj := range toObjects {
+		name := obj.GetKind() + "/" + obj.GetName()
+		if !current[id] {
+			plan.added = append(plan.added, name)
+			continue
+		}
+		if prev, ok := fromObjects[id]; ok && !reflect.DeepEqual(prev.Object, obj.Object) {
+			plan.changed = append(plan.changed, name)
+		}
+	}
+	sort.Strings(plan.added)
+	sort.Strings(plan.changed)
+	sort.Strings(plan.removed)
+	return plan
+}
+
+// templateObjects decodes the class templates keyed by identity
+func templateObjects(class *v1.NamespaceClass) (map[string]*unstructured.Unstructured, error) {
+	decoded, err := engine.NewTemplateCache().DecodedTemplates(class)
+	if err != nil {
+		return nil, fmt.Errorf("class %s: %w", class.Name, err)
+	}
+	objects := make(map[string]*unstructured.Unstructured)
+	for _, obj := range decoded {
+		if obj != nil {
+			objects[identity(obj.GetAPIVersion(), obj.GetKind(), "", obj.GetName())] = obj
+		}
+	}
+	return objects, nil
+}
+
+// switchClass relabels a namespace, guarding against a concurrent label change
+func switchClass(ctx context.Context, c client.Client, namespace, from, to string) error {
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	if ns.Labels[controllers.NamespaceClassLabel] != from {
+		return fmt.Errorf("namespace %s is no longer attached to class %s", namespace, from)
+	}
+	patch := client.MergeFromWithOptions(ns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	ns.Labels[controllers.NamespaceClassLabel] = to
+	if err := c.Patch(ctx, &ns, patch); err != nil {
+		return fmt.Errorf("failed to relabel namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// waitForClass polls until the controller reports the namespace synced with the target class
+func waitForClass(ctx context.Context, c client.Client, namespace, class string, timeout time.Duration) error {
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		var ns corev1.Namespace
+		if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+			return false, err
+		}
+		for _, cond := range ns.Status.Conditions {
+			if cond.Type == controllers.NamespaceClassSyncedCondition && cond.Status == corev1.ConditionFalse {
+				fmt.Fprintf(os.Stderr, "  %s: %s: %s\n", namespace, cond.Reason, cond.Message)
+			}
+		}
+		// A class without resources leaves no attached-class annotation behind
+		attached := ns.Annotations[engine.AttachedClassAnnotation]
+		return isSynced(&ns) && (attached == class || attached == ""), nil
+	})
+}
+
+func isSynced(ns *corev1.Namespace) bool {
+	for _, cond := range ns.Status.Conditions {
+		if cond.Type == controllers.NamespaceClassSyncedCondition {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func printPlanLines(prefix string, lines []string) {
+	for _, l := range lines {
+		fmt.Printf("  %s %s\n", prefix, l)
+	}
+}
+
+func namespaceNames(plans []namespacePlan) []string {
+	names := make([]string, len(plans))
+	for i, p := range plans {
+		names[i] = p.namespace
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runOrphans lists managed-labeled resources that no namespace inventory tracks, and classes nobody references
+func runOrphans(args []string) error {
+	var opts kubeOptions
+	fs := flag.NewFlagSet("orphans", flag.ExitOnError)
+	opts.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList); err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	tracked := make(map[string]bool)
+	referenced := make(map[string]bool)
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		if class := ns.Labels[controllers.NamespaceClassLabel]; class != "" {
+			referenced[class] = true
+		}
+		items, err := engine.ReadInventory(ctx, c, ns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: namespace %s has an unreadable inventory: %v\n", ns.Name, err)
+			continue
+		}
+		for _, item := range items {
+			tracked[identity(item.APIVersion, item.Kind, item.Namespace, item.Name)] = true
+		}
+	}
+
+	orphans, err := listUntrackedResources(ctx, c, &opts, tracked)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tKIND\tNAME\tSOURCE CLASS")
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.GetNamespace(), o.GetKind(), o.GetName(), o.GetLabels()[engine.SourceClassLabel])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var classes v1.NamespaceClassList
+	if err := c.List(ctx, &classes); err != nil {
+		return fmt.Errorf("failed to list namespace classes: %w", err)
+	}
+	var unreferenced []string
+	for _, class := range classes.Items {
+		if !referenced[class.Name] {
+			unreferenced = append(unreferenced, class.Name)
+		}
+	}
+	sort.Strings(unreferenced)
+
+	fmt.Println()
+	fmt.Println("UNREFERENCED CLASSES")
+	for _, name := range unreferenced {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// listUntrackedResources scans every listable namespaced resource type for objects carrying the managed-by label
+// whose identity is not in the tracked set
+func listUntrackedResources(ctx context.Context, c client.Client, opts *kubeOptions, tracked map[string]bool) ([]unstructured.Unstructured, error) {
+	dc, err := opts.discovery()
+	if err != nil {
+		return nil, err
+	}
+	// Partial discovery failures (e.g. an unavailable aggregated API) still return the healthy groups
+	resourceLists, err := dc.ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+
+	listOpts := []client.ListOption{client.MatchingLabels{engine.ManagedByLabel: engine.ManagerName}}
+	if opts.namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(opts.namespace))
+	}
+
+	var orphans []unstructured.Unstructured
+	for _, rl := range resourceLists {
+		gv, err := schema.ParseGroupVersion(rl.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, res := range rl.APIResources {
+			if !slices.Contains(res.Verbs, "list") {
+				continue
+			}
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(gv.WithKind(res.Kind + "List"))
+			if err := c.List(ctx, list, listOpts...); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to list %s: %v\n", res.Name, err)
+				continue
+			}
+			for _, obj := range list.Items {
+				if !tracked[identity(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())] {
+					orphans = append(orphans, obj)
+				}
+			}
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].GetNamespace() != orphans[j].GetNamespace() {
+			return orphans[i].GetNamespace() < orphans[j].GetNamespace()
+		}
+		if orphans[i].GetKind() != orphans[j].GetKind() {
+			return orphans[i].GetKind() < orphans[j].GetKind()
+		}
+		return orphans[i].GetName() < orphans[j].GetName()
+	})
+	return orphans, nil
+}
+
+// identity keys a resource by group rather than full apiVersion, since discovery lists the preferred version
+// which may differ from the version recorded in the inventory
+func identity(apiVersion, kind, namespace, name string) string {
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return fmt.Sprintf("%s|%s|%s|%s", gv.Group, kind, namespace, name)
+}
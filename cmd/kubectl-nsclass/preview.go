@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	nsclasstesting "github.com/lixu/namespaceclass-operator/pkg/testing"
+	"github.com/pmezard/go-difflib/difflib"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// runPreview shows what syncing a namespace with a class would create (+), change (~) and prune (-), with a
+// unified diff of every change. Each rendered resource is sent as a server-side dry run, so defaulting and
+// admission run as they would for the operator, and nothing in the cluster is written.
+func runPreview(args []string) error {
+	var opts kubeOptions
+	var classFile, profile string
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	opts.bind(fs)
+	fs.StringVar(&classFile, "f", "", "Read the class from this manifest instead of the cluster.")
+	fs.StringVar(&profile, "profile", "", "Comma-separated key=value pairs of the cluster profile template conditions see.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || (classFile != "" && fs.NArg() > 0) {
+		return fmt.Errorf("usage: kubectl nsclass preview [<class> | -f <class.yaml>] [-n <namespace>] [--profile env=prod]")
+	}
+	values, err := parseProfile(profile)
+	if err != nil {
+		return err
+	}
+	namespace, err := opts.targetNamespace()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	// Classes are resolved the way the operator resolves them: parents and additional classes come from the cluster
+	getClass := func(name string) (*v1.NamespaceClass, error) {
+		nsClass := &v1.NamespaceClass{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name}, nsClass); err != nil {
+			return nil, err
+		}
+		return nsClass, nil
+	}
+	resolve := func(nsClass *v1.NamespaceClass) (*v1.NamespaceClass, error) {
+		if len(nsClass.Spec.InheritFrom) == 0 {
+			return nsClass, nil
+		}
+		resolved, err := engine.ResolveInheritance(nsClass, getClass)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the classes %s inherits from: %w", nsClass.Name, err)
+		}
+		return resolved, nil
+	}
+	var nsClass *v1.NamespaceClass
+	switch {
+	case classFile != "":
+		if nsClass, err = nsclasstesting.LoadClass(classFile); err != nil {
+			return err
+		}
+	case fs.NArg() == 1 || ns.Labels[controllers.NamespaceClassLabel] != "":
+		name := fs.Arg(0)
+		if name == "" {
+			name = ns.Labels[controllers.NamespaceClassLabel]
+		}
+		if nsClass, err = getClass(name); err != nil {
+			return fmt.Errorf("failed to get class %s: %w", name, err)
+		}
+	default:
+		return fmt.Errorf("namespace %s has no class; name one or pass -f <class.yaml>", namespace)
+	}
+	if nsClass, err = resolve(nsClass); err != nil {
+		return err
+	}
+	if nsClass.Spec.Renderer != "" {
+		fmt.Printf("Class %s is rendered by external renderer %s, which previews cannot call\n", nsClass.Name, nsClass.Spec.Renderer)
+		return nil
+	}
+
+	renderer := &engine.ClassComposer{
+		Base: &engine.TemplateRenderer{Profile: values},
+		Class: func(_ context.Context, _ *corev1.Namespace, name string) (*v1.NamespaceClass, error) {
+			class, err := getClass(name)
+			if err != nil {
+				return nil, err
+			}
+			return resolve(class)
+		},
+	}
+	rendered, err := renderer.Render(ctx, ns, nsClass)
+	if err != nil {
+		return fmt.Errorf("failed to render class %s for namespace %s: %w", nsClass.Name, namespace, err)
+	}
+
+	var created, changed, unchanged, pruned, failed int
+	keep := make(map[string]bool, len(rendered))
+	for _, res := range rendered {
+		obj := res.Object
+		ref := fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName())
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get %s: %w", ref, err)
+			}
+			live = nil
+		}
+		if res.UpdatePolicy == v1.UpdatePolicyNever && live == nil {
+			// Never written, and not tracked until someone else creates it
+			continue
+		}
+		keep[engine.ItemFor(obj).Key()] = true
+		if live != nil && (res.UpdatePolicy == v1.UpdatePolicyIfNotPresent || res.UpdatePolicy == v1.UpdatePolicyNever) {
+			fmt.Printf("= %s (updatePolicy %s)\n", ref, res.UpdatePolicy)
+			unchanged++
+			continue
+		}
+		result := obj.DeepCopy()
+		if live != nil {
+			// The operator stamps these depending on its flags; keep them so they do not show up as removed
+			annotations := result.GetAnnotations()
+			for _, key := range []string{engine.RenderHashAnnotation, engine.AppliedByAnnotation} {
+				if value, ok := live.GetAnnotations()[key]; ok {
+					if annotations == nil {
+						annotations = map[string]string{}
+					}
+					annotations[key] = value
+				}
+			}
+			result.SetAnnotations(annotations)
+		}
+		if err := c.Patch(ctx, result, client.Apply, client.FieldOwner(engine.ManagerName), client.ForceOwnership, client.DryRunAll); err != nil {
+			fmt.Printf("! %s: %v\n", ref, err)
+			failed++
+			continue
+		}
+		if live == nil {
+			fmt.Printf("+ %s\n", ref)
+			created++
+			continue
+		}
+		diff, err := objectDiff(live, result)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			unchanged++
+			continue
+		}
+		fmt.Printf("~ %s\n%s", ref, diff)
+		changed++
+	}
+
+	inventory, err := engine.ReadInventory(ctx, c, ns)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory of namespace %s: %w", namespace, err)
+	}
+	for _, item := range inventory {
+		if !item.Adopted && !keep[item.Key()] {
+			fmt.Printf("- %s/%s\n", item.Kind, item.Name)
+			pruned++
+		}
+	}
+
+	fmt.Printf("Namespace %s with class %s: %d to create, %d to change, %d unchanged, %d to prune\n",
+		namespace, nsClass.Name, created, changed, unchanged, pruned)
+	if failed > 0 {
+		return fmt.Errorf("%d resources were rejected by the API server", failed)
+	}
+	return nil
+}
+
+// objectDiff returns a unified diff from live to the dry-run result of applying over it, ignoring the metadata the
+// API server maintains on every write, or "" when they match
+func objectDiff(live, result *unstructured.Unstructured) (string, error) {
+	var docs [2]string
+	for i, obj := range []*unstructured.Unstructured{live, result} {
+		content := obj.DeepCopy().Object
+		for _, field := range []string{"managedFields", "resourceVersion", "generation"} {
+			unstructured.RemoveNestedField(content, "metadata", field)
+		}
+		data, err := yaml.Marshal(content)
+		if err != nil {
+			return "", err
+		}
+		docs[i] = string(data)
+	}
+	if docs[0] == docs[1] {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(docs[0]),
+		B:        difflib.SplitLines(docs[1]),
+		FromFile: "live",
+		ToFile:   "class",
+		Context:  3,
+	})
+}
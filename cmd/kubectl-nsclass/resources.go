@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/query"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// runResources lists the resources a class manages across namespaces, according to the namespace inventories
+func runResources(args []string) error {
+	var opts kubeOptions
+	var kind, name string
+	fs := flag.NewFlagSet("resources", flag.ExitOnError)
+	opts.bind(fs)
+	fs.StringVar(&kind, "kind", "", "Only list resources of this kind (case-insensitive).")
+	fs.StringVar(&name, "name", "", "Only list resources with this name.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl nsclass resources [--kind kind] [--name name] <class>")
+	}
+
+	ctx := context.Background()
+	c, err := opts.client()
+	if err != nil {
+		return err
+	}
+	var nsClass v1.NamespaceClass
+	if err := c.Get(ctx, types.NamespacedName{Name: fs.Arg(0)}, &nsClass); err != nil {
+		return fmt.Errorf("failed to get class %s: %w", fs.Arg(0), err)
+	}
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList); err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	result := query.SearchResources(ctx, c, nsList.Items, nsClass.Name, kind, name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tAPIVERSION\tKIND\tNAME")
+	for _, res := range result.Resources {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Namespace, res.APIVersion, res.Kind, res.Name)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d resources in %d namespaces\n", len(result.Resources), len(result.Namespaces))
+	return nil
+}
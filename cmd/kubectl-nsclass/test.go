@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	nsclasstesting "github.com/lixu/namespaceclass-operator/pkg/testing"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// runTest runs the spec.tests of a class, read from the cluster or a manifest, and prints each result. The classes
+// it inherits from are always read from the cluster.
+func runTest(args []string) error {
+	var opts kubeOptions
+	var classFile string
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	opts.bind(fs)
+	fs.StringVar(&classFile, "f", "", "Read the class from this manifest instead of the cluster.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (classFile == "") == (fs.NArg() == 0) {
+		return fmt.Errorf("usage: kubectl nsclass test (<class> | -f <class.yaml>)")
+	}
+	ctx := context.Background()
+	var nsClass *v1.NamespaceClass
+	if classFile != "" {
+		var err error
+		if nsClass, err = nsclasstesting.LoadClass(classFile); err != nil {
+			return err
+		}
+	} else {
+		c, err := opts.client()
+		if err != nil {
+			return err
+		}
+		nsClass = &v1.NamespaceClass{}
+		if err := c.Get(ctx, types.NamespacedName{Name: fs.Arg(0)}, nsClass); err != nil {
+			return fmt.Errorf("failed to get class %s: %w", fs.Arg(0), err)
+		}
+	}
+	if len(nsClass.Spec.Tests) == 0 {
+		fmt.Printf("Class %s has no tests\n", nsClass.Name)
+		return nil
+	}
+	if len(nsClass.Spec.InheritFrom) > 0 {
+		// The parents are read from the cluster, as the operator would inherit them
+		c, err := opts.client()
+		if err != nil {
+			return err
+		}
+		resolved, err := engine.ResolveInheritance(nsClass, func(name string) (*v1.NamespaceClass, error) {
+			parent := &v1.NamespaceClass{}
+			if err := c.Get(ctx, types.NamespacedName{Name: name}, parent); err != nil {
+				return nil, err
+			}
+			return parent, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to resolve the classes %s inherits from: %w", nsClass.Name, err)
+		}
+		nsClass = resolved
+	}
+	if nsClass.Spec.Renderer != "" {
+		fmt.Printf("Class %s is rendered by external renderer %s, which tests cannot call\n", nsClass.Name, nsClass.Spec.Renderer)
+		return nil
+	}
+
+	results := engine.RunClassTests(ctx, nsClass)
+	for _, result := range results {
+		if len(result.Failures) == 0 {
+			fmt.Printf("PASS  %s\n", result.Name)
+			continue
+		}
+		fmt.Printf("FAIL  %s\n", result.Name)
+		for _, failure := range result.Failures {
+			fmt.Printf("      %s\n", failure)
+		}
+	}
+	if err := engine.ClassTestsError(results); err != nil {
+		return fmt.Errorf("class %s: %w", nsClass.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/config/crd"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"github.com/lixu/namespaceclass-operator/webhooks"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// runValidate checks manifests against the CRD schemas this plugin was built with and the class webhook's rules, or
+// prints the JSON Schema of a kind with --schema
+func runValidate(args []string) error {
+	var kind, version string
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.StringVar(&kind, "schema", "", "Print the JSON Schema of this kind, e.g. NamespaceClass, instead of validating files.")
+	fs.StringVar(&version, "version", "", "With --schema, the API version to print; defaults to the storage version.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if kind != "" {
+		found, err := crd.Lookup(kind, version)
+		if err != nil {
+			return err
+		}
+		if found == nil {
+			return fmt.Errorf("no schema for kind %s", kind)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found.JSONSchema())
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: kubectl nsclass validate <file>... | --schema <kind> [--version <version>]")
+	}
+
+	checked, invalid := 0, 0
+	for _, path := range fs.Args() {
+		n, bad, err := validateFile(path)
+		if err != nil {
+			return err
+		}
+		checked += n
+		invalid += bad
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d documents are invalid", invalid, checked)
+	}
+	fmt.Printf("%d documents valid\n", checked)
+	return nil
+}
+
+// validateFile validates every document of a multi-document YAML file whose kind the operator defines, printing
+// each violation, and returns how many documents it checked and how many were invalid
+func validateFile(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	checked, invalid := 0, 0
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return checked, invalid, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		gv, _ := schema.ParseGroupVersion(apiVersion)
+		found, err := crd.Lookup(kind, gv.Version)
+		if err != nil {
+			return checked, invalid, err
+		}
+		if found == nil || found.Group != gv.Group {
+			continue
+		}
+		checked++
+		name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+		problems := found.Validate(obj)
+		if len(problems) == 0 && found.Kind == "NamespaceClass" && gv.Version == v1.GroupVersion.Version {
+			if err := validateClass(obj); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		for _, p := range problems {
+			fmt.Printf("%s: %s %s: %s\n", path, kind, name, p)
+		}
+		if len(problems) > 0 {
+			invalid++
+		}
+	}
+	return checked, invalid, nil
+}
+
+// validateClass applies the class webhook's checks to a schema-valid v1 NamespaceClass manifest; without a cluster,
+// templates of cluster-scoped kinds are not caught
+func validateClass(obj map[string]interface{}) error {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var nsClass v1.NamespaceClass
+	if err := json.Unmarshal(raw, &nsClass); err != nil {
+		return err
+	}
+	return webhooks.ValidateClassSpec(&nsClass, engine.TemplatePolicy{})
+}
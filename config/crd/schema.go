@@ -0,0 +1,309 @@
+// Package crd embeds the CustomResourceDefinitions in bases/, so the operator and its tools serve and validate
+// against the schema of the version they were built from rather than whatever CRD happens to be installed.
+package crd
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed bases/*.yaml
+var bases embed.FS
+
+// Schema is the openAPIV3Schema of one served version of a CRD
+type Schema struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// Storage marks the version objects are stored in
+	Storage bool `json:"storage"`
+	// OpenAPIV3Schema is the structural schema as written in the CRD
+	OpenAPIV3Schema map[string]interface{} `json:"-"`
+}
+
+// APIVersion returns the group/version of the schema
+func (s *Schema) APIVersion() string {
+	return s.Group + "/" + s.Version
+}
+
+// crdManifest is the part of a CustomResourceDefinition the schemas are read from
+type crdManifest struct {
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name    string `json:"name"`
+			Served  bool   `json:"served"`
+			Storage bool   `json:"storage"`
+			Schema  struct {
+				OpenAPIV3Schema map[string]interface{} `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// Schemas returns the schema of every served version of the embedded CRDs, sorted by kind with the storage version
+// first
+func Schemas() ([]Schema, error) {
+	files, err := fs.Glob(bases, "bases/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	var out []Schema
+	for _, name := range files {
+		raw, err := bases.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var crd crdManifest
+		if err := yaml.Unmarshal(raw, &crd); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		for _, v := range crd.Spec.Versions {
+			if !v.Served {
+				continue
+			}
+			out = append(out, Schema{
+				Group:           crd.Spec.Group,
+				Version:         v.Name,
+				Kind:            crd.Spec.Names.Kind,
+				Storage:         v.Storage,
+				OpenAPIV3Schema: v.Schema.OpenAPIV3Schema,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Storage && !out[j].Storage
+	})
+	return out, nil
+}
+
+// Lookup returns the schema of kind, matched case-insensitively, in version or in the storage version when version
+// is empty. It returns nil when no embedded CRD serves that kind and version.
+func Lookup(kind, version string) (*Schema, error) {
+	schemas, err := Schemas()
+	if err != nil {
+		return nil, err
+	}
+	for i := range schemas {
+		s := &schemas[i]
+		if strings.EqualFold(s.Kind, kind) && (s.Version == version || (version == "" && s.Storage)) {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// JSONSchema returns the schema as a JSON Schema document for editors and CI validators. apiVersion and kind are
+// pinned to the schema's, int-or-string fields are spelled out and objects without preserved unknown fields reject
+// fields the operator does not know.
+func (s *Schema) JSONSchema() map[string]interface{} {
+	doc := toJSONSchema(s.OpenAPIV3Schema, true)
+	doc["$schema"] = "http://json-schema.org/draft-07/schema#"
+	doc["title"] = s.Kind + " (" + s.APIVersion() + ")"
+	properties, _ := doc["properties"].(map[string]interface{})
+	if properties == nil {
+		properties = map[string]interface{}{}
+		doc["properties"] = properties
+	}
+	properties["apiVersion"] = map[string]interface{}{"type": "string", "enum": []interface{}{s.APIVersion()}}
+	properties["kind"] = map[string]interface{}{"type": "string", "enum": []interface{}{s.Kind}}
+	if _, ok := properties["metadata"]; !ok {
+		properties["metadata"] = map[string]interface{}{"type": "object"}
+	}
+	doc["required"] = []interface{}{"apiVersion", "kind"}
+	return doc
+}
+
+// toJSONSchema copies an OpenAPI v3 schema node, translating the Kubernetes extensions JSON Schema has no
+// counterpart for
+func toJSONSchema(node map[string]interface{}, root bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(node))
+	for k, v := range node {
+		switch k {
+		case "properties":
+			props := map[string]interface{}{}
+			for name, child := range asMap(v) {
+				props[name] = toJSONSchema(asMap(child), false)
+			}
+			out[k] = props
+		case "items":
+			out[k] = toJSONSchema(asMap(v), false)
+		case "additionalProperties":
+			if child, ok := v.(map[string]interface{}); ok {
+				out[k] = toJSONSchema(child, false)
+			} else {
+				out[k] = v
+			}
+		default:
+			out[k] = v
+		}
+	}
+	if node["x-kubernetes-int-or-string"] == true {
+		out["anyOf"] = []interface{}{map[string]interface{}{"type": "integer"}, map[string]interface{}{"type": "string"}}
+	}
+	// The root also carries apiVersion, kind and metadata, which CRDs leave out of their schema
+	if !root && node["properties"] != nil && node["additionalProperties"] == nil && node["x-kubernetes-preserve-unknown-fields"] != true {
+		out["additionalProperties"] = false
+	}
+	return out
+}
+
+// Validate checks obj, a decoded manifest, against the schema and returns one message per violation, each prefixed
+// with the path of the offending field. Unknown fields are reported too, although the API server would silently
+// drop them, since they are almost always typos.
+func (s *Schema) Validate(obj map[string]interface{}) []string {
+	var errs []string
+	if apiVersion, _ := obj["apiVersion"].(string); apiVersion != s.APIVersion() {
+		errs = append(errs, fmt.Sprintf("apiVersion: must be %s", s.APIVersion()))
+	}
+	if kind, _ := obj["kind"].(string); kind != s.Kind {
+		errs = append(errs, fmt.Sprintf("kind: must be %s", s.Kind))
+	}
+	if _, ok := obj["metadata"].(map[string]interface{}); !ok {
+		errs = append(errs, "metadata: must be an object")
+	}
+	body := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		switch k {
+		case "apiVersion", "kind", "metadata":
+		default:
+			body[k] = v
+		}
+	}
+	return validate(body, s.OpenAPIV3Schema, "", errs)
+}
+
+// validate appends the violations of value against schema, found at path, to errs
+func validate(value interface{}, schema map[string]interface{}, path string, errs []string) []string {
+	at := func(format string, args ...interface{}) []string {
+		field := path
+		if field == "" {
+			field = "<root>"
+		}
+		return append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+	if value == nil {
+		return errs
+	}
+	if schema["x-kubernetes-int-or-string"] == true {
+		if _, ok := value.(string); !ok && !isInteger(value) {
+			return at("must be an integer or a string")
+		}
+		return errs
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return at("must be an object")
+		}
+		if schema["x-kubernetes-embedded-resource"] == true || schema["x-kubernetes-preserve-unknown-fields"] == true && schema["properties"] == nil {
+			return errs
+		}
+		for _, r := range asSlice(schema["required"]) {
+			if name, _ := r.(string); name != "" {
+				if _, ok := obj[name]; !ok {
+					errs = at("missing required field %q", name)
+				}
+			}
+		}
+		properties := asMap(schema["properties"])
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := join(path, name)
+			switch {
+			case properties[name] != nil:
+				errs = validate(obj[name], asMap(properties[name]), child, errs)
+			case additional != nil:
+				errs = validate(obj[name], additional, child, errs)
+			case schema["x-kubernetes-preserve-unknown-fields"] != true:
+				errs = append(errs, child+": unknown field")
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return at("must be an array")
+		}
+		items := asMap(schema["items"])
+		for i, item := range list {
+			errs = validate(item, items, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return at("must be a string")
+		}
+		if pattern, _ := schema["pattern"].(string); pattern != "" {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(str) {
+				errs = at("must match %s", pattern)
+			}
+		}
+	case "integer":
+		if !isInteger(value) {
+			return at("must be an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok && !isInteger(value) {
+			return at("must be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return at("must be a boolean")
+		}
+	}
+	if enum := asSlice(schema["enum"]); enum != nil {
+		for _, allowed := range enum {
+			if allowed == value {
+				return errs
+			}
+		}
+		return at("must be one of %v", enum)
+	}
+	return errs
+}
+
+func isInteger(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return v == float64(int64(v))
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// AdditionalClassesAnnotation lists classes a namespace attaches on top of the one named by its class label
+const AdditionalClassesAnnotation = engine.AdditionalClassesAnnotation
+
+// AttachedAdditionalClassesAnnotation records, comma-separated, the additional classes whose resources the last
+// successful sync of a namespace applied. A class being deleted waits for the namespaces it cascades to drop it.
+const AttachedAdditionalClassesAnnotation = "namespaceclass.akuity.io/attached-additional-classes"
+
+// additionalClassIndex indexes namespaces by the classes they list in AdditionalClassesAnnotation
+const additionalClassIndex = "additionalClass"
+
+// additionalClass returns the revision of the class called name that ns attaches through
+// AdditionalClassesAnnotation. The class is held to the same rules as one attached by the class label. A class that
+// is missing or being deleted counts as detached: it returns nil, so its resources are pruned with the next sync.
+func (r *NamespaceReconciler) additionalClass(ctx context.Context, ns *corev1.Namespace, name string) (*akuityv1.NamespaceClass, error) {
+	var nsClass akuityv1.NamespaceClass
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, &nsClass); err != nil {
+		if errors.IsNotFound(err) {
+			log.FromContext(ctx).V(1).Info("Additional class not found, treating it as detached", "class", name)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !nsClass.DeletionTimestamp.IsZero() {
+		log.FromContext(ctx).V(1).Info("Additional class is being deleted, treating it as detached", "class", name)
+		return nil, nil
+	}
+	allowed, err := NamespaceAllowed(&nsClass, ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate allowedNamespaces: %w", err)
+	}
+	if !allowed {
+		return nil, fmt.Errorf("namespace is not allowed to attach NamespaceClass %s", name)
+	}
+	return r.inherit(ctx, appliedRevision(ctx, &nsClass))
+}
+
+// attachedAdditionalClasses returns the additional classes of ns that exist and are not being deleted, the ones
+// a successful sync applied
+func (r *NamespaceReconciler) attachedAdditionalClasses(ctx context.Context, ns *corev1.Namespace, primary string) ([]string, error) {
+	var attached []string
+	for _, name := range engine.AdditionalClasses(ns, primary) {
+		var nsClass akuityv1.NamespaceClass
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &nsClass); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if nsClass.DeletionTimestamp.IsZero() {
+			attached = append(attached, name)
+		}
+	}
+	return attached, nil
+}
+
+// recordAdditionalClasses sets AttachedAdditionalClassesAnnotation of ns to names, removing it when names is empty
+func (r *NamespaceReconciler) recordAdditionalClasses(ctx context.Context, ns *corev1.Namespace, names []string) error {
+	value := strings.Join(names, ",")
+	if ns.Annotations[AttachedAdditionalClassesAnnotation] == value {
+		return nil
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	if value == "" {
+		delete(ns.Annotations, AttachedAdditionalClassesAnnotation)
+	} else {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[AttachedAdditionalClassesAnnotation] = value
+	}
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		return fmt.Errorf("failed to record additional classes: %w", err)
+	}
+	return nil
+}
+
+// orphanAdditionalClasses leaves out of items, the inventory about to be pruned, the resources of additional classes
+// being deleted whose deletion policy for ns is Orphan, so they stay in place unmanaged. Their resources are found
+// by label, as the inventory does not record which class rendered an item.
+func (r *NamespaceReconciler) orphanAdditionalClasses(ctx context.Context, ns *corev1.Namespace, items []InventoryItem) ([]InventoryItem, error) {
+	for _, name := range strings.Split(ns.Annotations[AttachedAdditionalClassesAnnotation], ",") {
+		if name == "" {
+			continue
+		}
+		var nsClass akuityv1.NamespaceClass
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &nsClass); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if nsClass.DeletionTimestamp.IsZero() {
+			continue
+		}
+		policy, err := NamespaceDeletionPolicy(ns, classDeletionPolicy(&nsClass))
+		if err != nil {
+			log.FromContext(ctx).Error(err, "ignoring deletion policy override")
+		}
+		if policy != akuityv1.DeletionPolicyOrphan {
+			continue
+		}
+		kinds, err := engine.TemplateKinds(&nsClass, r.Templates)
+		if err != nil {
+			return nil, err
+		}
+		orphaned, err := r.engine.DiscoverManaged(ctx, ns.Name, name, kinds)
+		if err != nil {
+			return nil, err
+		}
+		items = slices.DeleteFunc(slices.Clone(items), func(item InventoryItem) bool {
+			return slices.ContainsFunc(orphaned, func(o InventoryItem) bool {
+				return o.Kind == item.Kind && o.Name == item.Name && o.APIVersion == item.APIVersion
+			})
+		})
+		log.FromContext(ctx).Info("Additional class is being deleted, orphaning its resources", "class", name, "resources", len(orphaned))
+	}
+	return items, nil
+}
+
+// additionalClassAttached reports whether the last successful sync of ns applied class as an additional class
+func additionalClassAttached(ns *corev1.Namespace, class string) bool {
+	return slices.Contains(strings.Split(ns.Annotations[AttachedAdditionalClassesAnnotation], ","), class)
+}
+
+// classDeletionPolicy returns the deletion policy of nsClass, Cascade unless set
+func classDeletionPolicy(nsClass *akuityv1.NamespaceClass) akuityv1.DeletionPolicy {
+	if nsClass.Spec.DeletionPolicy == "" {
+		return akuityv1.DeletionPolicyCascade
+	}
+	return nsClass.Spec.DeletionPolicy
+}
+
+// detachAdditionalClass removes class, being deleted, from the AdditionalClassesAnnotation of the namespaces that
+// list it and cascade its deletion under policy, the way the class label is removed from namespaces it is attached
+// to; NamespaceReconciler then prunes its resources
+func (r *NamespaceClassReconciler) detachAdditionalClass(ctx context.Context, class string, policy akuityv1.DeletionPolicy) error {
+	logger := log.FromContext(ctx)
+	var nsList corev1.NamespaceList
+	if err := r.List(ctx, &nsList, client.MatchingFields{additionalClassIndex: class}); err != nil {
+		return err
+	}
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		nsPolicy, err := NamespaceDeletionPolicy(ns, policy)
+		if err != nil {
+			logger.Error(err, "ignoring deletion policy override", "namespace", ns.Name)
+		}
+		if nsPolicy != akuityv1.DeletionPolicyCascade {
+			continue
+		}
+		var keep []string
+		for _, name := range strings.Split(ns.Annotations[AdditionalClassesAnnotation], ",") {
+			if name = strings.TrimSpace(name); name != "" && name != class {
+				keep = append(keep, name)
+			}
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		if len(keep) == 0 {
+			delete(ns.Annotations, AdditionalClassesAnnotation)
+		} else {
+			ns.Annotations[AdditionalClassesAnnotation] = strings.Join(keep, ",")
+		}
+		if err := r.Patch(ctx, ns, patch); err != nil {
+			return fmt.Errorf("failed to detach additional class from namespace %s: %w", ns.Name, err)
+		}
+		logger.Info("Detached additional NamespaceClass from Namespace (Cascade)", "namespace", ns.Name)
+	}
+	return nil
+}
+
+// indexByAdditionalClasses returns the additional classes a namespace lists
+func indexByAdditionalClasses(obj client.Object) []string {
+	ns := obj.(*corev1.Namespace)
+	return engine.AdditionalClasses(ns, ns.Labels[NamespaceClassLabel])
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+)
+
+const (
+	// admissionDenialThreshold is how many syncs of a namespace in a row admission control must deny before the
+	// namespace is backed off on its own schedule; single denials are often a webhook rolling out
+	admissionDenialThreshold = 3
+	// admissionBackoffBase and admissionBackoffMax bound the delay between syncs of a namespace admission control
+	// keeps denying
+	admissionBackoffBase = time.Minute
+	admissionBackoffMax  = 30 * time.Minute
+)
+
+// admissionBackoff counts the consecutive syncs of each namespace that failed on an admission denial. Requeueing
+// such namespaces with rate-limited errors would keep them cycling through the workers and the shared rate limit,
+// slowing the rollout of every other namespace for the sake of one tenant's policy.
+type admissionBackoff struct {
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// next records the outcome of a failed sync of namespace and returns how long to wait before the next one. Zero
+// means err is not a repeated admission denial and is retried like any other error.
+func (b *admissionBackoff) next(namespace string, err error) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if engine.AdmissionDenier(err) == "" {
+		delete(b.failures, namespace)
+		return 0
+	}
+	if b.failures == nil {
+		b.failures = make(map[string]int)
+	}
+	b.failures[namespace]++
+	n := b.failures[namespace]
+	if n < admissionDenialThreshold {
+		return 0
+	}
+	delay := admissionBackoffBase
+	for i := admissionDenialThreshold; i < n && delay < admissionBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, admissionBackoffMax)
+}
+
+// reset forgets the denials of namespace after it synced or went away
+func (b *admissionBackoff) reset(namespace string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, namespace)
+}
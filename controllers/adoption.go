@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReasonResourcesAdopted is the event reason used when existing resources are recorded in a namespace inventory
+// without being rewritten
+const ReasonResourcesAdopted = "ResourcesAdopted"
+
+// adoptionContext returns ctx set up to leave discovered resources alone, discovering the existing resources of a
+// namespace that predates the operator and was never synced by it when AdoptExisting is set
+func (r *NamespaceReconciler) adoptionContext(ctx context.Context, ns *corev1.Namespace, old []InventoryItem) context.Context {
+	existing := r.AdoptExisting && len(old) == 0 && ns.Annotations[AttachedClassAnnotation] == "" &&
+		ns.CreationTimestamp.Time.Before(r.startedAt)
+	if existing {
+		log.FromContext(ctx).V(1).Info("First sync of a namespace that predates the operator, adopting existing resources")
+	}
+	return engine.WithAdoption(ctx, old, existing)
+}
+
+// reportAdopted records an event for the resources a sync discovered that old did not list yet
+func (r *NamespaceReconciler) reportAdopted(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, old []InventoryItem, results []engine.ApplyResult) {
+	known := make(map[string]bool, len(old))
+	for _, item := range old {
+		known[item.Key()] = true
+	}
+	adopted := 0
+	for _, res := range results {
+		if res.Outcome == engine.OutcomeDiscovered && !known[res.Item.Key()] {
+			adopted++
+		}
+	}
+	if adopted == 0 {
+		return
+	}
+	log.FromContext(ctx).Info("Adopted existing resources", "class", nsClass.Name, "resources", adopted)
+	r.Recorder.Eventf(ns, corev1.EventTypeNormal, ReasonResourcesAdopted,
+		"Adopted %d existing resources of NamespaceClass %s without rewriting them", adopted, nsClass.Name)
+}
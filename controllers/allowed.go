@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"fmt"
+	"path"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ReasonNamespaceNotAllowed is reported when a namespace attaches a class whose allowedNamespaces excludes it
+const ReasonNamespaceNotAllowed = "NamespaceNotAllowed"
+
+// NamespaceAllowed reports whether the class may be attached to the namespace according to spec.allowedNamespaces
+func NamespaceAllowed(nsClass *akuityv1.NamespaceClass, ns *corev1.Namespace) (bool, error) {
+	allowed := nsClass.Spec.AllowedNamespaces
+	if allowed == nil {
+		return true, nil
+	}
+	for _, pattern := range allowed.Names {
+		ok, err := path.Match(pattern, ns.Name)
+		if err != nil {
+			return false, fmt.Errorf("invalid allowedNamespaces name pattern %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	if allowed.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
+		if err != nil {
+			return false, fmt.Errorf("invalid allowedNamespaces selector: %w", err)
+		}
+		if selector.Matches(labels.Set(ns.Labels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
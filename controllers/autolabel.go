@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// AutoLabeledAnnotation records the pattern that labeled a namespace at creation. A namespace carrying it is never
+// labeled again, so removing the class label afterwards sticks.
+const AutoLabeledAnnotation = "namespaceclass.akuity.io/auto-labeled"
+
+// AutoLabelRule attaches Class to new namespaces whose name matches Pattern, a shell glob such as pr-*
+type AutoLabelRule struct {
+	Pattern string
+	Class   string
+}
+
+// ParseAutoLabelRules parses comma-separated pattern=class pairs, e.g. "pr-*=ephemeral,team-*=team"
+func ParseAutoLabelRules(spec string) ([]AutoLabelRule, error) {
+	var rules []AutoLabelRule
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		pattern, class, ok := strings.Cut(pair, "=")
+		if !ok || pattern == "" || class == "" {
+			return nil, fmt.Errorf("auto-label rule %q must be pattern=class", pair)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid auto-label pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, AutoLabelRule{Pattern: pattern, Class: class})
+	}
+	return rules, nil
+}
+
+// AutoLabelReconciler labels newly created namespaces with the class of the first rule their name matches. Unlike
+// NamespaceClassPolicies it only acts once per namespace and needs no CRD, which suits ephemeral CI namespaces.
+type AutoLabelReconciler struct {
+	client.Client
+	Rules []AutoLabelRule
+}
+
+func (r *AutoLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var ns corev1.Namespace
+	if err := r.Get(ctx, req.NamespacedName, &ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !ns.DeletionTimestamp.IsZero() || ns.Labels[NamespaceClassLabel] != "" {
+		return ctrl.Result{}, nil
+	}
+	if _, done := ns.Annotations[AutoLabeledAnnotation]; done || Expired(&ns) {
+		return ctrl.Result{}, nil
+	}
+	for _, rule := range r.Rules {
+		if ok, _ := path.Match(rule.Pattern, ns.Name); !ok {
+			continue
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Labels[NamespaceClassLabel] = rule.Class
+		ns.Annotations[AutoLabeledAnnotation] = rule.Pattern
+		if err := r.Patch(ctx, &ns, patch); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to label namespace with class %s: %w", rule.Class, err)
+		}
+		log.FromContext(ctx).Info("Auto-labeled namespace", "class", rule.Class, "pattern", rule.Pattern)
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the auto-label controller with the Manager. Only creations are watched; the initial
+// list after a restart covers namespaces created while the operator was down.
+func (r *AutoLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespace-autolabel").
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.Funcs{
+			CreateFunc:  func(event.CreateEvent) bool { return true },
+			UpdateFunc:  func(event.UpdateEvent) bool { return false },
+			DeleteFunc:  func(event.DeleteEvent) bool { return false },
+			GenericFunc: func(event.GenericEvent) bool { return false },
+		})).
+		Complete(r)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// SpecHash returns a short hash of spec, stable across encodings of the same content
+func SpecHash(spec *akuityv1.NamespaceClassSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// EncodeSpec compresses spec for status.lastAppliedSpec
+func EncodeSpec(spec *akuityv1.NamespaceClassSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeSpec reverses EncodeSpec
+func DecodeSpec(encoded string) (*akuityv1.NamespaceClassSpec, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode last applied spec: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress last applied spec: %w", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress last applied spec: %w", err)
+	}
+	var spec akuityv1.NamespaceClassSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal last applied spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// SpecChanges summarizes how spec differs from base: "+Kind/name", "-Kind/name" and "~Kind/name" for added,
+// removed and modified resource templates, and "~field" for other changed spec fields
+func SpecChanges(base, spec *akuityv1.NamespaceClassSpec) ([]string, error) {
+	before, err := templatesByKey(base)
+	if err != nil {
+		return nil, err
+	}
+	after, err := templatesByKey(spec)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for key, tmpl := range after {
+		old, ok := before[key]
+		switch {
+		case !ok:
+			changes = append(changes, "+"+key)
+		case !reflect.DeepEqual(old, tmpl):
+			changes = append(changes, "~"+key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, "-"+key)
+		}
+	}
+
+	// Compare the remaining fields through their JSON form, which names them as users write them
+	var baseFields, specFields map[string]json.RawMessage
+	for _, pair := range []struct {
+		spec *akuityv1.NamespaceClassSpec
+		out  *map[string]json.RawMessage
+	}{{base, &baseFields}, {spec, &specFields}} {
+		data, err := json.Marshal(pair.spec)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, pair.out); err != nil {
+			return nil, err
+		}
+		delete(*pair.out, "resources")
+	}
+	for field := range mergeKeys(baseFields, specFields) {
+		if !bytes.Equal(baseFields[field], specFields[field]) {
+			changes = append(changes, "~"+field)
+		}
+	}
+	sort.Strings(changes)
+	return changes, nil
+}
+
+// templatesByKey keys the resource templates of spec by Kind/name
+func templatesByKey(spec *akuityv1.NamespaceClassSpec) (map[string]akuityv1.ResourceTemplate, error) {
+	nsClass := &akuityv1.NamespaceClass{Spec: *spec}
+	objects, err := engine.NewTemplateCache().DecodedTemplates(nsClass)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]akuityv1.ResourceTemplate, len(objects))
+	for i, obj := range objects {
+		if obj == nil {
+			continue
+		}
+		tmpl := spec.Resources[i]
+		// Compare decoded content so formatting differences of the raw template don't count
+		tmpl.Template.Raw, err = json.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		out[obj.GetKind()+"/"+obj.GetName()] = tmpl
+	}
+	return out, nil
+}
+
+func mergeKeys(a, b map[string]json.RawMessage) map[string]struct{} {
+	out := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		out[k] = struct{}{}
+	}
+	for k := range b {
+		out[k] = struct{}{}
+	}
+	return out
+}
+
+// updateBaseline tracks the rollout of the current generation of nsClass in status.history and stores its spec as
+// the last applied baseline, with recordSpec a compressed copy of it too, once every attached namespace synced it.
+// It reports whether the status of nsClass changed.
+func updateBaseline(ctx context.Context, c client.Reader, nsClass *akuityv1.NamespaceClass, recordSpec bool, rollouts *RolloutTracker) (bool, error) {
+	if nsClass.Status.LastAppliedGeneration == nsClass.Generation {
+		return false, nil
+	}
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList, client.MatchingLabels{NamespaceClassLabel: nsClass.Name}); err != nil {
+		return false, err
+	}
+	attached := make([]*corev1.Namespace, 0, len(nsList.Items))
+	for i := range nsList.Items {
+		if nsList.Items[i].DeletionTimestamp.IsZero() {
+			attached = append(attached, &nsList.Items[i])
+		}
+	}
+	complete := rolloutComplete(ctx, c, nsClass, attached)
+	var summary *akuityv1.RolloutSummary
+	if complete {
+		summary = rollouts.summary(nsClass.Name, nsClass.Generation)
+	}
+	historyChanged := recordRevision(&nsClass.Status, nsClass.Generation, complete, summary, failedNamespaces(attached), metav1.Now())
+	if !complete {
+		return historyChanged, nil
+	}
+	rollouts.reportRollout(ctx, nsClass, nsClass.Status.History[len(nsClass.Status.History)-1])
+
+	hash, err := SpecHash(&nsClass.Spec)
+	if err != nil {
+		return false, err
+	}
+	nsClass.Status.LastAppliedGeneration = nsClass.Generation
+	nsClass.Status.LastAppliedSpecHash = hash
+	nsClass.Status.LastAppliedSpec = ""
+	if recordSpec {
+		if nsClass.Status.LastAppliedSpec, err = EncodeSpec(&nsClass.Spec); err != nil {
+			return false, err
+		}
+	}
+	log.FromContext(ctx).Info("Recorded last applied spec", "generation", nsClass.Generation, "hash", hash)
+	return true, nil
+}
+
+// describeBaselineChanges returns a note on what changed since the last applied generation of nsClass, for
+// messages about a failing sync, or "" when there is no recorded baseline to compare with
+func describeBaselineChanges(nsClass *akuityv1.NamespaceClass) string {
+	status := nsClass.Status
+	if status.LastAppliedGeneration == 0 || status.LastAppliedGeneration == nsClass.Generation || status.LastAppliedSpec == "" {
+		return ""
+	}
+	base, err := DecodeSpec(status.LastAppliedSpec)
+	if err != nil {
+		return ""
+	}
+	changes, err := SpecChanges(base, &nsClass.Spec)
+	if err != nil || len(changes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (changed since last applied generation %d: %s)", status.LastAppliedGeneration, strings.Join(changes, ", "))
+}
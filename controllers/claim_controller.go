@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclassclaims,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclassclaims/status,verbs=update;patch
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclassclaims/finalizers,verbs=update
+
+const (
+	// ClaimedByAnnotation names the NamespaceClassClaim that set the class label of a namespace
+	ClaimedByAnnotation = "namespaceclass.akuity.io/claimed-by"
+	// ClaimValuePrefix prefixes the namespace annotations carrying the values of the bound claim
+	ClaimValuePrefix = "claim.namespaceclass.akuity.io/"
+	// ClaimFinalizer detaches the class when a bound claim is deleted
+	ClaimFinalizer = "namespaceclass.akuity.io/claim"
+)
+
+// ClaimReconciler binds NamespaceClassClaims: it labels the claim's namespace with the requested class once the
+// class admits the namespace and the claim is approved, and detaches the class when the claim goes away
+type ClaimReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Denylist names namespaces claims are always rejected in; nil denies nothing
+	Denylist *NamespaceDenylist
+}
+
+func (r *ClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var claim akuityv1.NamespaceClassClaim
+	if err := r.Get(ctx, req.NamespacedName, &claim); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var ns corev1.Namespace
+	if err := r.Get(ctx, client.ObjectKey{Name: claim.Namespace}, &ns); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !claim.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&claim, ClaimFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		// A terminating namespace takes the class resources with it
+		if ns.DeletionTimestamp.IsZero() {
+			if err := r.release(ctx, &ns, &claim); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		patch := client.MergeFrom(claim.DeepCopy())
+		controllerutil.RemoveFinalizer(&claim, ClaimFinalizer)
+		return ctrl.Result{}, r.Patch(ctx, &claim, patch)
+	}
+
+	if !ns.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&claim, ClaimFinalizer) {
+		patch := client.MergeFrom(claim.DeepCopy())
+		controllerutil.AddFinalizer(&claim, ClaimFinalizer)
+		if err := r.Patch(ctx, &claim, patch); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add claim finalizer: %w", err)
+		}
+	}
+
+	phase, message, err := r.evaluate(ctx, &ns, &claim)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if phase == akuityv1.ClaimBound {
+		if err := r.bind(ctx, &ns, &claim); err != nil {
+			// An invalid value key or a webhook denial is the claim's problem, not a transient failure
+			if !apierrors.IsInvalid(err) && !apierrors.IsForbidden(err) {
+				return ctrl.Result{}, err
+			}
+			phase, message = akuityv1.ClaimRejected, err.Error()
+		}
+	}
+	if phase != akuityv1.ClaimBound {
+		if err := r.release(ctx, &ns, &claim); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if claim.Status.Phase == phase && claim.Status.Message == message {
+		return ctrl.Result{}, nil
+	}
+	logger.Info("NamespaceClassClaim changed phase", "class", claim.Spec.Class, "phase", phase, "message", message)
+	eventType := corev1.EventTypeNormal
+	if phase == akuityv1.ClaimRejected {
+		eventType = corev1.EventTypeWarning
+	}
+	r.Recorder.Event(&claim, eventType, "Claim"+string(phase), message)
+	patch := client.MergeFrom(claim.DeepCopy())
+	claim.Status.Phase = phase
+	claim.Status.Message = message
+	return ctrl.Result{}, r.Status().Patch(ctx, &claim, patch)
+}
+
+// evaluate decides the phase of claim for ns without changing anything
+func (r *ClaimReconciler) evaluate(ctx context.Context, ns *corev1.Namespace, claim *akuityv1.NamespaceClassClaim) (akuityv1.ClaimPhase, string, error) {
+	var nsClass akuityv1.NamespaceClass
+	if err := r.Get(ctx, client.ObjectKey{Name: claim.Spec.Class}, &nsClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return akuityv1.ClaimPending, fmt.Sprintf("NamespaceClass %q does not exist", claim.Spec.Class), nil
+		}
+		return "", "", err
+	}
+	if pattern := r.Denylist.Denies(ns.Name); pattern != "" {
+		return akuityv1.ClaimRejected, fmt.Sprintf("Namespace %q matches the operator's deny pattern %s", ns.Name, pattern), nil
+	}
+	allowed, err := NamespaceAllowed(&nsClass, ns)
+	if err != nil {
+		return akuityv1.ClaimRejected, err.Error(), nil
+	}
+	if !allowed {
+		return akuityv1.ClaimRejected, fmt.Sprintf("NamespaceClass %q does not allow namespace %q", nsClass.Name, ns.Name), nil
+	}
+
+	switch {
+	case claim.Status.Approval == akuityv1.ClaimDenied:
+		return akuityv1.ClaimRejected, "Denied by an approver", nil
+	case claim.Status.Approval != akuityv1.ClaimApproved && nsClass.Spec.ClaimApproval != akuityv1.ClaimApprovalAutomatic:
+		return akuityv1.ClaimPending, fmt.Sprintf("Waiting for approval: NamespaceClass %q requires manual approval", nsClass.Name), nil
+	}
+
+	if Expired(ns) {
+		return akuityv1.ClaimRejected, fmt.Sprintf("Class attachment expired (%s); remove the %s annotation from the namespace to claim again",
+			ns.Annotations[ExpiredAnnotation], ExpiredAnnotation), nil
+	}
+	if by := ns.Annotations[ClaimedByAnnotation]; by != "" && by != claim.Name {
+		return akuityv1.ClaimRejected, fmt.Sprintf("Namespace is already claimed by %q", by), nil
+	}
+	if current := ns.Labels[NamespaceClassLabel]; current != "" && ns.Annotations[ClaimedByAnnotation] == "" {
+		return akuityv1.ClaimRejected, fmt.Sprintf("Namespace already has class %q assigned outside of a claim", current), nil
+	}
+	return akuityv1.ClaimBound, fmt.Sprintf("Namespace attached to NamespaceClass %q", nsClass.Name), nil
+}
+
+// bind labels ns with the class of claim and copies the claim values onto it
+func (r *ClaimReconciler) bind(ctx context.Context, ns *corev1.Namespace, claim *akuityv1.NamespaceClassClaim) error {
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Labels[NamespaceClassLabel] = claim.Spec.Class
+	ns.Annotations[ClaimedByAnnotation] = claim.Name
+	for k := range ns.Annotations {
+		if key, ok := strings.CutPrefix(k, ClaimValuePrefix); ok {
+			if _, keep := claim.Spec.Values[key]; !keep {
+				delete(ns.Annotations, k)
+			}
+		}
+	}
+	for k, v := range claim.Spec.Values {
+		ns.Annotations[ClaimValuePrefix+k] = v
+	}
+	return r.Patch(ctx, ns, patch)
+}
+
+// release detaches the class from ns if claim is the claim that attached it
+func (r *ClaimReconciler) release(ctx context.Context, ns *corev1.Namespace, claim *akuityv1.NamespaceClassClaim) error {
+	if ns.Annotations[ClaimedByAnnotation] != claim.Name {
+		return nil
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	delete(ns.Labels, NamespaceClassLabel)
+	for k := range ns.Annotations {
+		if k == ClaimedByAnnotation || strings.HasPrefix(k, ClaimValuePrefix) {
+			delete(ns.Annotations, k)
+		}
+	}
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		return fmt.Errorf("failed to detach class claimed by %s: %w", claim.Name, err)
+	}
+	log.FromContext(ctx).Info("Detached NamespaceClass claimed by released claim", "class", claim.Spec.Class)
+	return nil
+}
+
+// claimsForClass enqueues the claims requesting a class when it changes
+func (r *ClaimReconciler) claimsForClass(ctx context.Context, obj client.Object) []reconcile.Request {
+	var claims akuityv1.NamespaceClassClaimList
+	if err := r.List(ctx, &claims); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list claims", "class", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, claim := range claims.Items {
+		if claim.Spec.Class == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&claim)})
+		}
+	}
+	return requests
+}
+
+// claimsInNamespace enqueues the claims of a namespace when its labels change, since allowedNamespaces selectors
+// and manual labels decide whether a claim can bind
+func (r *ClaimReconciler) claimsInNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	var claims akuityv1.NamespaceClassClaimList
+	if err := r.List(ctx, &claims, client.InNamespace(obj.GetName())); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list claims", "namespace", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(claims.Items))
+	for _, claim := range claims.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&claim)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the claim controller with the Manager
+func (r *ClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor(ControllerName)
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akuityv1.NamespaceClassClaim{}).
+		Watches(&akuityv1.NamespaceClass{}, handler.EnqueueRequestsFromMapFunc(r.claimsForClass),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.claimsInNamespace),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Complete(r)
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClassReadyCondition is True on a class once every attached namespace synced it
+	ClassReadyCondition = "Ready"
+	// ClassDegradedCondition is True on a class while some of its attached namespaces fail to sync
+	ClassDegradedCondition = "Degraded"
+
+	// ReasonAllNamespacesSynced means every attached namespace synced the class
+	ReasonAllNamespacesSynced = "AllNamespacesSynced"
+	// ReasonSyncPending means some attached namespaces have not synced the class yet
+	ReasonSyncPending = "SyncPending"
+	// ReasonSyncFailing means some attached namespaces failed to sync the class
+	ReasonSyncFailing = "SyncFailing"
+	// ReasonNoSyncFailures means no attached namespace failed to sync the class
+	ReasonNoSyncFailures = "NoSyncFailures"
+)
+
+// maxListedFailures bounds how many failing namespaces the Degraded message names
+const maxListedFailures = 5
+
+// setHealthConditions sets Ready and Degraded on nsClass from its aggregated status and reports whether they
+// changed
+func setHealthConditions(nsClass *akuityv1.NamespaceClass) bool {
+	status := &nsClass.Status
+	attached, synced, failed := status.AttachedNamespaces, int32(len(status.SyncedNamespaces)), int32(len(status.FailedNamespaces))
+
+	ready := metav1.Condition{
+		Type:               ClassReadyCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: nsClass.Generation,
+		Reason:             ReasonAllNamespacesSynced,
+		Message:            fmt.Sprintf("All %d attached namespaces synced", attached),
+	}
+	switch {
+	case failed > 0:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = ReasonSyncFailing
+		ready.Message = fmt.Sprintf("%d of %d attached namespaces failed to sync", failed, attached)
+	case synced < attached:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = ReasonSyncPending
+		ready.Message = fmt.Sprintf("%d of %d attached namespaces synced", synced, attached)
+	}
+
+	degraded := metav1.Condition{
+		Type:               ClassDegradedCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: nsClass.Generation,
+		Reason:             ReasonNoSyncFailures,
+		Message:            "No attached namespace failed to sync",
+	}
+	if failed > 0 {
+		names := status.FailedNamespaces
+		if len(names) > maxListedFailures {
+			names = append(names[:maxListedFailures:maxListedFailures], fmt.Sprintf("%d more", len(names)-maxListedFailures))
+		}
+		degraded.Status = metav1.ConditionTrue
+		degraded.Reason = ReasonSyncFailing
+		degraded.Message = "Failing to sync: " + strings.Join(names, ", ")
+	}
+
+	readyChanged := meta.SetStatusCondition(&status.Conditions, ready)
+	degradedChanged := meta.SetStatusCondition(&status.Conditions, degraded)
+	return readyChanged || degradedChanged
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// TemplatesCollideCondition is set on a class whose templates define a resource another class defines too
+const TemplatesCollideCondition = "TemplatesCollide"
+
+// ReasonResourceCollision is the reason of TemplatesCollideCondition
+const ReasonResourceCollision = "ResourceCollision"
+
+// defaultCollisionInterval is how often CollisionDetector compares classes when no interval is set
+const defaultCollisionInterval = 5 * time.Minute
+
+// CollisionDetector periodically compares the templates of every class and sets TemplatesCollide on classes that
+// define a resource, by kind and name, another class defines as well. Namespaces switching between such classes, or
+// attached to both through a selector, have the resource flap between two owners. Classes in the same
+// spec.fallbackClass chain are expected to overlap and are not compared. The condition is removed once the
+// collision is resolved.
+type CollisionDetector struct {
+	Client client.Client
+	// Templates caches decoded class templates; nil decodes on every pass
+	Templates *engine.TemplateCache
+	// Interval between passes; defaults to 5m
+	Interval time.Duration
+}
+
+// NeedLeaderElection makes only the leader write class status
+func (d *CollisionDetector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable, comparing classes right away and then every Interval
+func (d *CollisionDetector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("collisions")
+	interval := d.Interval
+	if interval <= 0 {
+		interval = defaultCollisionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.detect(log.IntoContext(ctx, logger)); err != nil {
+			logger.Error(err, "failed to detect template collisions")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// detect runs one pass over all classes
+func (d *CollisionDetector) detect(ctx context.Context) error {
+	var list akuityv1.NamespaceClassList
+	if err := d.Client.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list NamespaceClasses: %w", err)
+	}
+	classes := make(map[string]*akuityv1.NamespaceClass, len(list.Items))
+	for i := range list.Items {
+		classes[list.Items[i].Name] = &list.Items[i]
+	}
+
+	identities := make(map[string][]engine.ResourceIdentity, len(classes))
+	// owners maps each resource identity to the classes defining it
+	owners := make(map[string][]string)
+	for name, nsClass := range classes {
+		ids, err := engine.ClassIdentities(nsClass, d.Templates)
+		if err != nil {
+			// The class fails its own syncs with the decoding error; it has nothing to compare
+			continue
+		}
+		identities[name] = ids
+		for _, id := range ids {
+			if !slices.Contains(owners[id.Key()], name) {
+				owners[id.Key()] = append(owners[id.Key()], name)
+			}
+		}
+	}
+
+	for name, nsClass := range classes {
+		var collisions []string
+		for _, id := range identities[name] {
+			var others []string
+			for _, other := range owners[id.Key()] {
+				if other != name && !inFallbackChain(classes, name, other) && !inFallbackChain(classes, other, name) {
+					others = append(others, other)
+				}
+			}
+			if len(others) > 0 {
+				slices.Sort(others)
+				collisions = append(collisions, fmt.Sprintf("%s (also in %s)", id, strings.Join(others, ", ")))
+			}
+		}
+		if err := d.setCondition(ctx, nsClass, collisions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCondition sets TemplatesCollide on nsClass for the given collisions, or removes it when there are none,
+// patching the status only when it changes
+func (d *CollisionDetector) setCondition(ctx context.Context, nsClass *akuityv1.NamespaceClass, collisions []string) error {
+	patch := client.MergeFrom(nsClass.DeepCopy())
+	var changed bool
+	if len(collisions) == 0 {
+		changed = meta.RemoveStatusCondition(&nsClass.Status.Conditions, TemplatesCollideCondition)
+	} else {
+		slices.Sort(collisions)
+		changed = meta.SetStatusCondition(&nsClass.Status.Conditions, metav1.Condition{
+			Type:               TemplatesCollideCondition,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: nsClass.Generation,
+			Reason:             ReasonResourceCollision,
+			Message:            "Resources defined by other classes too: " + strings.Join(collisions, "; "),
+		})
+	}
+	if !changed {
+		return nil
+	}
+	if err := d.Client.Status().Patch(ctx, nsClass, patch); err != nil {
+		return fmt.Errorf("failed to update status of class %s: %w", nsClass.Name, err)
+	}
+	log.FromContext(ctx).Info("Updated template collisions", "class", nsClass.Name, "collisions", len(collisions))
+	return nil
+}
+
+// inFallbackChain reports whether other is reachable from the spec.fallbackClass of the class named name
+func inFallbackChain(classes map[string]*akuityv1.NamespaceClass, name, other string) bool {
+	nsClass := classes[name]
+	for depth := 0; nsClass != nil && depth < maxFallbackDepth; depth++ {
+		next := nsClass.Spec.FallbackClass
+		if next == "" {
+			return false
+		}
+		if next == other {
+			return true
+		}
+		nsClass = classes[next]
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var reconcileWorkers = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "namespaceclass_reconcile_workers",
+		Help: "Reconciles a controller currently allows to run at once under adaptive concurrency",
+	},
+	[]string{"controller"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileWorkers)
+}
+
+// AdaptiveConcurrency scales the number of reconciles a controller runs at once between Min and Max with its
+// backlog: one worker per QueuePerWorker requests that are queued, waiting for a slot or running. The controller is
+// started with Max workers and the surplus waits for a slot, so an idle operator keeps few requests in flight against
+// the API server while a backlog after a restart or class change is drained at full speed.
+type AdaptiveConcurrency struct {
+	// Name labels the namespaceclass_reconcile_workers gauge
+	Name string
+	Min  int
+	Max  int
+	// QueuePerWorker is the backlog that warrants one more worker; defaults to 10
+	QueuePerWorker int
+	// Interval is how often the limit is recomputed; defaults to 5s
+	Interval time.Duration
+
+	// newBase builds the queue NewQueue observes; nil means the default rate limited queue
+	newBase newQueueFunc
+
+	mu sync.Mutex
+	// wake is closed, and replaced, whenever a slot may have freed up
+	wake    chan struct{}
+	limit   int
+	running int
+	// waiting counts requests a worker took off the queue that wait in acquire for a slot
+	waiting int
+	queue   workqueue.TypedRateLimitingInterface[reconcile.Request]
+}
+
+// NewQueue builds the controller's queue, by default a rate limited one, and keeps it to observe its depth; set it
+// as controller.Options.NewQueue
+func (a *AdaptiveConcurrency) NewQueue(name string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+	var q workqueue.TypedRateLimitingInterface[reconcile.Request]
+	if a.newBase != nil {
+		q = a.newBase(name, rateLimiter)
+	} else {
+		q = workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter, workqueue.TypedRateLimitingQueueConfig[reconcile.Request]{Name: name})
+	}
+	a.mu.Lock()
+	a.queue = q
+	a.mu.Unlock()
+	return q
+}
+
+// Wrap limits the reconciles of r to the current worker count
+func (a *AdaptiveConcurrency) Wrap(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if err := a.acquire(ctx); err != nil {
+			return reconcile.Result{}, err
+		}
+		defer a.release()
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// NeedLeaderElection matches the controllers, which only run on the leader
+func (a *AdaptiveConcurrency) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable, recomputing the limit every Interval
+func (a *AdaptiveConcurrency) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("adaptive-concurrency").WithValues("controller", a.Name)
+	interval := a.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if prev, limit := a.resize(); prev != limit {
+			logger.V(1).Info("Adjusted reconcile workers", "from", prev, "to", limit)
+		}
+	}
+}
+
+// resize sets the limit from the current backlog and returns the previous and new limit. Workers take requests off
+// the queue before waiting for a slot, so those waiting and running count alongside the queue length.
+func (a *AdaptiveConcurrency) resize() (int, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	depth := a.waiting + a.running
+	if a.queue != nil {
+		depth += a.queue.Len()
+	}
+	perWorker := a.QueuePerWorker
+	if perWorker <= 0 {
+		perWorker = 10
+	}
+	limit := min(max((depth+perWorker-1)/perWorker, a.Min, 1), max(a.Max, 1))
+	prev := a.limit
+	a.limit = limit
+	reconcileWorkers.WithLabelValues(a.Name).Set(float64(limit))
+	if limit > prev {
+		a.broadcast()
+	}
+	return prev, limit
+}
+
+// acquire waits for a free slot, or returns the error of ctx once it is done, e.g. on manager shutdown
+func (a *AdaptiveConcurrency) acquire(ctx context.Context) error {
+	waiting := false
+	for {
+		a.mu.Lock()
+		a.init()
+		if a.running < a.limit {
+			a.running++
+			if waiting {
+				a.waiting--
+			}
+			a.mu.Unlock()
+			return nil
+		}
+		if !waiting {
+			a.waiting++
+			waiting = true
+		}
+		wake := a.wake
+		a.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			a.mu.Lock()
+			a.waiting--
+			a.mu.Unlock()
+			return ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+func (a *AdaptiveConcurrency) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running--
+	a.broadcast()
+}
+
+// broadcast wakes every waiting acquire to re-check the limit; callers hold mu
+func (a *AdaptiveConcurrency) broadcast() {
+	close(a.wake)
+	a.wake = make(chan struct{})
+}
+
+// init starts at Min workers until the first resize; callers hold mu
+func (a *AdaptiveConcurrency) init() {
+	if a.wake != nil {
+		return
+	}
+	a.wake = make(chan struct{})
+	a.limit = max(a.Min, 1)
+	reconcileWorkers.WithLabelValues(a.Name).Set(float64(a.limit))
+}
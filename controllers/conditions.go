@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespaceClassSyncedCondition is set on the Namespace status to report whether its class has been applied
+const NamespaceClassSyncedCondition corev1.NamespaceConditionType = "NamespaceClassSynced"
+
+// Condition and event reasons
+const (
+	ReasonSynced       = "Synced"
+	ReasonClassMissing = "ClassMissing"
+	ReasonApplied      = "Applied"
+)
+
+// setSyncedCondition records the NamespaceClassSynced condition, patching the Namespace status only when it changes
+func (r *NamespaceReconciler) setSyncedCondition(ctx context.Context, ns *corev1.Namespace, status corev1.ConditionStatus, reason, message string) error {
+	return r.setCondition(ctx, ns, NamespaceClassSyncedCondition, status, reason, message)
+}
+
+// removeSyncedCondition drops the NamespaceClassSynced condition once the Namespace no longer references a class
+func (r *NamespaceReconciler) removeSyncedCondition(ctx context.Context, ns *corev1.Namespace) error {
+	return r.removeCondition(ctx, ns, NamespaceClassSyncedCondition)
+}
+
+// setCondition records a condition of condType, patching the Namespace status only when it changes
+func (r *NamespaceReconciler) setCondition(ctx context.Context, ns *corev1.Namespace, condType corev1.NamespaceConditionType, status corev1.ConditionStatus, reason, message string) error {
+	cond := corev1.NamespaceCondition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	conditions := make([]corev1.NamespaceCondition, 0, len(ns.Status.Conditions)+1)
+	for _, c := range ns.Status.Conditions {
+		if c.Type != condType {
+			conditions = append(conditions, c)
+			continue
+		}
+		if c.Status == status && c.Reason == reason && c.Message == message {
+			return nil
+		}
+		if c.Status == status {
+			cond.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	patch := client.MergeFrom(ns.DeepCopy())
+	ns.Status.Conditions = append(conditions, cond)
+	return r.Status().Patch(ctx, ns, patch)
+}
+
+// removeCondition drops the condition of condType, patching the Namespace status only when it was set
+func (r *NamespaceReconciler) removeCondition(ctx context.Context, ns *corev1.Namespace, condType corev1.NamespaceConditionType) error {
+	conditions := make([]corev1.NamespaceCondition, 0, len(ns.Status.Conditions))
+	for _, c := range ns.Status.Conditions {
+		if c.Type != condType {
+			conditions = append(conditions, c)
+		}
+	}
+	if len(conditions) == len(ns.Status.Conditions) {
+		return nil
+	}
+
+	patch := client.MergeFrom(ns.DeepCopy())
+	ns.Status.Conditions = conditions
+	return r.Status().Patch(ctx, ns, patch)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// CRDTerminatingCondition is set on a Namespace while resources of its class are not applied because the
+// CustomResourceDefinition of their kind is being deleted, and removed once every resource applies again
+const CRDTerminatingCondition corev1.NamespaceConditionType = "NamespaceClassCRDTerminating"
+
+// ReasonCRDTerminating is the condition and event reason of resources blocked by a terminating
+// CustomResourceDefinition
+const ReasonCRDTerminating = "CRDTerminating"
+
+// crdTerminatingRequeue is how often a namespace with blocked resources is synced again, to apply them once the
+// CustomResourceDefinition is recreated or to fail plainly once it is gone
+const crdTerminatingRequeue = time.Minute
+
+// reportBlocked sets CRDTerminatingCondition on ns for the resources the last apply left alone because their
+// CustomResourceDefinition is being deleted, or removes it when there are none
+func (r *NamespaceReconciler) reportBlocked(ctx context.Context, ns *corev1.Namespace, class string, blocked []InventoryItem) {
+	var err error
+	if len(blocked) == 0 {
+		err = r.removeCondition(ctx, ns, CRDTerminatingCondition)
+	} else {
+		names := make([]string, 0, len(blocked))
+		for _, item := range blocked {
+			names = append(names, item.Kind+"/"+item.Name)
+		}
+		message := fmt.Sprintf("Not applying %s of NamespaceClass %s: the CustomResourceDefinition of their kind is being deleted",
+			strings.Join(names, ", "), class)
+		if cond := namespaceCondition(ns, CRDTerminatingCondition); cond == nil || cond.Message != message {
+			log.FromContext(ctx).Info("Resources are blocked by a terminating CustomResourceDefinition", "resources", names)
+			r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonCRDTerminating, message)
+		}
+		err = r.setCondition(ctx, ns, CRDTerminatingCondition, corev1.ConditionTrue, ReasonCRDTerminating, message)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to update CRD terminating condition")
+	}
+}
+
+// blockedRequeue returns when a namespace whose inventory holds blocked resources is synced again, zero when it
+// holds none
+func blockedRequeue(items []InventoryItem) time.Duration {
+	for _, item := range items {
+		if item.Blocked {
+			return crdTerminatingRequeue
+		}
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DeletionPolicyAnnotation overrides, for one namespace, what happens to its resources when its class is deleted or
+// detached: Orphan keeps them in place, Cascade removes them
+const DeletionPolicyAnnotation = "namespaceclass.akuity.io/deletion-policy"
+
+// NamespaceDeletionPolicy returns the deletion policy that applies to ns, falling back to fallback when the
+// namespace does not override it
+func NamespaceDeletionPolicy(ns *corev1.Namespace, fallback akuityv1.DeletionPolicy) (akuityv1.DeletionPolicy, error) {
+	raw, ok := ns.GetAnnotations()[DeletionPolicyAnnotation]
+	if !ok {
+		return fallback, nil
+	}
+	switch policy := akuityv1.DeletionPolicy(raw); policy {
+	case akuityv1.DeletionPolicyCascade, akuityv1.DeletionPolicyOrphan:
+		return policy, nil
+	}
+	return fallback, fmt.Errorf("invalid %s annotation %q, expected %s or %s", DeletionPolicyAnnotation, raw,
+		akuityv1.DeletionPolicyCascade, akuityv1.DeletionPolicyOrphan)
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AllowDeletionAnnotation must be set to the class name before a class with spec.deletionProtection can be deleted.
+// On a Namespace, set to the namespace name, it overrides the protection of the resources in it.
+const AllowDeletionAnnotation = "namespaceclass.akuity.io/allow-deletion"
+
+// ReasonDeletionProtected is the event reason used when a protected class is held back from deletion
+const ReasonDeletionProtected = "DeletionProtected"
+
+// DeletionAllowed reports whether nsClass may be deleted under its deletion protection
+func DeletionAllowed(nsClass *akuityv1.NamespaceClass) bool {
+	return !nsClass.Spec.DeletionProtection || nsClass.Annotations[AllowDeletionAnnotation] == nsClass.Name
+}
+
+// ProtectedResources returns the inventory items of ns whose templates set deletionProtection
+func ProtectedResources(ctx context.Context, c client.Reader, ns *corev1.Namespace) ([]InventoryItem, error) {
+	items, err := engine.ReadInventory(ctx, c, ns)
+	if err != nil {
+		return nil, err
+	}
+	var protected []InventoryItem
+	for _, item := range items {
+		if item.Protected {
+			protected = append(protected, item)
+		}
+	}
+	return protected, nil
+}
+
+// NamespaceDeletionAllowed reports whether ns carries the override that allows deleting its protected resources
+func NamespaceDeletionAllowed(ns *corev1.Namespace) bool {
+	return ns.Annotations[AllowDeletionAnnotation] == ns.Name
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ReasonNamespaceDenied is reported when a class is attached to a namespace on the operator's deny list
+const ReasonNamespaceDenied = "NamespaceDenied"
+
+// WellKnownDenyEntry in a deny list stands for WellKnownDeniedNamespaces
+const WellKnownDenyEntry = "well-known"
+
+// WellKnownDeniedNamespaces are the system namespaces of Kubernetes and common distributions, which no class should
+// be attached to
+var WellKnownDeniedNamespaces = []string{
+	"kube-system", "kube-public", "kube-node-lease",
+	"openshift", "openshift-*",
+	"cattle-*", "fleet-*", "rancher-*",
+	"gke-*", "gmp-*", "azure-*", "amazon-*",
+}
+
+// NamespaceDenylist matches the names of namespaces the operator never attaches a class to, whatever labels,
+// policies or claims say. A nil NamespaceDenylist denies nothing.
+type NamespaceDenylist struct {
+	globs   []string
+	regexes []*regexp.Regexp
+}
+
+// ParseNamespaceDenylist compiles deny list entries. An entry is a glob pattern such as "openshift-*", a regular
+// expression between slashes such as "/^team-[0-9]+-legacy$/", or WellKnownDenyEntry.
+func ParseNamespaceDenylist(entries []string) (*NamespaceDenylist, error) {
+	d := &NamespaceDenylist{}
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if entry == WellKnownDenyEntry {
+			d.globs = append(d.globs, WellKnownDeniedNamespaces...)
+			continue
+		}
+		if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+			re, err := regexp.Compile(entry[1 : len(entry)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid namespace deny pattern %s: %w", entry, err)
+			}
+			d.regexes = append(d.regexes, re)
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("invalid namespace deny pattern %q: %w", entry, err)
+		}
+		d.globs = append(d.globs, entry)
+	}
+	return d, nil
+}
+
+// ReadNamespaceDenylist reads deny list entries from a file with one entry per line, such as a mounted ConfigMap
+// key. Blank lines and lines starting with # are ignored.
+func ReadNamespaceDenylist(file string) ([]string, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace deny list: %w", err)
+	}
+	var entries []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	return entries, nil
+}
+
+// Denies returns the pattern that denies the namespace called name, or "" if none does
+func (d *NamespaceDenylist) Denies(name string) string {
+	if d == nil {
+		return ""
+	}
+	for _, glob := range d.globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return glob
+		}
+	}
+	for _, re := range d.regexes {
+		if re.MatchString(name) {
+			return "/" + re.String() + "/"
+		}
+	}
+	return ""
+}
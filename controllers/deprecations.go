@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeprecatedAPIsCondition is set on a class whose templates use API versions Kubernetes removes
+const DeprecatedAPIsCondition = "DeprecatedAPIs"
+
+const (
+	// ReasonAPIDeprecated means the templates use APIs a later Kubernetes version removes
+	ReasonAPIDeprecated = "APIDeprecated"
+	// ReasonAPIRemoved means the templates use APIs the cluster no longer serves, so every sync fails
+	ReasonAPIRemoved = "APIRemoved"
+)
+
+// syncDeprecations sets DeprecatedAPIsCondition while templates of nsClass use removed APIs, so classes get fixed
+// before an upgrade breaks their rollout, and emits a Warning event whenever the findings change
+func (r *NamespaceClassReconciler) syncDeprecations(ctx context.Context, nsClass *akuityv1.NamespaceClass) error {
+	findings, removed := engine.DeprecatedTemplates(nsClass, r.KubernetesMinor)
+	patch := client.MergeFrom(nsClass.DeepCopy())
+	var changed bool
+	if len(findings) == 0 {
+		changed = meta.RemoveStatusCondition(&nsClass.Status.Conditions, DeprecatedAPIsCondition)
+	} else {
+		reason := ReasonAPIDeprecated
+		if removed {
+			reason = ReasonAPIRemoved
+		}
+		message := strings.Join(findings, "; ")
+		changed = meta.SetStatusCondition(&nsClass.Status.Conditions, metav1.Condition{
+			Type:               DeprecatedAPIsCondition,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: nsClass.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+		if changed {
+			r.Recorder.Event(nsClass, corev1.EventTypeWarning, reason, message)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := r.Status().Patch(ctx, nsClass, patch); err != nil {
+		return fmt.Errorf("failed to update deprecated API condition: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DriftDetectedAnnotation records on a Namespace when the controller last reverted out-of-band changes to one of
+// its managed resources, as an RFC 3339 timestamp
+const DriftDetectedAnnotation = "namespaceclass.akuity.io/drift-detected"
+
+// ReasonDriftDetected is the event reason used when managed resources had drifted from their templates
+const ReasonDriftDetected = "DriftDetected"
+
+// countDrift returns how many resources had to be re-written although neither the class generation nor the
+// rendered object changed since the last sync, i.e. the live object was modified by someone else. Only meaningful
+// when unchanged applies are skipped; otherwise every apply is a write.
+func countDrift(old []InventoryItem, results []engine.ApplyResult) int {
+	previous := make(map[string]InventoryItem, len(old))
+	for _, item := range old {
+		previous[item.Key()] = item
+	}
+	drifted := 0
+	for _, res := range results {
+		if res.Outcome != engine.OutcomeApplied {
+			continue
+		}
+		prev, ok := previous[res.Item.Key()]
+		if ok && prev.Hash != "" && prev.Hash == res.Item.Hash && prev.Generation == res.Item.Generation {
+			drifted++
+		}
+	}
+	return drifted
+}
+
+// markDrift reports reverted drift with a Warning event and stamps the namespace with DriftDetectedAnnotation
+func (r *NamespaceReconciler) markDrift(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, drifted int) {
+	r.Recorder.Eventf(ns, corev1.EventTypeWarning, ReasonDriftDetected,
+		"Reverted out-of-band changes to %d resources managed by NamespaceClass %s", drifted, nsClass.Name)
+	driftCorrectionsTotal.WithLabelValues(ns.Name, nsClass.Name).Add(float64(drifted))
+
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[DriftDetectedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		log.FromContext(ctx).Error(err, "failed to record drift on namespace")
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DryRunAnnotation set to "true" on a Namespace previews its class instead of applying it: every resource is sent
+// as a server-side dry run and the would-be changes are reported, but nothing in the namespace, including its
+// inventory, is written
+const DryRunAnnotation = "namespaceclass.akuity.io/dry-run"
+
+// ReasonDryRun is the condition and event reason of a previewed sync
+const ReasonDryRun = "DryRun"
+
+// maxDryRunListed bounds how many resources the dry run report names
+const maxDryRunListed = 10
+
+// dryRunRequested reports whether ns asks for its class to be previewed only
+func dryRunRequested(ns *corev1.Namespace) bool {
+	return ns.Annotations[DryRunAnnotation] == "true"
+}
+
+// dryRun previews syncing ns with nsClass: it dry-runs every rendered resource, works out which inventory entries
+// would be pruned and reports both through a DryRun event and the NamespaceClassSynced condition, whose status stays
+// Unknown since the class is not applied
+func (r *NamespaceReconciler) dryRun(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, old []InventoryItem) error {
+	results, err := r.engine.DryRun(ctx, ns, nsClass)
+	if err != nil {
+		return r.failSync(ctx, ns, "dry-run", "Dry run failed", err)
+	}
+	summary := summarizeApply(old, results)
+	var changes []string
+	rendered := make(map[string]bool, len(results))
+	for _, res := range results {
+		rendered[res.Item.Key()] = true
+		if res.Outcome == engine.OutcomeApplied {
+			changes = append(changes, fmt.Sprintf("%s/%s", res.Item.Kind, res.Item.Name))
+		}
+	}
+	for _, item := range old {
+		if !item.Adopted && !rendered[item.Key()] {
+			summary.pruned++
+			changes = append(changes, fmt.Sprintf("-%s/%s", item.Kind, item.Name))
+		}
+	}
+
+	message := fmt.Sprintf("Dry run of NamespaceClass %s generation %d: %s", nsClass.Name, nsClass.Generation, summary)
+	if len(changes) > maxDryRunListed {
+		changes = append(changes[:maxDryRunListed], fmt.Sprintf("and %d more", len(changes)-maxDryRunListed))
+	}
+	if len(changes) > 0 {
+		message += " (" + strings.Join(changes, ", ") + ")"
+	}
+	log.FromContext(ctx).Info("Previewed class", "class", nsClass.Name, "applied", summary.applied, "changed", summary.changed, "pruned", summary.pruned)
+	// Namespace and class events re-run the preview; only a different outcome is worth another event
+	if cond := syncedCondition(ns); cond == nil || cond.Reason != ReasonDryRun || cond.Message != message {
+		r.Recorder.Event(ns, corev1.EventTypeNormal, ReasonDryRun, message)
+	}
+	return r.setSyncedCondition(ctx, ns, corev1.ConditionUnknown, ReasonDryRun, message)
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExclusionStatus writes status.excludedNamespaces of every class. Policies and selectors each report the
+// namespaces that opted out of a class they would attach with ExcludeAnnotation, and the class lists the union, so
+// neither controller overwrites what the other recorded.
+type ExclusionStatus struct {
+	Client client.Client
+
+	mu      sync.Mutex
+	sources map[string]map[string][]string
+}
+
+// Record replaces the exclusions reported by source, keyed by class name, and updates the status of every class
+// whose merged list changed
+func (s *ExclusionStatus) Record(ctx context.Context, source string, excluded map[string][]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sources == nil {
+		s.sources = map[string]map[string][]string{}
+	}
+	s.sources[source] = excluded
+
+	var classList akuityv1.NamespaceClassList
+	if err := s.Client.List(ctx, &classList); err != nil {
+		return err
+	}
+	for i := range classList.Items {
+		nsClass := &classList.Items[i]
+		var names []string
+		for _, bySource := range s.sources {
+			names = append(names, bySource[nsClass.Name]...)
+		}
+		sort.Strings(names)
+		names = slices.Compact(names)
+		if slices.Equal(names, nsClass.Status.ExcludedNamespaces) {
+			continue
+		}
+		patch := client.MergeFrom(nsClass.DeepCopy())
+		nsClass.Status.ExcludedNamespaces = names
+		if err := s.Client.Status().Patch(ctx, nsClass, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
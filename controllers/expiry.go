@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ExpiresAtAnnotation on a namespace holds an RFC 3339 time after which its class is detached and its resources
+	// cleaned up, for temporary attachments such as a debugging class with extra RBAC
+	ExpiresAtAnnotation = "namespaceclass.akuity.io/expires-at"
+	// ExpiredAnnotation replaces ExpiresAtAnnotation once the class was detached, recording the class and when;
+	// policies, auto-labeling and claims leave such namespaces alone
+	ExpiredAnnotation = "namespaceclass.akuity.io/expired"
+	// ReasonAttachmentExpired is the event reason used when an expired class is detached
+	ReasonAttachmentExpired = "AttachmentExpired"
+	// ReasonInvalidExpiry is the event reason used when ExpiresAtAnnotation cannot be parsed
+	ReasonInvalidExpiry = "InvalidExpiry"
+)
+
+// attachmentExpiry returns how long the class attachment of ns has left, and whether it has an expiry at all.
+// An unparsable annotation is reported and ignored.
+func (r *NamespaceReconciler) attachmentExpiry(ns *corev1.Namespace, now time.Time) (time.Duration, bool) {
+	value, ok := ns.Annotations[ExpiresAtAnnotation]
+	if !ok {
+		return 0, false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		r.Recorder.Eventf(ns, corev1.EventTypeWarning, ReasonInvalidExpiry, "Ignoring %s=%q: %v", ExpiresAtAnnotation, value, err)
+		return 0, false
+	}
+	return expiresAt.Sub(now), true
+}
+
+// detachExpired removes the class label of ns and records the expiry; the label change triggers the clean-up
+func (r *NamespaceReconciler) detachExpired(ctx context.Context, ns *corev1.Namespace, className string) error {
+	expiresAt := ns.Annotations[ExpiresAtAnnotation]
+	patch := client.MergeFrom(ns.DeepCopy())
+	delete(ns.Labels, NamespaceClassLabel)
+	delete(ns.Annotations, ExpiresAtAnnotation)
+	ns.Annotations[ExpiredAnnotation] = fmt.Sprintf("%s@%s", className, expiresAt)
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		return fmt.Errorf("failed to detach expired class: %w", err)
+	}
+	log.FromContext(ctx).Info("Detached expired NamespaceClass", "class", className, "expiresAt", expiresAt)
+	r.Recorder.Eventf(ns, corev1.EventTypeNormal, ReasonAttachmentExpired, "NamespaceClass %s detached: attachment expired at %s", className, expiresAt)
+	return nil
+}
+
+// Expired reports whether the class attachment of ns expired, which keeps automation from re-attaching a class
+func Expired(ns *corev1.Namespace) bool {
+	_, ok := ns.Annotations[ExpiredAnnotation]
+	return ok
+}
+
+// sooner returns the smaller positive duration of a and b, or zero when neither is positive
+func sooner(a, b time.Duration) time.Duration {
+	switch {
+	case a <= 0:
+		return max(b, 0)
+	case b <= 0:
+		return a
+	}
+	return min(a, b)
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReasonFallbackApplied is reported while a namespace runs on a fallback class because its own class failed to render
+const ReasonFallbackApplied = "FallbackApplied"
+
+const (
+	// maxFallbackDepth bounds how many spec.fallbackClass links are followed
+	maxFallbackDepth = 5
+	// fallbackRetryInterval is how soon a namespace on a fallback class retries its own class
+	fallbackRetryInterval = time.Minute
+)
+
+// applyFallback follows the spec.fallbackClass chain of nsClass after it failed to render with cause, applying the
+// first fallback that renders in place of the class and pruning what the fallback does not keep. The inventory stays
+// recorded under the attached class so its next successful sync prunes the fallback resources. It reports false when
+// no fallback could be applied, leaving the caller to fail the sync as usual.
+func (r *NamespaceReconciler) applyFallback(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, oldInventory []InventoryItem, cause error) (ctrl.Result, bool, error) {
+	logger := log.FromContext(ctx)
+	className := nsClass.Name
+	seen := map[string]bool{className: true}
+	next := nsClass.Spec.FallbackClass
+	for depth := 0; next != "" && depth < maxFallbackDepth; depth++ {
+		if seen[next] {
+			logger.Info("Ignoring fallback class that loops back", "fallback", next)
+			return ctrl.Result{}, false, nil
+		}
+		seen[next] = true
+
+		var fallback akuityv1.NamespaceClass
+		if err := r.Get(ctx, types.NamespacedName{Name: next}, &fallback); err != nil {
+			logger.Error(err, "failed to get fallback class", "fallback", next)
+			return ctrl.Result{}, false, nil
+		}
+		if allowed, err := NamespaceAllowed(&fallback, ns); err != nil || !allowed {
+			logger.Info("Fallback class is not allowed in namespace", "fallback", next)
+			return ctrl.Result{}, false, nil
+		}
+
+		fallbackRevision, err := r.inherit(ctx, appliedRevision(ctx, &fallback))
+		if err != nil {
+			logger.Info("Fallback class failed to resolve its inherited classes", "fallback", next, "error", err.Error())
+			next = fallback.Spec.FallbackClass
+			continue
+		}
+		applied, summary, err := r.applyClassResources(ctx, ns, fallbackRevision, oldInventory)
+		if engine.IsNamespaceTerminating(err) {
+			r.forgetTerminating(ns.Name)
+			return ctrl.Result{}, true, nil
+		}
+		if err != nil {
+			if engine.IsRenderFailure(err) {
+				logger.Info("Fallback class failed to render", "fallback", next, "error", err.Error())
+				next = fallback.Spec.FallbackClass
+				continue
+			}
+			logger.Error(err, "failed to apply fallback class", "fallback", next)
+			summary.failed++
+			r.reportSummary(ctx, ns, className, 0, summary)
+			if merged := engine.MergePartial(oldInventory, applied); len(merged) > len(oldInventory) {
+				if err := r.setNamespaceInventory(ctx, ns, className, merged); err != nil {
+					logger.Error(err, "failed to record partially applied resources")
+				}
+			}
+			return ctrl.Result{}, false, nil
+		}
+		applied = engine.CarryOverAdopted(oldInventory, applied)
+		applied = engine.CarryOverCreated(oldInventory, applied, metav1.Now())
+
+		pruned, err := r.pruneOrphanedResources(ctx, oldInventory, applied, className)
+		summary.pruned += pruned
+		if err != nil {
+			summary.failed++
+			r.reportSummary(ctx, ns, className, 0, summary)
+			return ctrl.Result{}, true, r.failSync(ctx, ns, "prune", "Failed to prune resources after applying fallback "+next, err)
+		}
+		r.reportSummary(ctx, ns, className, 0, summary)
+		if err := r.setNamespaceInventory(ctx, ns, className, applied); err != nil {
+			return ctrl.Result{}, true, r.failSync(ctx, ns, "persist-inventory", "Failed to persist inventory", err)
+		}
+
+		r.recordError(ns, "apply-resources", cause)
+		message := fmt.Sprintf("NamespaceClass %s failed to render, applied fallback %s: %v", className, next, cause)
+		r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonFallbackApplied, message)
+		if err := r.setSyncedCondition(ctx, ns, corev1.ConditionFalse, ReasonFallbackApplied, message); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		return ctrl.Result{RequeueAfter: fallbackRetryInterval}, true, nil
+	}
+	return ctrl.Result{}, false, nil
+}
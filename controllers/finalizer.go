@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// finalizeNamespace deletes the inventory resources of a terminating namespace that garbage collection leaves
+// behind, unless the namespace orphans its resources, and then releases engine.CleanupFinalizer along with the
+// inventory
+func (r *NamespaceReconciler) finalizeNamespace(ctx context.Context, ns *corev1.Namespace) error {
+	logger := log.FromContext(ctx)
+	items, err := r.getNamespaceInventory(ctx, ns)
+	if err != nil {
+		return err
+	}
+	policy, err := NamespaceDeletionPolicy(ns, akuityv1.DeletionPolicyCascade)
+	if err != nil {
+		logger.Error(err, "ignoring deletion policy override")
+	}
+	if policy == akuityv1.DeletionPolicyCascade {
+		var unowned []InventoryItem
+		for _, item := range items {
+			if engine.NeedsCleanup(item) {
+				unowned = append(unowned, item)
+			}
+		}
+		logger.Info("Namespace is being deleted, cleaning up unowned resources", "resources", len(unowned))
+		if _, err := r.pruneOrphanedResources(ctx, unowned, nil, ns.Annotations[AttachedClassAnnotation]); err != nil {
+			r.recordError(ns, "cleanup", err)
+			r.recordStuck(ctx, ns, err)
+			return err
+		}
+	}
+	if err := r.setNamespaceInventory(ctx, ns, "", nil); err != nil {
+		r.reportBlockedCleanup(ns)
+		return err
+	}
+	forgetBlockedCleanup(ns.Name)
+	return nil
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReasonFrozen is the event reason used when a class is frozen at a generation
+const ReasonFrozen = "Frozen"
+
+// appliedRevision returns the class to render for namespaces: nsClass itself, or while it is frozen a copy
+// carrying the frozen spec and generation
+func appliedRevision(ctx context.Context, nsClass *akuityv1.NamespaceClass) *akuityv1.NamespaceClass {
+	if nsClass.Spec.UpdatePolicy != akuityv1.ClassUpdatePolicyFrozen || nsClass.Status.FrozenSpec == "" {
+		return nsClass
+	}
+	spec, err := DecodeSpec(nsClass.Status.FrozenSpec)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "ignoring frozen spec", "class", nsClass.Name)
+		return nsClass
+	}
+	frozen := nsClass.DeepCopy()
+	frozen.Spec = *spec
+	frozen.Generation = nsClass.Status.FrozenGeneration
+	return frozen
+}
+
+// syncFreeze snapshots the applied revision into status when the class becomes frozen and drops the snapshot
+// once it is unfrozen. The snapshot is the last fully rolled out spec when one is recorded, otherwise the spec
+// at the time of freezing.
+func (r *NamespaceClassReconciler) syncFreeze(ctx context.Context, nsClass *akuityv1.NamespaceClass) error {
+	frozen := nsClass.Spec.UpdatePolicy == akuityv1.ClassUpdatePolicyFrozen
+	if frozen == (nsClass.Status.FrozenSpec != "") {
+		return nil
+	}
+
+	patch := client.MergeFrom(nsClass.DeepCopy())
+	if !frozen {
+		log.FromContext(ctx).Info("Unfreezing NamespaceClass", "frozenGeneration", nsClass.Status.FrozenGeneration)
+		nsClass.Status.FrozenGeneration = 0
+		nsClass.Status.FrozenSpec = ""
+		return r.Status().Patch(ctx, nsClass, patch)
+	}
+
+	if nsClass.Status.LastAppliedGeneration > 0 && nsClass.Status.LastAppliedSpec != "" {
+		nsClass.Status.FrozenGeneration = nsClass.Status.LastAppliedGeneration
+		nsClass.Status.FrozenSpec = nsClass.Status.LastAppliedSpec
+	} else {
+		spec, err := EncodeSpec(&nsClass.Spec)
+		if err != nil {
+			return err
+		}
+		nsClass.Status.FrozenGeneration = nsClass.Generation
+		nsClass.Status.FrozenSpec = spec
+	}
+	log.FromContext(ctx).Info("Freezing NamespaceClass", "frozenGeneration", nsClass.Status.FrozenGeneration)
+	r.Recorder.Eventf(nsClass, corev1.EventTypeNormal, ReasonFrozen, "Namespaces are held at generation %d until spec.updatePolicy is no longer Frozen",
+		nsClass.Status.FrozenGeneration)
+	return r.Status().Patch(ctx, nsClass, patch)
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"slices"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxHistory is how many generations status.history keeps
+const maxHistory = 10
+
+// recordRevision updates the status.history entry of generation, starting one when the generation is new, adding
+// namespaces that failed to sync it and completing it with summary once the rollout is complete. It reports whether
+// status changed.
+func recordRevision(status *akuityv1.NamespaceClassStatus, generation int64, complete bool, summary *akuityv1.RolloutSummary, failed []string, now metav1.Time) bool {
+	changed := false
+	n := len(status.History)
+	if n == 0 || status.History[n-1].Revision != generation {
+		status.History = append(status.History, akuityv1.RevisionHistory{Revision: generation, StartedAt: now})
+		if len(status.History) > maxHistory {
+			status.History = slices.Clone(status.History[len(status.History)-maxHistory:])
+		}
+		n = len(status.History)
+		changed = true
+	}
+
+	entry := &status.History[n-1]
+	if entry.CompletedAt != nil {
+		return changed
+	}
+	for _, ns := range failed {
+		if !slices.Contains(entry.FailedNamespaces, ns) {
+			entry.FailedNamespaces = append(entry.FailedNamespaces, ns)
+			changed = true
+		}
+	}
+	slices.Sort(entry.FailedNamespaces)
+	if complete {
+		entry.CompletedAt = &now
+		entry.Summary = summary
+		changed = true
+	}
+	return changed
+}
+
+// failedNamespaces returns the names of the namespaces whose last sync failed
+func failedNamespaces(namespaces []*corev1.Namespace) []string {
+	var failed []string
+	for _, ns := range namespaces {
+		if cond := syncedCondition(ns); cond != nil && cond.Status == corev1.ConditionFalse {
+			failed = append(failed, ns.Name)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// inherit merges the classes nsClass inherits from into it. Each parent contributes its applied revision, so
+// freezing a parent also freezes what its children inherit.
+func (r *NamespaceReconciler) inherit(ctx context.Context, nsClass *akuityv1.NamespaceClass) (*akuityv1.NamespaceClass, error) {
+	resolved, err := engine.ResolveInheritance(nsClass, func(name string) (*akuityv1.NamespaceClass, error) {
+		var parent akuityv1.NamespaceClass
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &parent); err != nil {
+			return nil, err
+		}
+		return appliedRevision(ctx, &parent), nil
+	})
+	if err != nil {
+		return nil, engine.WithReason(engine.ReasonRenderError, err)
+	}
+	return resolved, nil
+}
+
+// inheritingClasses returns the names of the classes that inherit from the class called name, directly or through
+// other classes
+func (r *NamespaceReconciler) inheritingClasses(ctx context.Context, name string) []string {
+	var classList akuityv1.NamespaceClassList
+	if err := r.List(ctx, &classList); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list classes inheriting from class", "class", name)
+		return nil
+	}
+	found := []string{name}
+	for i := 0; i < len(found); i++ {
+		for _, class := range classList.Items {
+			if slices.Contains(class.Spec.InheritFrom, found[i]) && !slices.Contains(found, class.Name) {
+				found = append(found, class.Name)
+			}
+		}
+	}
+	return found[1:]
+}
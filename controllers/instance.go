@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// AppliedByAnnotation names the operator instance (--instance-id) that applied a resource, or that syncs a Namespace
+const AppliedByAnnotation = engine.AppliedByAnnotation
+
+// InstanceConflictCondition is set on a Namespace synced by an operator instance other than the one recorded in its
+// AppliedByAnnotation
+const InstanceConflictCondition corev1.NamespaceConditionType = "NamespaceClassInstanceConflict"
+
+// ReasonInstanceConflict is the condition and event reason of namespaces synced by more than one operator instance
+const ReasonInstanceConflict = "InstanceConflict"
+
+var (
+	instanceInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_instance_info",
+			Help: "Always 1, labeled with the --instance-id of the operator instance exposing the metrics",
+		},
+		[]string{"instance"},
+	)
+	instanceConflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "namespaceclass_instance_conflicts_total",
+			Help: "Total syncs of namespaces recorded as synced by another operator instance",
+		},
+		[]string{"namespace", "instance", "other"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(instanceInfo, instanceConflictsTotal)
+}
+
+// claimInstance records r.Instance on ns as the instance syncing it, or reports a conflict when another instance is
+// recorded. The record is never overwritten, so two instances syncing the same namespace do not keep updating it.
+func (r *NamespaceReconciler) claimInstance(ctx context.Context, ns *corev1.Namespace) error {
+	if r.Instance == "" {
+		return nil
+	}
+	switch other := ns.Annotations[AppliedByAnnotation]; other {
+	case r.Instance:
+		return r.removeCondition(ctx, ns, InstanceConflictCondition)
+	case "":
+		patch := client.MergeFrom(ns.DeepCopy())
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[AppliedByAnnotation] = r.Instance
+		if err := r.Patch(ctx, ns, patch); err != nil {
+			return fmt.Errorf("failed to record operator instance: %w", err)
+		}
+		return r.removeCondition(ctx, ns, InstanceConflictCondition)
+	default:
+		instanceConflictsTotal.WithLabelValues(ns.Name, r.Instance, other).Inc()
+		message := fmt.Sprintf("Namespace is synced by operator instance %s but recorded as synced by %s; make sure their namespaces do not overlap, then remove the %s annotation if %s should own it",
+			r.Instance, other, AppliedByAnnotation, r.Instance)
+		if cond := namespaceCondition(ns, InstanceConflictCondition); cond == nil || cond.Message != message {
+			log.FromContext(ctx).Info("Namespace is recorded as synced by another operator instance", "instance", r.Instance, "other", other)
+			r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonInstanceConflict, message)
+		}
+		return r.setCondition(ctx, ns, InstanceConflictCondition, corev1.ConditionTrue, ReasonInstanceConflict, message)
+	}
+}
+
+// releaseInstance removes the record of r.Instance from ns once no class is attached, so another instance can take
+// the namespace over
+func (r *NamespaceReconciler) releaseInstance(ctx context.Context, ns *corev1.Namespace) error {
+	if r.Instance == "" || ns.Annotations[AppliedByAnnotation] != r.Instance {
+		return nil
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	delete(ns.Annotations, AppliedByAnnotation)
+	return r.Patch(ctx, ns, patch)
+}
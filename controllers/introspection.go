@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	metrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics for sizing the operator's memory footprint
+var (
+	cacheObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_cache_objects",
+			Help: "Number of objects held in the informer cache per kind",
+		},
+		[]string{"kind"},
+	)
+	inventoryItems = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_inventory_items",
+			Help: "Number of resources recorded in the inventory of a namespace",
+		},
+		[]string{"namespace"},
+	)
+	inventoryBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_inventory_bytes",
+			Help: "Serialized size of the inventory of a namespace",
+		},
+		[]string{"namespace"},
+	)
+	inventoriesPendingMigration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_inventories_pending_migration",
+			Help: "Number of namespaces whose inventory is stored in an older format",
+		},
+	)
+	inventoryMigrationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "namespaceclass_inventory_migrations_total",
+			Help: "Total number of namespace inventories upgraded to the current format, by the version they were upgraded from",
+		},
+		[]string{"from"},
+	)
+	templateCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_template_cache_entries",
+			Help: "Number of decoded resource templates held in the template cache",
+		},
+	)
+	renderCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_render_cache_entries",
+			Help: "Number of rendered resources held in the per-namespace render cache",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheObjects, inventoryItems, inventoryBytes, inventoriesPendingMigration, inventoryMigrationsTotal, templateCacheEntries, renderCacheEntries)
+}
+
+// recordInventorySize publishes the size of a namespace inventory
+func recordInventorySize(namespace string, items int, size int) {
+	if items == 0 {
+		inventoryItems.DeleteLabelValues(namespace)
+		inventoryBytes.DeleteLabelValues(namespace)
+		return
+	}
+	inventoryItems.WithLabelValues(namespace).Set(float64(items))
+	inventoryBytes.WithLabelValues(namespace).Set(float64(size))
+}
+
+// IntrospectionCollector periodically publishes informer cache and template cache sizes and per-class resource
+// states and, when HeapLogInterval is set, logs a heap summary so memory limits can be right-sized
+type IntrospectionCollector struct {
+	Reader    client.Reader
+	Templates *engine.TemplateCache
+	Renders   *engine.RenderCache
+	// Profile is used to evaluate template conditions when counting desired resources
+	Profile         engine.ProfileSource
+	Interval        time.Duration
+	HeapLogInterval time.Duration
+}
+
+// NeedLeaderElection lets every replica report its own memory footprint
+func (c *IntrospectionCollector) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable
+func (c *IntrospectionCollector) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("introspection")
+
+	interval := c.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var heapC <-chan time.Time
+	if c.HeapLogInterval > 0 {
+		heapTicker := time.NewTicker(c.HeapLogInterval)
+		defer heapTicker.Stop()
+		heapC = heapTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.collect(ctx); err != nil {
+				logger.Error(err, "failed to collect cache metrics")
+			}
+		case <-heapC:
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			logger.Info("Heap summary", "heapAllocBytes", m.HeapAlloc, "heapInuseBytes", m.HeapInuse,
+				"heapObjects", m.HeapObjects, "sysBytes", m.Sys, "numGC", m.NumGC, "goroutines", runtime.NumGoroutine())
+		}
+	}
+}
+
+func (c *IntrospectionCollector) collect(ctx context.Context) error {
+	var nsList corev1.NamespaceList
+	if err := c.Reader.List(ctx, &nsList); err != nil {
+		return err
+	}
+	cacheObjects.WithLabelValues("Namespace").Set(float64(meta.LenList(&nsList)))
+	pending := 0
+	for i := range nsList.Items {
+		if v, err := engine.InventoryVersion(&nsList.Items[i]); err == nil && v > 0 && v < engine.CurrentInventoryVersion {
+			pending++
+		}
+	}
+	inventoriesPendingMigration.Set(float64(pending))
+
+	var classList akuityv1.NamespaceClassList
+	if err := c.Reader.List(ctx, &classList); err != nil {
+		return err
+	}
+	cacheObjects.WithLabelValues("NamespaceClass").Set(float64(meta.LenList(&classList)))
+	c.collectResourceStates(ctx, nsList.Items, classList.Items)
+
+	if c.Templates != nil {
+		live := make(map[types.UID]bool, len(classList.Items))
+		for _, class := range classList.Items {
+			live[class.UID] = true
+		}
+		templateCacheEntries.Set(float64(c.Templates.Retain(live)))
+	}
+	if c.Renders != nil {
+		live := make(map[types.UID]bool, len(nsList.Items))
+		for _, ns := range nsList.Items {
+			live[ns.UID] = true
+		}
+		renderCacheEntries.Set(float64(c.Renders.Retain(live)))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// AllowMassPruneAnnotation, set on a Namespace to its name, lets a prune of resources found by the inventory label
+// fallback exceed MaxDiscoveredPrune
+const AllowMassPruneAnnotation = "namespaceclass.akuity.io/allow-mass-prune"
+
+// ReasonMassPruneBlocked is the reason of a sync or cleanup held back because the inventory label fallback found
+// more resources to prune than MaxDiscoveredPrune
+const ReasonMassPruneBlocked = "MassPruneBlocked"
+
+// discoverInventory stands in for the lost inventory of ns: it returns the resources labeled as managed by nsClass
+// in ns, searching the kinds nsClass templates and those of keep. When more than MaxDiscoveredPrune of them are
+// not in keep, and would be pruned, it fails unless ns carries AllowMassPruneAnnotation; labels can be copied onto
+// resources the operator never created, so a large prune of them is more likely a mistake than a cleanup.
+func (r *NamespaceReconciler) discoverInventory(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, keep []InventoryItem) ([]InventoryItem, error) {
+	kinds, err := engine.TemplateKinds(nsClass, r.Templates)
+	if err != nil {
+		return nil, engine.WithReason(engine.ReasonRenderError, err)
+	}
+	discovered, err := r.engine.DiscoverManaged(ctx, ns.Name, nsClass.Name, append(kinds, keep...))
+	if err != nil {
+		return nil, err
+	}
+
+	keepMap := make(map[string]bool, len(keep))
+	for _, item := range keep {
+		keepMap[item.Key()] = true
+	}
+	prune := 0
+	for _, item := range discovered {
+		if !keepMap[item.Key()] {
+			prune++
+		}
+	}
+	if prune == 0 {
+		return discovered, nil
+	}
+	if r.MaxDiscoveredPrune > 0 && prune > r.MaxDiscoveredPrune && ns.Annotations[AllowMassPruneAnnotation] != ns.Name {
+		return nil, engine.WithReason(ReasonMassPruneBlocked, fmt.Errorf(
+			"inventory is missing and %d resources labeled for NamespaceClass %s would be pruned, more than the limit of %d; annotate the namespace with %s=%s to prune them",
+			prune, nsClass.Name, r.MaxDiscoveredPrune, AllowMassPruneAnnotation, ns.Name))
+	}
+	log.FromContext(ctx).Info("Inventory is missing, pruning resources found by their labels", "class", nsClass.Name, "count", prune)
+	return discovered, nil
+}
+
+// discoverCleanup returns the resources to clean up of the class called className when the inventory of ns is
+// lost, or nothing when the class no longer exists to tell which kinds to search
+func (r *NamespaceReconciler) discoverCleanup(ctx context.Context, ns *corev1.Namespace, className string) ([]InventoryItem, error) {
+	var nsClass akuityv1.NamespaceClass
+	if err := r.Get(ctx, client.ObjectKey{Name: className}, &nsClass); err != nil {
+		if errors.IsNotFound(err) {
+			log.FromContext(ctx).Info("Inventory is missing and its class is gone, leaving its resources in place", "class", className)
+			return nil, nil
+		}
+		return nil, err
+	}
+	revision, err := r.inherit(ctx, appliedRevision(ctx, &nsClass))
+	if err != nil {
+		return nil, err
+	}
+	return r.discoverInventory(ctx, ns, revision, nil)
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// watchManaged adds watches on the managed resources of r.WatchKinds to bldr, so out-of-band edits and deletions are
+// reverted right away instead of at the next resync. Only metadata of objects carrying ManagedByLabel is cached, in
+// a cache of its own: the manager cache restricts some kinds, such as ConfigMaps, to the objects the operator reads.
+func (r *NamespaceReconciler) watchManaged(mgr ctrl.Manager, bldr *builder.Builder) (*builder.Builder, error) {
+	if len(r.WatchKinds) == 0 {
+		return bldr, nil
+	}
+	managed, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient:           mgr.GetHTTPClient(),
+		Scheme:               mgr.GetScheme(),
+		Mapper:               mgr.GetRESTMapper(),
+		DefaultLabelSelector: labels.SelectorFromSet(labels.Set{engine.ManagedByLabel: engine.ManagerName}),
+		DefaultTransform:     cache.TransformStripManagedFields(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed resource cache: %w", err)
+	}
+	if err := mgr.Add(managed); err != nil {
+		return nil, fmt.Errorf("failed to add managed resource cache: %w", err)
+	}
+	for _, gvk := range r.WatchKinds {
+		bldr = bldr.WatchesRawSource(source.Kind[client.Object](managed, managedObject(gvk),
+			r.triggers.handler(managedNamespaceRequest, triggerFor(TriggerManagedResource)), managedChanged()))
+	}
+	return bldr, nil
+}
+
+// managedObject returns a metadata-only object of kind gvk
+func managedObject(gvk schema.GroupVersionKind) client.Object {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
+
+// managedNamespaceRequest maps a managed resource to the namespace it belongs to
+func managedNamespaceRequest(_ context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetNamespace()}}}
+}
+
+// managedChanged passes deletions and edits of managed resources. Creations are the operator's own, or the initial
+// list of the cache. Only metadata is watched, so for kinds without a generation, such as ConfigMaps, every update
+// counts as an edit; applies that change nothing do not update the object, so the reconciles that follow settle.
+func managedChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectNew.GetGeneration() == 0 {
+				return e.ObjectOld.GetResourceVersion() != e.ObjectNew.GetResourceVersion()
+			}
+			return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+				!labels.Equals(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) ||
+				!labels.Equals(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations())
+		},
+		DeleteFunc:  func(event.DeleteEvent) bool { return true },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultMetadataSettleTime is how long resources wait after the namespace metadata changed when the class does
+// not set spec.namespaceMetadata.settleTime
+const defaultMetadataSettleTime = 5 * time.Second
+
+// metadataSettling holds back the resources of namespaces whose metadata just changed. The update of the Namespace
+// enqueues it again right away, so the wait has to outlive the reconcile that started it.
+type metadataSettling struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// hold makes the resources of namespace wait for d
+func (s *metadataSettling) hold(namespace string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.until == nil {
+		s.until = make(map[string]time.Time)
+	}
+	s.until[namespace] = time.Now().Add(d)
+}
+
+// remaining returns how much longer the resources of namespace wait, zero once they may be applied
+func (s *metadataSettling) remaining(namespace string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.until[namespace]
+	if !ok {
+		return 0
+	}
+	if left := time.Until(until); left > 0 {
+		return left
+	}
+	delete(s.until, namespace)
+	return 0
+}
+
+// metadataSettleTime returns how long the resources of nsClass wait after its namespace metadata changed
+func metadataSettleTime(nsClass *akuityv1.NamespaceClass) time.Duration {
+	if md := nsClass.Spec.NamespaceMetadata; md != nil && md.SettleTime != nil {
+		return md.SettleTime.Duration
+	}
+	return defaultMetadataSettleTime
+}
+
+// applyNamespaceMetadata applies the namespace metadata of nsClass to ns ahead of its resources, and returns how
+// long the resources have to wait for admission to observe the change
+func (r *NamespaceReconciler) applyNamespaceMetadata(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) (time.Duration, error) {
+	changed, err := engine.ApplyNamespaceMetadata(ctx, r.Client, ns, nsClass.Spec.NamespaceMetadata)
+	if err != nil {
+		return 0, err
+	}
+	if settle := metadataSettleTime(nsClass); changed && settle > 0 {
+		log.FromContext(ctx).Info("Namespace metadata changed, waiting for admission to observe it before applying resources", "settleTime", settle)
+		r.settling.hold(ns.Name, settle)
+	}
+	return r.settling.remaining(ns.Name), nil
+}
+
+// metadataRemovedLast reports whether the class called className keeps its namespace metadata until its resources
+// are removed. A class that no longer exists removes it first.
+func (r *NamespaceReconciler) metadataRemovedLast(ctx context.Context, className string) bool {
+	var nsClass akuityv1.NamespaceClass
+	if err := r.Get(ctx, types.NamespacedName{Name: className}, &nsClass); err != nil {
+		return false
+	}
+	md := appliedRevision(ctx, &nsClass).Spec.NamespaceMetadata
+	return md != nil && md.RemoveLast
+}
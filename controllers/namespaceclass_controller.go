@@ -4,22 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/utils/pointer"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	metrics "sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -28,11 +34,11 @@ import (
 
 const (
 	NamespaceClassLabel     = "namespaceclass.akuity.io/name"
-	ManagedByLabel          = "namespaceclass.akuity.io/managed-by"
-	SourceClassLabel        = "namespaceclass.akuity.io/source-class"
-	InventoryAnnotation     = "namespaceclass.akuity.io/inventory"
-	AttachedClassAnnotation = "namespaceclass.akuity.io/attached-class"
-	ControllerName          = "namespace-class-controller"
+	ManagedByLabel          = engine.ManagedByLabel
+	SourceClassLabel        = engine.SourceClassLabel
+	InventoryAnnotation     = engine.InventoryAnnotation
+	AttachedClassAnnotation = engine.AttachedClassAnnotation
+	ControllerName          = engine.ManagerName
 	NamespaceClassFinalizer = "namespaceclass.core.akuity.io/finalizer"
 )
 
@@ -57,7 +63,14 @@ var (
 			Name: "namespaceclass_reconcile_errors_total",
 			Help: "Total reconcile errors",
 		},
-		[]string{"namespace", "phase"},
+		[]string{"namespace", "phase", "reason"},
+	)
+	applyErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "namespaceclass_apply_errors_total",
+			Help: "Total number of failed resource applies by kind and failure reason",
+		},
+		[]string{"namespace", "class", "kind", "reason"},
 	)
 	reconcileDurationSeconds = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -67,10 +80,31 @@ var (
 		},
 		[]string{"namespace", "class"},
 	)
+	driftCorrectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "namespaceclass_drift_corrections_total",
+			Help: "Total number of managed resources re-applied because they were changed out of band",
+		},
+		[]string{"namespace", "class"},
+	)
+	skippedAppliesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "namespaceclass_skipped_applies_total",
+			Help: "Total number of applies skipped because the controller's owned fields were already up to date",
+		},
+		[]string{"namespace", "class", "kind"},
+	)
+	namespacesWaitingForClass = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "namespaceclass_namespaces_waiting_for_class",
+			Help: "Number of namespaces referencing a NamespaceClass that does not exist",
+		},
+		[]string{"class"},
+	)
 )
 
 func init() {
-	metrics.Registry.MustRegister(appliedResourcesTotal, prunedResourcesTotal, reconcileErrorsTotal, reconcileDurationSeconds)
+	metrics.Registry.MustRegister(appliedResourcesTotal, prunedResourcesTotal, reconcileErrorsTotal, applyErrorsTotal, reconcileDurationSeconds, skippedAppliesTotal, driftCorrectionsTotal, namespacesWaitingForClass)
 }
 
 type NamespaceReconciler struct {
@@ -78,90 +112,483 @@ type NamespaceReconciler struct {
 	Scheme                  *runtime.Scheme
 	Recorder                record.EventRecorder
 	MaxConcurrentReconciles int
+	// Templates caches decoded class templates across reconciles; nil disables caching
+	Templates *engine.TemplateCache
+	// Renders caches rendered resources per namespace across reconciles; nil disables caching
+	Renders *engine.RenderCache
+	// SkipUnchangedApplies skips the server-side apply when the controller's owned fields already match the intent
+	SkipUnchangedApplies bool
+	// RespectConflicts fails applies that would take over fields owned by other field managers, except for the
+	// kinds a class lists in spec.forceOwnershipKinds
+	RespectConflicts bool
+	// ClassMissingRequeue is how often a namespace whose class does not exist is re-checked. Zero disables requeueing.
+	ClassMissingRequeue time.Duration
+	// AnnotateSource stamps applied resources with the generation of the class they were rendered from
+	AnnotateSource bool
+	// LabelGeneration labels applied resources with their class generation and prunes labeled resources of older
+	// generations that the inventory lost track of
+	LabelGeneration bool
+	// DenyCRDs rejects classes that template CustomResourceDefinitions
+	DenyCRDs bool
+	// ForbiddenKinds are kinds classes may not template
+	ForbiddenKinds []schema.GroupKind
+	// Protected are resources of the operator itself that classes may not template
+	Protected []engine.ProtectedObject
+	// Instance names this operator instance on the resources it applies and the namespaces it syncs; empty leaves
+	// them unnamed
+	Instance string
+	// ResourceEvents emits a Normal event on every resource the controller writes, naming its class and generation
+	ResourceEvents bool
+	// RespectAutoscalers leaves replicas and container resources of HPA and VPA targets to the autoscalers
+	RespectAutoscalers bool
+	// HashAnnotation stamps every managed resource with the hash of its rendered intent
+	HashAnnotation bool
+	// Profile is the cluster profile that template conditions can reference; nil disables it
+	Profile *ClusterProfile
+	// Startup delays reconciles until every NamespaceClass has been reconciled once; nil starts right away
+	Startup *ClassesFirst
+	// Concurrency scales the reconcile workers with the queue depth instead of MaxConcurrentReconciles; nil
+	// keeps the fixed count
+	Concurrency *AdaptiveConcurrency
+	// ClientSideApplyKinds are applied with three-way merge patches instead of server-side apply, for kinds
+	// served by API servers with unreliable SSA support
+	ClientSideApplyKinds []schema.GroupVersionKind
+	// PrioritizeClasses reconciles namespaces in the spec.priority order of their classes when requests queue up
+	PrioritizeClasses bool
+	// RetainKinds are never pruned, whatever the class says; a resource of such a kind that its class stops
+	// rendering is dropped from the inventory and reported instead, to be deleted by hand
+	RetainKinds []schema.GroupKind
+	// ApplyOnceKinds are created once and then never re-applied or drift-corrected, cluster-wide
+	ApplyOnceKinds []schema.GroupKind
+	// WatchKinds are the kinds whose managed resources are watched, so that editing or deleting one syncs its
+	// namespace right away
+	WatchKinds []schema.GroupVersionKind
+	// RegistryMirrors rewrite the container images of rendered workloads to pull from mirrors instead
+	RegistryMirrors []engine.RegistryMirror
+	// ExternalRenderers render the classes whose spec.renderer names them
+	ExternalRenderers map[string]engine.RenderPlugin
+	// ExternalRenderTimeout bounds each call to an external renderer; engine.DefaultExternalRenderTimeout when zero
+	ExternalRenderTimeout time.Duration
+	// SyncSLO is how long a namespace may take to sync a class change before SyncSLOBreachedCondition is set on
+	// it; zero disables the condition, the latency is observed either way
+	SyncSLO time.Duration
+	// StuckCleanupThreshold is how long the cleanup of a namespace may be blocked before it is exported as
+	// namespaceclass_cleanup_blocked_since_seconds; zero exports every blocked cleanup
+	StuckCleanupThreshold time.Duration
+	// ApplyLimits cap the concurrent applies per API group or kind across all reconciles
+	ApplyLimits []engine.ApplyLimit
+	// Faults injects apply failures and latency for testing; nil disables fault injection
+	Faults *engine.Faults
+	// Denylist names namespaces that never get a class; nil denies nothing
+	Denylist *NamespaceDenylist
+	// AdoptExisting records the resources that already exist in a namespace on its first sync instead of rewriting
+	// them, for installs into clusters whose namespaces already hold what their classes render. Only namespaces
+	// created before the operator started, and never synced by it, are adopted.
+	AdoptExisting bool
+	// InventoryConfigMaps keeps each namespace's inventory in a ConfigMap instead of annotations on the Namespace,
+	// moving existing inventories over as namespaces sync, see engine.ConfigMapStore
+	InventoryConfigMaps bool
+	// Rollouts totals the syncs of each class generation for the summary of its rollout; nil counts nothing
+	Rollouts *RolloutTracker
+	// Pacing spreads the reconciles queued when the operator starts; nil reconciles them as fast as the workers allow
+	Pacing *StartupPacing
+	// InventoryLabelFallback prunes and cleans up the resources labeled as managed by a class when the inventory
+	// of their namespace is missing, e.g. after its annotation was wiped
+	InventoryLabelFallback bool
+	// MaxDiscoveredPrune blocks prunes of more resources than this found by InventoryLabelFallback, unless the
+	// namespace carries AllowMassPruneAnnotation; zero allows any number
+	MaxDiscoveredPrune int
+
+	engine    *engine.Engine
+	startedAt time.Time
+	triggers  triggerTracker
+	admission admissionBackoff
+	settling  metadataSettling
+	syncs     syncTracker
+	waitingMu sync.Mutex
+	waiting   map[string]string // namespace -> missing class
 }
 
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core,resources=namespaces/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=akuity.io,resources=namespaceclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=autoscaling.k8s.io,resources=verticalpodautoscalers,verbs=get;list
 // +kubebuilder:rbac:groups=*,resources=*,verbs=*
 
 func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := log.FromContext(ctx)
+	trigger := r.triggers.take(req)
+	logger := log.FromContext(ctx).WithValues("trigger", trigger)
+	ctx = log.IntoContext(withTrigger(ctx, trigger), logger)
+	if err := r.Startup.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	var ns corev1.Namespace
 	if err := r.Get(ctx, req.NamespacedName, &ns); err != nil {
+		if errors.IsNotFound(err) {
+			r.clearWaiting(req.Name)
+			r.admission.reset(req.Name)
+			r.syncs.finish(req.Name)
+			recordInventorySize(req.Name, 0, 0)
+			forgetBlockedCleanup(req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Check if Namespace is being deleted
+	if terminating(&ns) {
+		// Kubernetes Garbage Collector will clean up resources
+		// since we set OwnerReference to Namespace in applyClassResources
+		r.forgetTerminating(ns.Name)
+		// Resources without it are held by the cleanup finalizer until deleted here
+		if controllerutil.ContainsFinalizer(&ns, engine.CleanupFinalizer) {
+			return ctrl.Result{}, r.finalizeNamespace(ctx, &ns)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	start := time.Now()
 	className := ns.Labels[NamespaceClassLabel]
+	reconcileTriggersTotal.WithLabelValues(className, trigger).Inc()
 	defer func() {
 		reconcileDurationSeconds.WithLabelValues(ns.Name, className).Observe(time.Since(start).Seconds())
 	}()
 
-	// Check if Namespace is being deleted
-	if !ns.DeletionTimestamp.IsZero() {
-		// Kubernetes Garbage Collector will clean up resources
-		// since we set OwnerReference to Namespace in applyClassResources
-		return ctrl.Result{}, nil
+	// A temporary attachment is detached once it expires; the clean-up runs on the reconcile the label change triggers
+	var untilExpiry time.Duration
+	if className != "" {
+		remaining, ok := r.attachmentExpiry(&ns, time.Now())
+		if ok && remaining <= 0 {
+			r.clearWaiting(ns.Name)
+			return ctrl.Result{}, r.detachExpired(ctx, &ns, className)
+		}
+		untilExpiry = remaining
 	}
 
 	if className == "" {
+		r.clearWaiting(ns.Name)
+		r.syncs.finish(ns.Name)
+		if err := r.removeSyncedCondition(ctx, &ns); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.removeCondition(ctx, &ns, SyncSLOBreachedCondition); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.removeCondition(ctx, &ns, CRDTerminatingCondition); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.removeCondition(ctx, &ns, InstanceConflictCondition); err != nil {
+			return ctrl.Result{}, err
+		}
 		// Case: Label missing/removed
 		// Check for existing Inventory annotation to determine if cleanup is needed
 		if ann := ns.GetAnnotations(); ann != nil && ann[AttachedClassAnnotation] != "" {
 			prevClass := ann[AttachedClassAnnotation]
+			policy, err := NamespaceDeletionPolicy(&ns, akuityv1.DeletionPolicyCascade)
+			if err != nil {
+				logger.Error(err, "ignoring deletion policy override")
+			}
+			if policy == akuityv1.DeletionPolicyOrphan {
+				// Keep the resources in place and stop managing them
+				logger.Info("Class label removed, orphaning resources", "previousClass", prevClass)
+				if err := r.setNamespaceInventory(ctx, &ns, "", nil); err != nil {
+					return ctrl.Result{}, err
+				}
+				return ctrl.Result{}, r.releaseInstance(ctx, &ns)
+			}
 			logger.Info("Class label removed, cleaning up resources", "previousClass", prevClass)
 			if err := r.cleanUpResources(ctx, &ns, prevClass); err != nil {
 				logger.Error(err, "failed to cleanup resources")
-				reconcileErrorsTotal.WithLabelValues(ns.Name, "cleanup").Inc()
+				r.recordError(&ns, "cleanup", err)
 				return ctrl.Result{}, err
 			}
 		}
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, r.releaseInstance(ctx, &ns)
 	}
 
 	// Get NamespaceClass definition
 	var nsClass akuityv1.NamespaceClass
 	if err := r.Get(ctx, types.NamespacedName{Name: className}, &nsClass); err != nil {
 		if errors.IsNotFound(err) {
-			logger.Info("Referenced NamespaceClass not found", "class", className)
-			r.Recorder.Eventf(&ns, corev1.EventTypeWarning, "ClassMissing", "NamespaceClass %s not found", className)
-			reconcileErrorsTotal.WithLabelValues(ns.Name, "class-missing").Inc()
-			return ctrl.Result{}, nil // No retry - wait for Class creation or label modification
+			message := fmt.Sprintf("NamespaceClass %s not found", className)
+			// The requeue re-checks a class that stays missing; only the first miss is worth an event and an error
+			if cond := syncedCondition(&ns); cond == nil || cond.Reason != ReasonClassMissing || cond.Message != message {
+				logger.Info("Referenced NamespaceClass not found", "class", className)
+				r.Recorder.Event(&ns, corev1.EventTypeWarning, ReasonClassMissing, message)
+				reconcileErrorsTotal.WithLabelValues(ns.Name, "class-missing", ReasonClassMissing).Inc()
+			}
+			r.markWaiting(ns.Name, className)
+			if err := r.setSyncedCondition(ctx, &ns, corev1.ConditionFalse, ReasonClassMissing, message); err != nil {
+				return ctrl.Result{}, err
+			}
+			// Class creation also triggers a reconcile via the watch; the requeue is a safety net
+			return ctrl.Result{RequeueAfter: r.ClassMissingRequeue}, nil
 		}
 		return ctrl.Result{}, err
 	}
+	r.clearWaiting(ns.Name)
+
+	// The operator's deny list overrides every class; a denied namespace keeps none of the class resources
+	if pattern := r.Denylist.Denies(ns.Name); pattern != "" {
+		logger.Info("Namespace is on the deny list, not attaching class", "class", className, "pattern", pattern)
+		return ctrl.Result{}, r.refuseClass(ctx, &ns, ReasonNamespaceDenied,
+			fmt.Sprintf("Namespace matches the operator's deny pattern %s and cannot attach NamespaceClass %s", pattern, className))
+	}
+
+	// Enforce spec.allowedNamespaces; a namespace that is not allowed keeps none of the class resources
+	allowed, err := NamespaceAllowed(&nsClass, &ns)
+	if err != nil {
+		return ctrl.Result{}, r.failSync(ctx, &ns, "authorize", "Failed to evaluate allowedNamespaces", engine.WithReason(engine.ReasonRenderError, err))
+	}
+	if !allowed {
+		logger.Info("Namespace is not allowed to attach class", "class", className)
+		return ctrl.Result{}, r.refuseClass(ctx, &ns, ReasonNamespaceNotAllowed,
+			fmt.Sprintf("Namespace is not allowed to attach NamespaceClass %s", className))
+	}
 
 	// Read old inventory
 	oldInventory, err := r.getNamespaceInventory(ctx, &ns)
 	if err != nil {
-		reconcileErrorsTotal.WithLabelValues(ns.Name, "read-inventory").Inc()
+		return ctrl.Result{}, r.failSync(ctx, &ns, "read-inventory", "Failed to read inventory", err)
+	}
+	revision, err := r.inherit(ctx, appliedRevision(ctx, &nsClass))
+	if err != nil {
+		return ctrl.Result{}, r.failSync(ctx, &ns, "resolve-inheritance", "Failed to resolve inherited classes", err)
+	}
+	if dryRunRequested(&ns) {
+		return ctrl.Result{}, r.dryRun(ctx, &ns, revision, oldInventory)
+	}
+	if err := r.trackSync(ctx, &ns, revision, oldInventory); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// Apply resources
-	appliedInventory, err := r.applyClassResources(ctx, &ns, &nsClass)
+	// A resync requested on the class re-writes every resource, including ones that look unchanged
+	resync := resyncRequested(&ns, &nsClass)
+	applyCtx := ctx
+	if resync {
+		logger.Info("Resync requested by class", "resync", nsClass.Annotations[ResyncAnnotation])
+		applyCtx = engine.WithForceApply(ctx)
+	}
+	applyCtx = r.adoptionContext(applyCtx, &ns, oldInventory)
+
+	if err := r.claimInstance(ctx, &ns); err != nil {
+		return ctrl.Result{}, r.failSync(ctx, &ns, "instance", "Failed to record operator instance", err)
+	}
+
+	// Namespace metadata, e.g. the Pod Security Admission level, goes first, so pods of the class are admitted
+	// under it
+	wait, err := r.applyNamespaceMetadata(ctx, &ns, revision)
+	if err != nil {
+		return ctrl.Result{}, r.failSync(ctx, &ns, "namespace-metadata", "Failed to apply namespace metadata", err)
+	}
+	if wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// Apply resources; a frozen class keeps enforcing its frozen revision
+	appliedInventory, summary, err := r.applyClassResources(applyCtx, &ns, revision, oldInventory)
+	if engine.IsNamespaceTerminating(err) {
+		// The namespace started terminating mid-sync; its deletion removes whatever was applied
+		logger.V(1).Info("Namespace started terminating during sync, skipping the remaining applies")
+		r.forgetTerminating(ns.Name)
+		return ctrl.Result{}, nil
+	}
+	if err != nil && engine.IsRenderFailure(err) && revision.Spec.FallbackClass != "" {
+		logger.Error(err, "Failed to render class, trying its fallback", "fallback", revision.Spec.FallbackClass)
+		if result, ok, fbErr := r.applyFallback(applyCtx, &ns, revision, oldInventory, err); ok {
+			return result, fbErr
+		}
+	}
 	if err != nil {
 		logger.Error(err, "Failed to apply resources")
-		reconcileErrorsTotal.WithLabelValues(ns.Name, "apply-resources").Inc()
-		r.Recorder.Eventf(&ns, corev1.EventTypeWarning, "ApplyFailed", "Failed to apply resources: %v", err)
-		return ctrl.Result{}, err
+		summary.failed++
+		r.reportSummary(ctx, &ns, className, revision.Generation, summary)
+		// Track what the partial rollout created so a later sync can prune it
+		if merged := engine.MergePartial(oldInventory, appliedInventory); len(merged) > len(oldInventory) {
+			if err := r.setNamespaceInventory(ctx, &ns, className, merged); err != nil {
+				logger.Error(err, "failed to record partially applied resources")
+			}
+		}
+		syncErr := r.failSync(ctx, &ns, "apply-resources", "Failed to apply resources"+describeBaselineChanges(revision), err)
+		if delay := r.admission.next(ns.Name, err); delay > 0 {
+			logger.Info("Admission control keeps denying the namespace, backing it off", "deniedBy", engine.AdmissionDenier(err), "retryAfter", delay)
+			return ctrl.Result{RequeueAfter: delay}, nil
+		}
+		return ctrl.Result{}, syncErr
 	}
+	r.admission.reset(ns.Name)
+	appliedInventory = engine.CarryOverAdopted(oldInventory, appliedInventory)
+	appliedInventory = engine.CarryOverCreated(oldInventory, appliedInventory, metav1.Now())
 
-	// Clean up orphaned resources
-	if err := r.pruneOrphanedResources(ctx, ns.Name, oldInventory, appliedInventory, className); err != nil {
-		reconcileErrorsTotal.WithLabelValues(ns.Name, "prune").Inc()
-		return ctrl.Result{}, err
+	// Clean up orphaned resources; without an inventory, by the labels of the class
+	pruneFrom := oldInventory
+	if len(oldInventory) == 0 && r.InventoryLabelFallback {
+		pruneFrom, err = r.discoverInventory(ctx, &ns, revision, appliedInventory)
+		if err != nil {
+			summary.failed++
+			r.reportSummary(ctx, &ns, className, revision.Generation, summary)
+			return ctrl.Result{}, r.failSync(ctx, &ns, "prune", "Failed to prune resources", err)
+		}
+	}
+	if pruneFrom, err = r.orphanAdditionalClasses(ctx, &ns, pruneFrom); err != nil {
+		summary.failed++
+		r.reportSummary(ctx, &ns, className, revision.Generation, summary)
+		return ctrl.Result{}, r.failSync(ctx, &ns, "prune", "Failed to orphan resources of deleted additional classes", err)
 	}
+	pruned, err := r.pruneOrphanedResources(ctx, pruneFrom, appliedInventory, className)
+	summary.pruned += pruned
+	r.recordStuck(ctx, &ns, err)
+	if err != nil {
+		summary.failed++
+		r.reportSummary(ctx, &ns, className, revision.Generation, summary)
+		return ctrl.Result{}, r.failSync(ctx, &ns, "prune", "Failed to prune resources", err)
+	}
+	if r.LabelGeneration {
+		kinds := append(append([]InventoryItem(nil), oldInventory...), appliedInventory...)
+		pruned, err := r.engine.PruneStale(ctx, ns.Name, className, revision.Generation, appliedInventory, kinds)
+		for _, item := range pruned {
+			prunedResourcesTotal.WithLabelValues(item.Namespace, className, item.Kind).Inc()
+		}
+		summary.pruned += len(pruned)
+		if err != nil {
+			summary.failed++
+			r.reportSummary(ctx, &ns, className, revision.Generation, summary)
+			return ctrl.Result{}, r.failSync(ctx, &ns, "prune", "Failed to prune resources of earlier generations", err)
+		}
+	}
+	r.reportSummary(ctx, &ns, className, revision.Generation, summary)
 
-	// Update inventory
+	// Update inventory; this also persists an inventory read in an older format in the current one
 	if err := r.setNamespaceInventory(ctx, &ns, className, appliedInventory); err != nil {
-		reconcileErrorsTotal.WithLabelValues(ns.Name, "persist-inventory").Inc()
+		return ctrl.Result{}, r.failSync(ctx, &ns, "persist-inventory", "Failed to persist inventory", err)
+	}
+	additional, err := r.attachedAdditionalClasses(ctx, &ns, className)
+	if err == nil {
+		err = r.recordAdditionalClasses(ctx, &ns, additional)
+	}
+	if err != nil {
+		return ctrl.Result{}, r.failSync(ctx, &ns, "persist-inventory", "Failed to record additional classes", err)
+	}
+	if resync {
+		if err := r.markResynced(ctx, &ns, &nsClass); err != nil {
+			return ctrl.Result{}, r.failSync(ctx, &ns, "resync", "Failed to record resync", err)
+		}
+	}
+	if version, _ := engine.InventoryVersion(&ns); version > 0 && version < engine.CurrentInventoryVersion && len(appliedInventory) > 0 {
+		logger.Info("Migrated inventory format", "from", version, "to", engine.CurrentInventoryVersion)
+		inventoryMigrationsTotal.WithLabelValues(strconv.Itoa(version)).Inc()
+	}
+
+	message := fmt.Sprintf("NamespaceClass %s applied", className)
+	if revision.Generation != nsClass.Generation {
+		message = fmt.Sprintf("NamespaceClass %s applied (frozen at generation %d)", className, revision.Generation)
+	}
+	if err := r.setSyncedCondition(ctx, &ns, corev1.ConditionTrue, ReasonSynced, message); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.completeSync(ctx, &ns, className); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	logger.Info("Successfully reconciled namespace", "class", className)
-	return ctrl.Result{}, nil
+	requeue := sooner(sooner(driftCheckInterval(&nsClass), untilExpiry), blockedRequeue(appliedInventory))
+	if requeue > 0 && requeue == driftCheckInterval(&nsClass) {
+		r.triggers.recordRequeue(req, TriggerDrift)
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// minDriftCheckInterval bounds how often a class can ask for its namespaces to be re-applied
+const minDriftCheckInterval = 30 * time.Second
+
+// driftCheckInterval returns when a namespace synced with nsClass should be re-applied, or zero to rely on the
+// global resync
+func driftCheckInterval(nsClass *akuityv1.NamespaceClass) time.Duration {
+	if nsClass.Spec.DriftCheckInterval == nil || nsClass.Spec.DriftCheckInterval.Duration <= 0 {
+		return 0
+	}
+	return max(nsClass.Spec.DriftCheckInterval.Duration, minDriftCheckInterval)
+}
+
+// refuseClass removes the resources of the class previously attached to ns, if any, and reports why its label is
+// not honoured
+func (r *NamespaceReconciler) refuseClass(ctx context.Context, ns *corev1.Namespace, reason, message string) error {
+	if prevClass := ns.GetAnnotations()[AttachedClassAnnotation]; prevClass != "" {
+		if err := r.cleanUpResources(ctx, ns, prevClass); err != nil {
+			return r.failSync(ctx, ns, "cleanup", "Failed to clean up resources", err)
+		}
+	}
+	r.Recorder.Event(ns, corev1.EventTypeWarning, reason, message)
+	reconcileErrorsTotal.WithLabelValues(ns.Name, "authorize", reason).Inc()
+	return r.setSyncedCondition(ctx, ns, corev1.ConditionFalse, reason, message)
+}
+
+// recordError classifies a reconcile failure and counts it under the given phase
+func (r *NamespaceReconciler) recordError(ns *corev1.Namespace, phase string, err error) string {
+	reason := engine.ClassifyError(err)
+	reconcileErrorsTotal.WithLabelValues(ns.Name, phase, reason).Inc()
+	return reason
+}
+
+// failSync reports a failed sync through the error metric, a Warning event and the NamespaceClassSynced condition.
+// The original error is returned so the request is retried with backoff. Failures caused by the namespace
+// terminating mid-sync are dropped instead; its deletion ends the sync.
+func (r *NamespaceReconciler) failSync(ctx context.Context, ns *corev1.Namespace, phase, message string, err error) error {
+	if engine.IsNamespaceTerminating(err) {
+		log.FromContext(ctx).V(1).Info("Namespace started terminating during sync", "phase", phase)
+		r.forgetTerminating(ns.Name)
+		return nil
+	}
+	reason := r.recordError(ns, phase, err)
+	if by := engine.AdmissionDenier(err); by != "" {
+		message += " (denied by " + by + ")"
+	}
+	r.Recorder.Eventf(ns, corev1.EventTypeWarning, reason, "%s: %v", message, err)
+	if condErr := r.setSyncedCondition(ctx, ns, corev1.ConditionFalse, reason, fmt.Sprintf("%s: %v", message, err)); condErr != nil {
+		log.FromContext(ctx).Error(condErr, "failed to update sync condition")
+	}
+	return err
+}
+
+// terminating reports whether ns is being deleted
+func terminating(ns *corev1.Namespace) bool {
+	return !ns.DeletionTimestamp.IsZero() || ns.Status.Phase == corev1.NamespaceTerminating
+}
+
+// forgetTerminating drops a terminating namespace from the per-namespace gauges ahead of its deletion
+func (r *NamespaceReconciler) forgetTerminating(namespace string) {
+	r.clearWaiting(namespace)
+	r.admission.reset(namespace)
+	r.syncs.finish(namespace)
+	recordInventorySize(namespace, 0, 0)
+}
+
+// markWaiting records that a namespace references a missing class and updates the waiting gauge
+func (r *NamespaceReconciler) markWaiting(namespace, class string) {
+	r.waitingMu.Lock()
+	defer r.waitingMu.Unlock()
+	if r.waiting == nil {
+		r.waiting = make(map[string]string)
+	}
+	if prev, ok := r.waiting[namespace]; ok {
+		if prev == class {
+			return
+		}
+		namespacesWaitingForClass.WithLabelValues(prev).Dec()
+	}
+	r.waiting[namespace] = class
+	namespacesWaitingForClass.WithLabelValues(class).Inc()
+}
+
+// clearWaiting drops a namespace from the waiting gauge once its class resolves or it no longer references one
+func (r *NamespaceReconciler) clearWaiting(namespace string) {
+	r.waitingMu.Lock()
+	defer r.waitingMu.Unlock()
+	if prev, ok := r.waiting[namespace]; ok {
+		namespacesWaitingForClass.WithLabelValues(prev).Dec()
+		delete(r.waiting, namespace)
+	}
 }
 
 // +kubebuilder:rbac:groups=akuity.io,resources=namespaceclasses,verbs=get;list;watch;update
@@ -169,7 +596,26 @@ func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 type NamespaceClassReconciler struct {
 	client.Client
 	Scheme                  *runtime.Scheme
+	Recorder                record.EventRecorder
 	MaxConcurrentReconciles int
+	// RecordLastAppliedSpec keeps a compressed copy of the last fully rolled out spec in status.lastAppliedSpec
+	RecordLastAppliedSpec bool
+	// Rollouts summarizes each rollout as it completes; nil records it without totals
+	Rollouts *RolloutTracker
+	// Concurrency scales the reconcile workers with the queue depth instead of MaxConcurrentReconciles; nil
+	// keeps the fixed count
+	Concurrency *AdaptiveConcurrency
+	// PrioritizeClasses reconciles classes in spec.priority order when requests queue up
+	PrioritizeClasses bool
+	// AggregateStatus leaves the aggregated status and the last applied baseline to ClassStatusReconciler, so
+	// namespace syncs no longer enqueue their class
+	AggregateStatus bool
+	// StatusBatchInterval delays the class reconcile a namespace change triggers when AggregateStatus is off, so the
+	// changes of every namespace synced within the interval fold into one status write
+	StatusBatchInterval time.Duration
+	// KubernetesMinor is the minor version of the cluster, e.g. 29 for 1.29, which DeprecatedAPIsCondition is
+	// evaluated against; zero when unknown
+	KubernetesMinor int
 }
 
 func (r *NamespaceClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -188,11 +634,32 @@ func (r *NamespaceClassReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			}
 			logger.Info("Added finalizer to NamespaceClass")
 		}
-		return ctrl.Result{}, nil
+		if err := r.syncFreeze(ctx, &nsClass); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.syncDeprecations(ctx, &nsClass); err != nil {
+			return ctrl.Result{}, err
+		}
+		// The status controller aggregates the status on its own schedule
+		if r.AggregateStatus {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, patchClassStatus(ctx, r.Client, &nsClass, func(nsClass *akuityv1.NamespaceClass) (bool, error) {
+			return updateClassStatus(ctx, r.Client, nsClass, r.RecordLastAppliedSpec, r.Rollouts)
+		})
 	}
 
 	// Handle deletion logic
 	if controllerutil.ContainsFinalizer(&nsClass, NamespaceClassFinalizer) {
+		// A protected class keeps its finalizer, and namespaces keep their resources, until deletion is confirmed;
+		// setting the annotation triggers a new reconcile
+		if !DeletionAllowed(&nsClass) {
+			logger.Info("NamespaceClass is protected from deletion", "annotation", AllowDeletionAnnotation)
+			r.Recorder.Eventf(&nsClass, corev1.EventTypeWarning, ReasonDeletionProtected,
+				"Deletion is blocked until annotation %s is set to %q", AllowDeletionAnnotation, nsClass.Name)
+			return ctrl.Result{}, nil
+		}
+
 		logger.Info("NamespaceClass is being deleted", "policy", nsClass.Spec.DeletionPolicy)
 
 		// Default policy is Cascade
@@ -201,24 +668,46 @@ func (r *NamespaceClassReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			policy = akuityv1.DeletionPolicyCascade
 		}
 
-		if policy == akuityv1.DeletionPolicyCascade {
-			// Find all Namespaces referencing this Class and remove the label
-			// NamespaceReconciler will cleanUpResources
-			var nsList corev1.NamespaceList
-			if err := r.List(ctx, &nsList, client.MatchingLabels{NamespaceClassLabel: nsClass.Name}); err != nil {
+		// Find all Namespaces referencing this Class and remove the label from those whose effective policy is
+		// Cascade; NamespaceReconciler will cleanUpResources
+		var nsList corev1.NamespaceList
+		if err := r.List(ctx, &nsList, client.MatchingLabels{NamespaceClassLabel: nsClass.Name}); err != nil {
+			return ctrl.Result{}, err
+		}
+		// Namespaces attaching it as an additional class drop it from their annotation and prune its resources
+		if err := r.detachAdditionalClass(ctx, nsClass.Name, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		for _, ns := range nsList.Items {
+			nsPolicy, err := NamespaceDeletionPolicy(&ns, policy)
+			if err != nil {
+				logger.Error(err, "ignoring deletion policy override", "namespace", ns.Name)
+			}
+			if nsPolicy != akuityv1.DeletionPolicyCascade {
+				continue
+			}
+			// Remove label
+			patch := client.MergeFrom(ns.DeepCopy())
+			delete(ns.Labels, NamespaceClassLabel)
+			if err := r.Patch(ctx, &ns, patch); err != nil {
+				logger.Error(err, "Failed to remove label from namespace during cascade delete", "namespace", ns.Name)
 				return ctrl.Result{}, err
 			}
+			logger.Info("Detached NamespaceClass from Namespace (Cascade)", "namespace", ns.Name)
+		}
 
-			for _, ns := range nsList.Items {
-				// Remove label
-				patch := client.MergeFrom(ns.DeepCopy())
-				delete(ns.Labels, NamespaceClassLabel)
-				if err := r.Patch(ctx, &ns, patch); err != nil {
-					logger.Error(err, "Failed to remove label from namespace during cascade delete", "namespace", ns.Name)
-					return ctrl.Result{}, err
-				}
-				logger.Info("Detached NamespaceClass from Namespace (Cascade)", "namespace", ns.Name)
-			}
+		// Keep the finalizer until every namespace being cascaded has removed its resources
+		pending, err := r.pendingCleanup(ctx, &nsClass, policy)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.updateDeletionStatus(ctx, &nsClass, len(pending)); err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(pending) > 0 {
+			logger.Info("Waiting for namespaces to clean up", "pending", len(pending), "namespaces", pending)
+			return ctrl.Result{RequeueAfter: deletionPollInterval}, nil
 		}
 
 		// Remove finalizer
@@ -226,191 +715,180 @@ func (r *NamespaceClassReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		if err := r.Update(ctx, &nsClass); err != nil {
 			return ctrl.Result{}, err
 		}
+		r.Rollouts.forget(nsClass.Name)
 		logger.Info("Removed finalizer and deleted NamespaceClass")
 	}
 
 	return ctrl.Result{}, nil
 }
 
-type inventoryItem struct {
-	APIVersion string `json:"apiVersion"`
-	Kind       string `json:"kind"`
-	Name       string `json:"name"`
-	Namespace  string `json:"namespace"`
-}
-
-// applyClassResources applies resources defined in NamespaceClass to target Namespace using Server-Side Apply
-func (r *NamespaceReconciler) applyClassResources(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]inventoryItem, error) {
-	logger := log.FromContext(ctx)
-	var inventory []inventoryItem
-
-	for _, tmpl := range nsClass.Spec.Resources {
-		// Deserialize resource template
-		obj := &unstructured.Unstructured{}
-		if tmpl.Template.Object != nil {
-			u, ok := tmpl.Template.Object.(*unstructured.Unstructured)
-			if ok {
-				obj = u.DeepCopy() // Make a copy to avoid mutating original template
-			} else {
-				continue
-			}
-		} else {
-			if err := json.Unmarshal(tmpl.Template.Raw, obj); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal resource template: %w", err)
-			}
-		}
+// deletionPollInterval is how often a class waiting for namespace cleanup re-checks progress
+const deletionPollInterval = 5 * time.Second
 
-		// Configure object metadata
-		obj.SetNamespace(ns.Name)
-		labels := obj.GetLabels()
-		if labels == nil {
-			labels = make(map[string]string)
+// pendingCleanup returns the namespaces that still hold resources of a class being deleted and will remove them.
+// Namespaces that orphan their resources, or that are terminating themselves, are not waited for, except that a
+// namespace attaching it as an additional class is waited for either way, as it orphans the resources itself.
+func (r *NamespaceClassReconciler) pendingCleanup(ctx context.Context, nsClass *akuityv1.NamespaceClass, policy akuityv1.DeletionPolicy) ([]string, error) {
+	var nsList corev1.NamespaceList
+	if err := r.List(ctx, &nsList); err != nil {
+		return nil, err
+	}
+	var pending []string
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		if !ns.DeletionTimestamp.IsZero() {
+			continue
 		}
-		labels[ManagedByLabel] = ControllerName
-		labels[SourceClassLabel] = nsClass.Name
-		obj.SetLabels(labels)
-
-		// Set OwnerReference to Namespace for garbage collection
-		ownerRef := metav1.OwnerReference{
-			APIVersion:         "v1",
-			Kind:               "Namespace",
-			Name:               ns.Name,
-			UID:                ns.UID,
-			BlockOwnerDeletion: pointer.Bool(true),
-			Controller:         pointer.Bool(true),
+		if additionalClassAttached(ns, nsClass.Name) {
+			pending = append(pending, ns.Name)
+			continue
 		}
-		obj.SetOwnerReferences([]metav1.OwnerReference{ownerRef})
-
-		// Server-Side Apply (SSA)
-		// Use Patch instead of Create to update resources when Class changes
-		// Force=true means controller takes precedence in case of field conflicts
-		force := true
-		patchOpts := &client.PatchOptions{
-			FieldManager: ControllerName,
-			Force:        &force,
+		if ns.Annotations[AttachedClassAnnotation] != nsClass.Name {
+			continue
 		}
-
-		if err := r.Patch(ctx, obj, client.Apply, patchOpts); err != nil {
-			return nil, fmt.Errorf("failed to apply resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		if nsPolicy, _ := NamespaceDeletionPolicy(ns, policy); nsPolicy == akuityv1.DeletionPolicyCascade {
+			pending = append(pending, ns.Name)
 		}
-
-		logger.V(1).Info("Applied resource", "kind", obj.GetKind(), "name", obj.GetName())
-		appliedResourcesTotal.WithLabelValues(ns.Name, nsClass.Name, obj.GetKind()).Inc()
-
-		inventory = append(inventory, inventoryItem{
-			APIVersion: obj.GetAPIVersion(),
-			Kind:       obj.GetKind(),
-			Name:       obj.GetName(),
-			Namespace:  obj.GetNamespace(),
-		})
 	}
-
-	return inventory, nil
+	return pending, nil
 }
 
-// pruneOrphanedResources deletes resources that exist in old inventory but not in keep inventory
-func (r *NamespaceReconciler) pruneOrphanedResources(ctx context.Context, namespace string, old []inventoryItem, keep []inventoryItem, class string) error {
-	logger := log.FromContext(ctx)
-	keepMap := make(map[string]bool)
-	for _, k := range keep {
-		key := fmt.Sprintf("%s|%s|%s|%s", k.APIVersion, k.Kind, k.Namespace, k.Name)
-		keepMap[key] = true
+// updateDeletionStatus records cleanup progress in status.deletion. The total is fixed by the first observation so
+// progress only moves forward.
+func (r *NamespaceClassReconciler) updateDeletionStatus(ctx context.Context, nsClass *akuityv1.NamespaceClass, pending int) error {
+	total := int32(pending)
+	if d := nsClass.Status.Deletion; d != nil && d.Total > total {
+		total = d.Total
 	}
+	progress := &akuityv1.DeletionStatus{CleanedNamespaces: total - int32(pending), Total: total}
+	if d := nsClass.Status.Deletion; d != nil && *d == *progress {
+		return nil
+	}
+	patch := client.MergeFrom(nsClass.DeepCopy())
+	nsClass.Status.Deletion = progress
+	return r.Status().Patch(ctx, nsClass, patch)
+}
 
-	for _, item := range old {
-		key := fmt.Sprintf("%s|%s|%s|%s", item.APIVersion, item.Kind, item.Namespace, item.Name)
-		if keepMap[key] {
-			continue
-		}
-
-		// Build object to delete
-		u := &unstructured.Unstructured{}
-		u.SetAPIVersion(item.APIVersion)
-		u.SetKind(item.Kind)
-		u.SetName(item.Name)
-		u.SetNamespace(item.Namespace)
+// InventoryItem identifies one resource the controller manages in a Namespace
+type InventoryItem = engine.InventoryItem
 
-		logger.Info("Pruning orphaned resource", "kind", item.Kind, "name", item.Name)
-		if err := r.Delete(ctx, u); err != nil {
-			if !errors.IsNotFound(err) {
-				return err
+// applyClassResources applies resources defined in NamespaceClass to target Namespace and records the outcomes
+func (r *NamespaceReconciler) applyClassResources(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, old []InventoryItem) ([]InventoryItem, syncSummary, error) {
+	results, err := r.engine.Apply(ctx, ns, nsClass)
+	summary := summarizeApply(old, results)
+	r.reportAdopted(ctx, ns, nsClass, old, results)
+	r.reportBlocked(ctx, ns, nsClass.Name, engine.Blocked(results))
+	for _, res := range results {
+		switch res.Outcome {
+		case engine.OutcomeApplied:
+			appliedResourcesTotal.WithLabelValues(ns.Name, nsClass.Name, res.Item.Kind).Inc()
+			if r.ResourceEvents {
+				r.Recorder.Eventf(itemObject(res.Item), corev1.EventTypeNormal, ReasonApplied,
+					"Applied from NamespaceClass %s generation %d", nsClass.Name, nsClass.Generation)
 			}
+		case engine.OutcomeUnchanged:
+			skippedAppliesTotal.WithLabelValues(ns.Name, nsClass.Name, res.Item.Kind).Inc()
+		}
+	}
+	// Forced applies write every resource, so they say nothing about drift
+	if r.SkipUnchangedApplies && !engine.ForceApply(ctx) {
+		if drifted := countDrift(old, results); drifted > 0 {
+			r.markDrift(ctx, ns, nsClass, drifted)
 		}
+	}
+	if kind := engine.FailedKind(err); kind != "" && !engine.IsNamespaceTerminating(err) {
+		applyErrorsTotal.WithLabelValues(ns.Name, nsClass.Name, kind, engine.ClassifyError(err)).Inc()
+	}
+	// On failure the items applied so far are returned with the error
+	return engine.Items(results), summary, err
+}
+
+// itemObject returns an object reference for an inventory item that events can be recorded against
+func itemObject(item InventoryItem) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(item.APIVersion)
+	u.SetKind(item.Kind)
+	u.SetName(item.Name)
+	u.SetNamespace(item.Namespace)
+	return u
+}
+
+// pruneOrphanedResources deletes resources that exist in old inventory but not in keep inventory and returns how
+// many it deleted. Resources of RetainKinds, and resources whose API is no longer served, are reported instead.
+func (r *NamespaceReconciler) pruneOrphanedResources(ctx context.Context, old []InventoryItem, keep []InventoryItem, class string) (int, error) {
+	r.reportRetained(ctx, r.engine.Retained(old, keep), class)
+	r.reportUnserved(ctx, r.engine.Unserved(old, keep), class)
+	pruned, err := r.engine.Prune(ctx, old, keep)
+	for _, item := range pruned {
 		prunedResourcesTotal.WithLabelValues(item.Namespace, class, item.Kind).Inc()
 	}
-	return nil
+	return len(pruned), err
 }
 
-// cleanUpResources removes all managed resources from Namespace and clears inventory annotations
+// cleanUpResources removes all managed resources and the namespace metadata of the class from Namespace and clears
+// inventory annotations
 func (r *NamespaceReconciler) cleanUpResources(ctx context.Context, ns *corev1.Namespace, classFilter string) error {
 	old, err := r.getNamespaceInventory(ctx, ns)
 	if err != nil {
 		return err
 	}
+	removeLast := engine.HasNamespaceMetadata(ns) && r.metadataRemovedLast(ctx, classFilter)
+	if !removeLast {
+		if _, err := engine.ApplyNamespaceMetadata(ctx, r.Client, ns, nil); err != nil {
+			return err
+		}
+	}
+	if len(old) == 0 && r.InventoryLabelFallback {
+		if old, err = r.discoverCleanup(ctx, ns, classFilter); err != nil {
+			return err
+		}
+	}
 	// Set keep list to nil to delete all resources
-	if err := r.pruneOrphanedResources(ctx, ns.Name, old, nil, classFilter); err != nil {
+	_, err = r.pruneOrphanedResources(ctx, old, nil, classFilter)
+	r.recordStuck(ctx, ns, err)
+	if err != nil {
 		return err
 	}
+	if removeLast {
+		if _, err := engine.ApplyNamespaceMetadata(ctx, r.Client, ns, nil); err != nil {
+			return err
+		}
+	}
 	// Clear annotations
 	return r.setNamespaceInventory(ctx, ns, "", nil)
 }
 
-// getNamespaceInventory retrieves resource inventory from Namespace annotations
-func (r *NamespaceReconciler) getNamespaceInventory(ctx context.Context, ns *corev1.Namespace) ([]inventoryItem, error) {
-	ann := ns.GetAnnotations()
-	if ann == nil {
-		return nil, nil
-	}
-	raw, ok := ann[InventoryAnnotation]
-	if !ok || raw == "" {
-		return nil, nil
-	}
-	var items []inventoryItem
-	if err := json.Unmarshal([]byte(raw), &items); err != nil {
-		return nil, err
-	}
-	return items, nil
+// getNamespaceInventory retrieves resource inventory from the inventory store
+func (r *NamespaceReconciler) getNamespaceInventory(ctx context.Context, ns *corev1.Namespace) ([]InventoryItem, error) {
+	return r.engine.Inventory.Get(ctx, ns)
 }
 
-// setNamespaceInventory updates Namespace annotations with current resource inventory
-func (r *NamespaceReconciler) setNamespaceInventory(ctx context.Context, ns *corev1.Namespace, className string, items []inventoryItem) error {
-	patch := &corev1.Namespace{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "v1",
-			Kind:       "Namespace",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: ns.Name,
-		},
-	}
-
-	if items == nil || len(items) == 0 {
+// NamespaceInventory decodes the resource inventory stored in the Namespace annotations
+func NamespaceInventory(ns *corev1.Namespace) ([]InventoryItem, error) {
+	return engine.NamespaceInventory(ns)
+}
 
-		patch.Annotations = map[string]string{
-			InventoryAnnotation:     "",
-			AttachedClassAnnotation: "",
+// setNamespaceInventory persists the current resource inventory and publishes its size. Clearing the class also
+// clears the additional classes recorded as attached.
+func (r *NamespaceReconciler) setNamespaceInventory(ctx context.Context, ns *corev1.Namespace, className string, items []InventoryItem) error {
+	if err := r.engine.Inventory.Set(ctx, ns, className, items); err != nil {
+		return err
+	}
+	if className == "" {
+		if err := r.recordAdditionalClasses(ctx, ns, nil); err != nil {
+			return err
 		}
-
-		patch.Annotations = nil
-	} else {
+	}
+	size := 0
+	if len(items) > 0 {
 		b, err := json.Marshal(items)
 		if err != nil {
 			return err
 		}
-		patch.Annotations = map[string]string{
-			InventoryAnnotation:     string(b),
-			AttachedClassAnnotation: className,
-		}
+		size = len(b)
 	}
-
-	patchOpts := &client.PatchOptions{
-		FieldManager: ControllerName,
-	}
-	//aligned with controller
-	force := true
-	patchOpts.Force = &force
-
-	return r.Patch(ctx, patch, client.Apply, patchOpts, client.ForceOwnership)
+	recordInventorySize(ns.Name, len(items), size)
+	return nil
 }
 
 func indexByNamespaceClassLabel(obj client.Object) []string {
@@ -422,22 +900,37 @@ func indexByNamespaceClassLabel(obj client.Object) []string {
 	return []string{}
 }
 
-// findNamespacesForClass returns reconcile requests for all Namespaces referencing a specific NamespaceClass
+// findNamespacesForClass returns reconcile requests for all Namespaces referencing a specific NamespaceClass, or a
+// class inheriting from it, by their class label or as an additional class
 func (r *NamespaceReconciler) findNamespacesForClass(ctx context.Context, obj client.Object) []reconcile.Request {
 	nsClass := obj.(*akuityv1.NamespaceClass)
-	var nsList corev1.NamespaceList
-
-	// Use field indexer to efficiently find Namespaces with matching label
-	if err := r.List(ctx, &nsList, client.MatchingFields{
-		"namespaceClass": nsClass.Name,
-	}); err != nil {
-		log.FromContext(ctx).Error(err, "failed to list namespaces via index")
-		return []reconcile.Request{}
+	var namespaces []corev1.Namespace
+	for _, name := range append([]string{nsClass.Name}, r.inheritingClasses(ctx, nsClass.Name)...) {
+		for _, index := range []string{"namespaceClass", additionalClassIndex} {
+			var nsList corev1.NamespaceList
+			// Use field indexer to efficiently find Namespaces with matching label
+			if err := r.List(ctx, &nsList, client.MatchingFields{
+				index: name,
+			}); err != nil {
+				log.FromContext(ctx).Error(err, "failed to list namespaces via index")
+				return []reconcile.Request{}
+			}
+			namespaces = append(namespaces, nsList.Items...)
+		}
 	}
 
-	requests := make([]reconcile.Request, len(nsList.Items))
-	for i, ns := range nsList.Items {
-		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}}
+	return namespaceRequests(namespaces)
+}
+
+// namespaceRequests returns reconcile requests for namespaces, leaving out terminating ones which have nothing
+// left to sync
+func namespaceRequests(namespaces []corev1.Namespace) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(namespaces))
+	for i := range namespaces {
+		if terminating(&namespaces[i]) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespaces[i].Name}})
 	}
 	return requests
 }
@@ -445,6 +938,51 @@ func (r *NamespaceReconciler) findNamespacesForClass(ctx context.Context, obj cl
 // SetupWithManager registers ns reconcilers with the controller manager
 func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Recorder = mgr.GetEventRecorderFor(ControllerName)
+	r.startedAt = time.Now()
+	if r.Instance != "" {
+		instanceInfo.WithLabelValues(r.Instance).Set(1)
+	}
+	templates := &engine.TemplateRenderer{Templates: r.Templates, Renders: r.Renders, AnnotateSource: r.AnnotateSource, LabelGeneration: r.LabelGeneration, DenyCRDs: r.DenyCRDs, ForbiddenKinds: r.ForbiddenKinds, Protected: r.Protected, Instance: r.Instance}
+	if r.Profile != nil {
+		templates.Profile = r.Profile
+	}
+	var renderer engine.Renderer = templates
+	if r.RespectAutoscalers {
+		renderer = &engine.AutoscalerFilter{Base: renderer, Reader: r.Client}
+	}
+	if len(r.RegistryMirrors) > 0 {
+		renderer = &engine.ImageRewriter{Base: renderer, Mirrors: r.RegistryMirrors}
+	}
+	if len(r.ExternalRenderers) > 0 {
+		renderer = &engine.ExternalRenderer{Base: renderer, Plugins: r.ExternalRenderers, Timeout: r.ExternalRenderTimeout}
+	}
+	renderer = &engine.ClassComposer{Base: renderer, Class: r.additionalClass}
+	ssa := &engine.ServerSideApplier{Client: r.Client, FieldManager: ControllerName, SkipUnchanged: r.SkipUnchangedApplies, RespectConflicts: r.RespectConflicts}
+	appliers := engine.NewRegistry(r.Client, ssa)
+	for _, gvk := range r.ClientSideApplyKinds {
+		appliers.RegisterBase(gvk, &engine.ClientSideApplier{Client: r.Client, FieldManager: ControllerName})
+	}
+	var applier engine.Applier = appliers
+	if r.Faults != nil {
+		applier = &engine.FaultInjector{Base: applier, Faults: r.Faults, Client: r.Client}
+	}
+	if len(r.ApplyLimits) > 0 {
+		applier = engine.NewApplyLimiter(r.Client, applier, r.ApplyLimits)
+	}
+	var inventory engine.InventoryStore = &engine.AnnotationStore{Client: r.Client, FieldManager: ControllerName}
+	if r.InventoryConfigMaps {
+		inventory = &engine.ConfigMapStore{Client: r.Client, FieldManager: ControllerName}
+	}
+	r.engine = &engine.Engine{
+		Client:         r.Client,
+		Renderer:       renderer,
+		Applier:        applier,
+		Inventory:      inventory,
+		HashAnnotation: r.HashAnnotation,
+		DryRunApplier:  ssa,
+		RetainKinds:    r.RetainKinds,
+		ApplyOnceKinds: r.ApplyOnceKinds,
+	}
 
 	//Register field indexer for NamespaceClass label
 	if err := mgr.GetFieldIndexer().IndexField(
@@ -455,27 +993,113 @@ func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	); err != nil {
 		return fmt.Errorf("failed to register index: %w", err)
 	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Namespace{}, additionalClassIndex, indexByAdditionalClasses); err != nil {
+		return fmt.Errorf("failed to register index: %w", err)
+	}
+
+	var priorityOf func(reconcile.Request) int
+	if r.PrioritizeClasses {
+		priorityOf = func(req reconcile.Request) int { return namespacePriority(context.Background(), r.Client, req) }
+	}
+	opts, reconciler, err := controllerOptions(mgr, r, r.MaxConcurrentReconciles, r.Concurrency, priorityOf)
+	if err != nil {
+		return err
+	}
+	// Paced requests wait before taking an adaptive concurrency slot
+	if r.Pacing != nil {
+		reconciler = r.Pacing.Wrap(reconciler)
+	}
 
-	// Register NamespaceReconciler
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Namespace{}).
-		WithOptions(controller.Options{
-			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
-		}).
-		Watches(
-			&akuityv1.NamespaceClass{},
-			handler.EnqueueRequestsFromMapFunc(r.findNamespacesForClass),
-		).
-		Complete(r)
+	// Register NamespaceReconciler; every watch records why it enqueued a namespace
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		Named("namespace").
+		WithOptions(opts).
+		Watches(&corev1.Namespace{}, r.triggers.handler(namespaceRequest, triggerFor(TriggerNamespace))).
+		Watches(&akuityv1.NamespaceClass{}, r.triggers.handler(r.findNamespacesForClass, classTrigger))
+	if r.Profile != nil {
+		bldr = bldr.Watches(&corev1.ConfigMap{}, r.triggers.handler(r.findNamespacesForProfile, triggerFor(TriggerProfile)))
+	}
+	bldr, err = r.watchManaged(mgr, bldr)
+	if err != nil {
+		return err
+	}
+	return bldr.Complete(reconciler)
 }
 
 // SetupWithManager registers ns class reconcilers with the controller manager
 func (r *NamespaceClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor(ControllerName)
+	var priorityOf func(reconcile.Request) int
+	if r.PrioritizeClasses {
+		priorityOf = func(req reconcile.Request) int { return classPriority(context.Background(), r.Client, req) }
+	}
+	opts, reconciler, err := controllerOptions(mgr, r, r.MaxConcurrentReconciles, r.Concurrency, priorityOf)
+	if err != nil {
+		return err
+	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&akuityv1.NamespaceClass{}).
-		WithOptions(controller.Options{
-			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
-		}).
-		Complete(r)
+		WithOptions(opts)
+	if !r.AggregateStatus {
+		// Namespace syncs complete a rollout, which records the class baseline
+		bldr = bldr.Watches(&corev1.Namespace{}, batchedClassForNamespace(r.StatusBatchInterval))
+	}
+	return bldr.Complete(reconciler)
+}
+
+// controllerOptions returns the controller options for a fixed worker count, or with adaptive set, for up to
+// adaptive.Max workers limited by adaptive, along with the reconciler to register. With priorityOf set the
+// controller uses a priority queue ordered by it.
+func controllerOptions(mgr ctrl.Manager, r reconcile.Reconciler, workers int, adaptive *AdaptiveConcurrency, priorityOf func(reconcile.Request) int) (controller.Options, reconcile.Reconciler, error) {
+	opts := controller.Options{MaxConcurrentReconciles: workers}
+	if priorityOf != nil {
+		opts.UsePriorityQueue = ptr.To(true)
+		opts.NewQueue = newClassPriorityQueue(mgr.GetLogger(), priorityOf)
+	}
+	if adaptive == nil {
+		return opts, r, nil
+	}
+	if err := mgr.Add(adaptive); err != nil {
+		return controller.Options{}, nil, fmt.Errorf("failed to add adaptive concurrency: %w", err)
+	}
+	adaptive.newBase = opts.NewQueue
+	opts.MaxConcurrentReconciles = adaptive.Max
+	opts.NewQueue = adaptive.NewQueue
+	return opts, adaptive.Wrap(r), nil
+}
+
+// batchedClassForNamespace enqueues the class of a namespace after delay. The queue keeps the earliest time a
+// delayed request is due, so every event within the delay joins the request of the first.
+func batchedClassForNamespace(delay time.Duration) handler.EventHandler {
+	add := func(ctx context.Context, q workqueue.TypedRateLimitingInterface[reconcile.Request], objs ...client.Object) {
+		for _, obj := range objs {
+			for _, req := range classForNamespace(ctx, obj) {
+				q.AddAfter(req, delay)
+			}
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, e.Object)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, e.Object)
+		},
+	}
+}
+
+// classForNamespace maps a namespace to the class it is attached to
+func classForNamespace(_ context.Context, obj client.Object) []reconcile.Request {
+	className := obj.GetLabels()[NamespaceClassLabel]
+	if className == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: className}}}
 }
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclassnotifications,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclassnotifications/status,verbs=update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// defaultDegradedFor is how long a namespace must fail to sync before NamespaceDegraded fires by default
+const defaultDegradedFor = 10 * time.Minute
+
+// notificationPollInterval is how often notification rules are re-evaluated; degraded durations and rollouts are
+// derived from namespace state rather than watched
+const notificationPollInterval = 30 * time.Second
+
+// ReasonNotificationFailed is the event reason used when a provider rejects a notification
+const ReasonNotificationFailed = "NotificationFailed"
+
+// NotificationReconciler evaluates NamespaceClassNotification triggers against namespace and class state and sends
+// each event once to the configured providers
+type NotificationReconciler struct {
+	client.Client
+	// APIReader reads provider Secrets directly so the manager does not cache every Secret in the cluster
+	APIReader client.Reader
+	Recorder  record.EventRecorder
+	// Namespace holds the Secrets referenced by providers, typically the operator namespace
+	Namespace string
+	// Sender delivers notifications; defaults to HTTP delivery
+	Sender NotificationSender
+}
+
+// notificationEvent is one occurrence of a trigger. Key identifies what it is about (trigger, class and
+// namespace) and Identity which occurrence it is, so an event is sent once even across restarts.
+type notificationEvent struct {
+	Key      string
+	Identity string
+	Notification
+}
+
+func (r *NotificationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	var notif akuityv1.NamespaceClassNotification
+	if err := r.Get(ctx, req.NamespacedName, &notif); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	events, err := r.evaluate(ctx, &notif)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	delivered := make(map[string]string, len(events))
+	var failures []string
+	for _, ev := range events {
+		if notif.Status.Delivered[ev.Key] == ev.Identity {
+			delivered[ev.Key] = ev.Identity
+			continue
+		}
+		if errs := r.deliver(ctx, &notif, ev.Notification); len(errs) > 0 {
+			failures = append(failures, errs...)
+			// Keep the previous identity so the event is retried on the next evaluation
+			if prev, ok := notif.Status.Delivered[ev.Key]; ok {
+				delivered[ev.Key] = prev
+			}
+			continue
+		}
+		logger.Info("Sent notification", "trigger", ev.Trigger, "class", ev.Class, "namespace", ev.Namespace)
+		delivered[ev.Key] = ev.Identity
+	}
+
+	lastError := strings.Join(failures, "; ")
+	if !maps.Equal(delivered, notif.Status.Delivered) || lastError != notif.Status.LastError {
+		patch := client.MergeFrom(notif.DeepCopy())
+		notif.Status.Delivered = delivered
+		notif.Status.LastError = lastError
+		if err := r.Status().Patch(ctx, &notif, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: notificationPollInterval}, nil
+}
+
+// deliver sends n to every provider of notif and returns the failures
+func (r *NotificationReconciler) deliver(ctx context.Context, notif *akuityv1.NamespaceClassNotification, n Notification) []string {
+	var failures []string
+	for _, provider := range notif.Spec.Providers {
+		target, err := r.providerTarget(ctx, provider)
+		if err == nil {
+			err = r.Sender.Send(ctx, provider.Type, target, n)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name, err))
+			r.Recorder.Eventf(notif, corev1.EventTypeWarning, ReasonNotificationFailed,
+				"Failed to send %s notification to %s: %v", n.Trigger, provider.Name, err)
+		}
+	}
+	return failures
+}
+
+// providerTarget returns the URL, or for PagerDuty the routing key, of provider
+func (r *NotificationReconciler) providerTarget(ctx context.Context, provider akuityv1.NotificationProvider) (string, error) {
+	if provider.SecretRef == nil {
+		if provider.URL == "" {
+			return "", fmt.Errorf("provider has neither url nor secretRef")
+		}
+		return provider.URL, nil
+	}
+	var secret corev1.Secret
+	if err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: provider.SecretRef.Name}, &secret); err != nil {
+		return "", fmt.Errorf("failed to read secret %s/%s: %w", r.Namespace, provider.SecretRef.Name, err)
+	}
+	value, ok := secret.Data[provider.SecretRef.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %s", r.Namespace, provider.SecretRef.Name, provider.SecretRef.Key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// evaluate returns the events currently raised by the triggers of notif
+func (r *NotificationReconciler) evaluate(ctx context.Context, notif *akuityv1.NamespaceClassNotification) ([]notificationEvent, error) {
+	var classList akuityv1.NamespaceClassList
+	if err := r.List(ctx, &classList); err != nil {
+		return nil, err
+	}
+	var nsList corev1.NamespaceList
+	if err := r.List(ctx, &nsList, client.HasLabels{NamespaceClassLabel}); err != nil {
+		return nil, err
+	}
+
+	watched := func(class string) bool {
+		return len(notif.Spec.Classes) == 0 || slices.Contains(notif.Spec.Classes, class)
+	}
+	attached := map[string][]*corev1.Namespace{}
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		if class := ns.Labels[NamespaceClassLabel]; watched(class) && ns.DeletionTimestamp.IsZero() {
+			attached[class] = append(attached[class], ns)
+		}
+	}
+
+	now := time.Now()
+	var events []notificationEvent
+	for _, trigger := range notif.Spec.Triggers {
+		switch trigger.Type {
+		case akuityv1.TriggerRolloutComplete:
+			for i := range classList.Items {
+				nsClass := &classList.Items[i]
+				if !watched(nsClass.Name) || !rolloutComplete(ctx, r.Client, nsClass, attached[nsClass.Name]) {
+					continue
+				}
+				events = append(events, notificationEvent{
+					Key:      string(trigger.Type) + "/" + nsClass.Name,
+					Identity: fmt.Sprintf("generation-%d", nsClass.Generation),
+					Notification: Notification{
+						Trigger:  trigger.Type,
+						Class:    nsClass.Name,
+						Severity: SeverityInfo,
+						Message: fmt.Sprintf("NamespaceClass %s generation %d is synced in all %d namespaces",
+							nsClass.Name, nsClass.Generation, len(attached[nsClass.Name])),
+					},
+				})
+			}
+		case akuityv1.TriggerNamespaceDegraded:
+			degradedFor := defaultDegradedFor
+			if trigger.For != nil {
+				degradedFor = trigger.For.Duration
+			}
+			for class, namespaces := range attached {
+				for _, ns := range namespaces {
+					cond := syncedCondition(ns)
+					if cond == nil || cond.Status != corev1.ConditionFalse || now.Sub(cond.LastTransitionTime.Time) < degradedFor {
+						continue
+					}
+					events = append(events, notificationEvent{
+						Key:      string(trigger.Type) + "/" + class + "/" + ns.Name,
+						Identity: cond.LastTransitionTime.UTC().Format(time.RFC3339),
+						Notification: Notification{
+							Trigger:   trigger.Type,
+							Class:     class,
+							Namespace: ns.Name,
+							Severity:  SeverityError,
+							Message: fmt.Sprintf("Namespace %s has failed to sync NamespaceClass %s since %s: %s: %s",
+								ns.Name, class, cond.LastTransitionTime.UTC().Format(time.RFC3339), cond.Reason, cond.Message),
+						},
+					})
+				}
+			}
+		case akuityv1.TriggerDriftDetected:
+			for class, namespaces := range attached {
+				for _, ns := range namespaces {
+					stamp := ns.Annotations[DriftDetectedAnnotation]
+					detected, err := time.Parse(time.RFC3339, stamp)
+					// Drift reverted before the notification existed is history, not news
+					if err != nil || detected.Before(notif.CreationTimestamp.Time) {
+						continue
+					}
+					events = append(events, notificationEvent{
+						Key:      string(trigger.Type) + "/" + class + "/" + ns.Name,
+						Identity: stamp,
+						Notification: Notification{
+							Trigger:   trigger.Type,
+							Class:     class,
+							Namespace: ns.Name,
+							Severity:  SeverityWarning,
+							Message: fmt.Sprintf("Reverted out-of-band changes to resources of NamespaceClass %s in namespace %s at %s",
+								class, ns.Name, stamp),
+						},
+					})
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// rolloutComplete reports whether every namespace attached to nsClass synced its current generation
+func rolloutComplete(ctx context.Context, c client.Reader, nsClass *akuityv1.NamespaceClass, namespaces []*corev1.Namespace) bool {
+	if len(namespaces) == 0 {
+		return false
+	}
+	for _, ns := range namespaces {
+		cond := syncedCondition(ns)
+		if cond == nil || cond.Status != corev1.ConditionTrue || ns.Annotations[AttachedClassAnnotation] != nsClass.Name {
+			return false
+		}
+		items, err := engine.ReadInventory(ctx, c, ns)
+		if err != nil {
+			return false
+		}
+		for _, item := range items {
+			// Resources of additional classes follow the generation of their own class
+			if !item.Adopted && item.Class == "" && item.Generation != nsClass.Generation {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// syncedCondition returns the NamespaceClassSynced condition of ns, if any
+func syncedCondition(ns *corev1.Namespace) *corev1.NamespaceCondition {
+	for i := range ns.Status.Conditions {
+		if ns.Status.Conditions[i].Type == NamespaceClassSyncedCondition {
+			return &ns.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetupWithManager sets up the notification controller with the Manager
+func (r *NotificationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor(ControllerName)
+	if r.Sender == nil {
+		r.Sender = &HTTPSender{}
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&akuityv1.NamespaceClassNotification{}).
+		Complete(r)
+}
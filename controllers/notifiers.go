@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+)
+
+// Notification severities, mapped onto the PagerDuty severity of the same name
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Notification is the payload sent for one triggered event. Webhook providers receive it as JSON.
+type Notification struct {
+	Trigger   akuityv1.NotificationTriggerType `json:"trigger"`
+	Class     string                           `json:"class"`
+	Namespace string                           `json:"namespace,omitempty"`
+	Severity  string                           `json:"severity"`
+	Message   string                           `json:"message"`
+}
+
+// NotificationSender delivers a notification to a provider. Target is the provider URL, or the routing key for
+// PagerDuty.
+type NotificationSender interface {
+	Send(ctx context.Context, provider akuityv1.NotificationProviderType, target string, n Notification) error
+}
+
+// HTTPSender delivers notifications over HTTP
+type HTTPSender struct {
+	// Client defaults to an http.Client with a 10s timeout
+	Client *http.Client
+}
+
+var _ NotificationSender = &HTTPSender{}
+
+// Send implements NotificationSender
+func (s *HTTPSender) Send(ctx context.Context, provider akuityv1.NotificationProviderType, target string, n Notification) error {
+	var url string
+	var body any
+	switch provider {
+	case akuityv1.ProviderWebhook:
+		url, body = target, n
+	case akuityv1.ProviderSlack:
+		url, body = target, map[string]string{"text": n.Message}
+	case akuityv1.ProviderPagerDuty:
+		dedupKey := string(n.Trigger) + "/" + n.Class
+		if n.Namespace != "" {
+			dedupKey += "/" + n.Namespace
+		}
+		url, body = PagerDutyEventsURL, map[string]any{
+			"routing_key":  target,
+			"event_action": "trigger",
+			"dedup_key":    dedupKey,
+			"payload": map[string]string{
+				"summary":  n.Message,
+				"source":   ControllerName,
+				"severity": n.Severity,
+			},
+		}
+	default:
+		return fmt.Errorf("unknown provider type %q", provider)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("provider returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
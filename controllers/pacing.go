@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// StartupPacing spreads the reconciles that follow an operator restart, when every labeled namespace is queued at
+// once, so a restart on a big cluster does not spike API server latency. Reconciles start at Rate per second, the
+// rate grows linearly to MaxRate over RampUp, and pacing ends after RampUp.
+type StartupPacing struct {
+	// Rate is the number of reconciles per second allowed when the controller starts
+	Rate float64
+	// MaxRate is the rate reached at the end of RampUp; defaults to Rate
+	MaxRate float64
+	// RampUp is how long reconciles are paced
+	RampUp time.Duration
+	// Jitter delays each paced reconcile by a random duration up to Jitter, so requests queued together do not hit
+	// the API server in lockstep
+	Jitter time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	next  time.Time
+}
+
+// ParseStartupPacing parses comma-separated key=value pairs into StartupPacing, e.g.
+// "rate=5,max=50,ramp=5m,jitter=1s". rate and ramp are required.
+func ParseStartupPacing(spec string) (*StartupPacing, error) {
+	p := &StartupPacing{}
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("startup pacing %q must be key=value", pair)
+		}
+		switch key {
+		case "rate", "max":
+			r, err := strconv.ParseFloat(value, 64)
+			if err != nil || r <= 0 {
+				return nil, fmt.Errorf("startup pacing %q must be a positive number of reconciles per second", pair)
+			}
+			if key == "rate" {
+				p.Rate = r
+			} else {
+				p.MaxRate = r
+			}
+		case "ramp", "jitter":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("startup pacing %q must be a duration", pair)
+			}
+			if key == "ramp" {
+				p.RampUp = d
+			} else {
+				p.Jitter = d
+			}
+		default:
+			return nil, fmt.Errorf("startup pacing %q: unknown key %s", pair, key)
+		}
+	}
+	if p.Rate == 0 || p.RampUp == 0 {
+		return nil, fmt.Errorf("startup pacing requires rate and ramp")
+	}
+	if p.MaxRate == 0 {
+		p.MaxRate = p.Rate
+	}
+	if p.MaxRate < p.Rate {
+		return nil, fmt.Errorf("startup pacing max must not be below rate")
+	}
+	return p, nil
+}
+
+// Wrap paces the reconciles of r. The ramp-up starts with the first reconcile, i.e. once the manager has started
+// the controller, after leader election.
+func (p *StartupPacing) Wrap(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		if err := p.wait(ctx); err != nil {
+			return reconcile.Result{}, err
+		}
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// wait blocks until the current rate admits another reconcile
+func (p *StartupPacing) wait(ctx context.Context) error {
+	delay, paced := p.reserve(time.Now())
+	if !paced {
+		return nil
+	}
+	if p.Jitter > 0 {
+		delay += rand.N(p.Jitter)
+	}
+	if delay <= 0 {
+		return nil
+	}
+	log.FromContext(ctx).V(1).Info("Pacing reconcile after startup", "delay", delay)
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes the next reconcile slot and returns how long until it, or false once the ramp-up is over
+func (p *StartupPacing) reserve(now time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.start.IsZero() {
+		p.start, p.next = now, now
+	}
+	elapsed := now.Sub(p.start)
+	if elapsed >= p.RampUp {
+		return 0, false
+	}
+	slot := p.next
+	if slot.Before(now) {
+		slot = now
+	}
+	// The rate at the slot decides the spacing to the one after it
+	progress := min(float64(slot.Sub(p.start))/float64(p.RampUp), 1)
+	rate := p.Rate + (p.MaxRate-p.Rate)*progress
+	p.next = slot.Add(time.Duration(float64(time.Second) / rate))
+	return slot.Sub(now), true
+}
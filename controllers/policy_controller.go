@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclasspolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core.akuity.io,resources=namespaceclasspolicies/status,verbs=update;patch
+
+// AssignedByAnnotation names the NamespaceClassPolicy that set the class label of a namespace. Namespaces labeled
+// without it were assigned by hand and are never relabeled by a policy.
+const AssignedByAnnotation = "namespaceclass.akuity.io/assigned-by"
+
+// ExcludeAnnotation lists, comma-separated, the classes a namespace opts out of. Policies skip rules assigning an
+// excluded class and detach it from namespaces they assigned it to.
+const ExcludeAnnotation = "namespaceclass.akuity.io/exclude"
+
+// ReasonInvalidPolicy is the event reason used when a policy rule cannot be evaluated
+const ReasonInvalidPolicy = "InvalidPolicy"
+
+// policyRequest is the single request all policy and namespace events map to; assignments are evaluated across
+// all policies at once so their precedence is applied consistently
+var policyRequest = reconcile.Request{NamespacedName: client.ObjectKey{Name: "namespaceclass-policies"}}
+
+// ClassPolicyReconciler labels namespaces with the class chosen by NamespaceClassPolicies. Policies are evaluated
+// in name order and the first one that yields a class for a namespace wins.
+type ClassPolicyReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Denylist names namespaces policies never assign a class to; nil denies nothing
+	Denylist *NamespaceDenylist
+	// Exclusions records the namespaces that opt out of a class a policy assigns; share it with
+	// ClassSelectorReconciler so both are listed. Unset, the reconciler uses its own.
+	Exclusions *ExclusionStatus
+}
+
+// compiledRule is an AssignmentRule with its matchers parsed
+type compiledRule struct {
+	name     *regexp.Regexp
+	selector labels.Selector
+	class    string
+}
+
+func (r *ClassPolicyReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var policyList akuityv1.NamespaceClassPolicyList
+	if err := r.List(ctx, &policyList); err != nil {
+		return ctrl.Result{}, err
+	}
+	policies := policyList.Items
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	rules := make([][]compiledRule, len(policies))
+	for i := range policies {
+		rules[i] = r.compile(&policies[i])
+	}
+
+	var nsList corev1.NamespaceList
+	if err := r.List(ctx, &nsList); err != nil {
+		return ctrl.Result{}, err
+	}
+	assigned := map[string]int32{}
+	excluded := map[string][]string{}
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		if !ns.DeletionTimestamp.IsZero() || Expired(ns) || r.Denylist.Denies(ns.Name) != "" {
+			continue
+		}
+		current, by := ns.Labels[NamespaceClassLabel], ns.Annotations[AssignedByAnnotation]
+		// Classes attached by a namespaceSelector give way to policies
+		if current != "" && by == "" && ns.Annotations[SelectedByAnnotation] == "" {
+			continue
+		}
+		policy, class, skipped := assignClass(policies, rules, ns)
+		for _, name := range skipped {
+			excluded[name] = append(excluded[name], ns.Name)
+		}
+		if current != "" && excludedClasses(ns)[current] && class == "" {
+			r.detach(ctx, ns, current, by)
+			continue
+		}
+		if class == "" {
+			// Keep the previous assignment; dropping the label would remove the class resources
+			if current != "" {
+				assigned[by]++
+			}
+			continue
+		}
+		assigned[policy]++
+		if class == current && policy == by {
+			continue
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Labels[NamespaceClassLabel] = class
+		ns.Annotations[AssignedByAnnotation] = policy
+		delete(ns.Annotations, SelectedByAnnotation)
+		if err := r.Patch(ctx, ns, patch); err != nil {
+			// The namespace webhook rejects classes whose allowedNamespaces exclude the namespace
+			logger.Error(err, "failed to assign class", "namespace", ns.Name, "class", class, "policy", policy)
+			continue
+		}
+		logger.Info("Assigned NamespaceClass", "namespace", ns.Name, "class", class, "policy", policy, "previousClass", current)
+	}
+
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Status.AssignedNamespaces == assigned[policy.Name] {
+			continue
+		}
+		patch := client.MergeFrom(policy.DeepCopy())
+		policy.Status.AssignedNamespaces = assigned[policy.Name]
+		if err := r.Status().Patch(ctx, policy, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.Exclusions.Record(ctx, "policies", excluded)
+}
+
+// detach removes the class a policy assigned to ns after ns excluded it
+func (r *ClassPolicyReconciler) detach(ctx context.Context, ns *corev1.Namespace, class, policy string) {
+	patch := client.MergeFrom(ns.DeepCopy())
+	delete(ns.Labels, NamespaceClassLabel)
+	delete(ns.Annotations, AssignedByAnnotation)
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		log.FromContext(ctx).Error(err, "failed to detach excluded class", "namespace", ns.Name, "class", class, "policy", policy)
+		return
+	}
+	log.FromContext(ctx).Info("Detached excluded NamespaceClass", "namespace", ns.Name, "class", class, "policy", policy)
+}
+
+// excludedClasses returns the classes ns opts out of with ExcludeAnnotation
+func excludedClasses(ns *corev1.Namespace) map[string]bool {
+	value := ns.Annotations[ExcludeAnnotation]
+	if value == "" {
+		return nil
+	}
+	out := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+// compile parses the rules of policy, reporting and skipping invalid ones
+func (r *ClassPolicyReconciler) compile(policy *akuityv1.NamespaceClassPolicy) []compiledRule {
+	var out []compiledRule
+	for i, rule := range policy.Spec.Rules {
+		compiled := compiledRule{class: rule.Class}
+		var err error
+		if rule.NameRegex != "" {
+			compiled.name, err = regexp.Compile("^(?:" + rule.NameRegex + ")$")
+		}
+		if err == nil && rule.Selector != nil {
+			compiled.selector, err = metav1.LabelSelectorAsSelector(rule.Selector)
+		}
+		if err == nil && compiled.name == nil && compiled.selector == nil {
+			err = fmt.Errorf("rule sets neither nameRegex nor selector")
+		}
+		if err != nil {
+			r.Recorder.Eventf(policy, corev1.EventTypeWarning, ReasonInvalidPolicy, "Skipping rule %d: %v", i, err)
+			continue
+		}
+		out = append(out, compiled)
+	}
+	return out
+}
+
+// assignClass returns the first policy that yields a class for ns, and that class. Rules and default classes
+// yielding a class ns excludes are skipped; those classes are returned as the third value.
+func assignClass(policies []akuityv1.NamespaceClassPolicy, rules [][]compiledRule, ns *corev1.Namespace) (string, string, []string) {
+	exclude := excludedClasses(ns)
+	var skipped []string
+	yields := func(class string) bool {
+		if !exclude[class] {
+			return true
+		}
+		if !slices.Contains(skipped, class) {
+			skipped = append(skipped, class)
+		}
+		return false
+	}
+	for i := range policies {
+		for _, rule := range rules[i] {
+			if rule.name != nil && !rule.name.MatchString(ns.Name) {
+				continue
+			}
+			if rule.selector != nil && !rule.selector.Matches(labels.Set(ns.Labels)) {
+				continue
+			}
+			if yields(rule.class) {
+				return policies[i].Name, rule.class, skipped
+			}
+		}
+		if policies[i].Spec.DefaultClass != "" && yields(policies[i].Spec.DefaultClass) {
+			return policies[i].Name, policies[i].Spec.DefaultClass, skipped
+		}
+	}
+	return "", "", skipped
+}
+
+// SetupWithManager sets up the policy controller with the Manager
+func (r *ClassPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor(ControllerName)
+	if r.Exclusions == nil {
+		r.Exclusions = &ExclusionStatus{Client: r.Client}
+	}
+	enqueueAll := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{policyRequest}
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespaceclasspolicy").
+		Watches(&akuityv1.NamespaceClassPolicy{}, enqueueAll, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Namespace{}, enqueueAll, builder.WithPredicates(predicate.Or(
+			predicate.LabelChangedPredicate{},
+			predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetAnnotations()[ExcludeAnnotation] != e.ObjectNew.GetAnnotations()[ExcludeAnnotation]
+			}}))).
+		Complete(r)
+}
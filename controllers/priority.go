@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/priorityqueue"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newQueueFunc builds a controller work queue, see controller.Options.NewQueue
+type newQueueFunc func(name string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request]
+
+// classPriorityQueue is a controller-runtime priority queue that orders requests by the spec.priority of the class
+// they belong to. Events enqueued without a priority get the class priority, and those controller-runtime marks as
+// low priority (the initial list on startup, resyncs) get it lowered by handler.LowPriority, so a restart still
+// drains critical classes first. Requeues keep the priority they were dequeued with.
+type classPriorityQueue struct {
+	priorityqueue.PriorityQueue[reconcile.Request]
+	priorityOf func(reconcile.Request) int
+}
+
+// newClassPriorityQueue returns a newQueueFunc for priority queues that look up request priorities with priorityOf
+func newClassPriorityQueue(logger logr.Logger, priorityOf func(reconcile.Request) int) newQueueFunc {
+	return func(name string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+		q := priorityqueue.New(name, func(o *priorityqueue.Opts[reconcile.Request]) {
+			o.Log = logger.WithValues("controller", name)
+			o.RateLimiter = rateLimiter
+		})
+		return &classPriorityQueue{PriorityQueue: q, priorityOf: priorityOf}
+	}
+}
+
+// Add implements workqueue.TypedInterface
+func (q *classPriorityQueue) Add(item reconcile.Request) {
+	q.AddWithOpts(priorityqueue.AddOpts{}, item)
+}
+
+// AddAfter implements workqueue.TypedDelayingInterface
+func (q *classPriorityQueue) AddAfter(item reconcile.Request, after time.Duration) {
+	q.AddWithOpts(priorityqueue.AddOpts{After: after}, item)
+}
+
+// AddRateLimited implements workqueue.TypedRateLimitingInterface
+func (q *classPriorityQueue) AddRateLimited(item reconcile.Request) {
+	q.AddWithOpts(priorityqueue.AddOpts{RateLimited: true}, item)
+}
+
+// AddWithOpts implements priorityqueue.PriorityQueue
+func (q *classPriorityQueue) AddWithOpts(o priorityqueue.AddOpts, items ...reconcile.Request) {
+	if o.Priority != nil && *o.Priority != handler.LowPriority {
+		q.PriorityQueue.AddWithOpts(o, items...)
+		return
+	}
+	low := o.Priority != nil
+	for _, item := range items {
+		priority := q.priorityOf(item)
+		if low {
+			priority += handler.LowPriority
+		}
+		o.Priority = ptr.To(priority)
+		q.PriorityQueue.AddWithOpts(o, item)
+	}
+}
+
+// classPriority returns the spec.priority of the class named by req, or 0 when it cannot be read
+func classPriority(ctx context.Context, reader client.Reader, req reconcile.Request) int {
+	var nsClass akuityv1.NamespaceClass
+	if err := reader.Get(ctx, req.NamespacedName, &nsClass); err != nil {
+		return 0
+	}
+	return int(nsClass.Spec.Priority)
+}
+
+// namespacePriority returns the spec.priority of the class the namespace named by req is attached to, or 0
+func namespacePriority(ctx context.Context, reader client.Reader, req reconcile.Request) int {
+	var ns corev1.Namespace
+	if err := reader.Get(ctx, req.NamespacedName, &ns); err != nil {
+		return 0
+	}
+	className := ns.Labels[NamespaceClassLabel]
+	if className == "" {
+		return 0
+	}
+	return classPriority(ctx, reader, reconcile.Request{NamespacedName: client.ObjectKey{Name: className}})
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ClusterProfile reads the cluster profile from the data of a ConfigMap, typically in the operator namespace.
+// A missing ConfigMap is an empty profile.
+type ClusterProfile struct {
+	Reader    client.Reader
+	Namespace string
+	Name      string
+}
+
+// Profile implements engine.ProfileSource
+func (p *ClusterProfile) Profile(ctx context.Context) (map[string]string, error) {
+	var cm corev1.ConfigMap
+	if err := p.Reader.Get(ctx, types.NamespacedName{Namespace: p.Namespace, Name: p.Name}, &cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cm.Data, nil
+}
+
+// findNamespacesForProfile re-queues every namespace with a class when the cluster profile ConfigMap changes
+func (r *NamespaceReconciler) findNamespacesForProfile(ctx context.Context, obj client.Object) []reconcile.Request {
+	if r.Profile == nil || obj.GetNamespace() != r.Profile.Namespace || obj.GetName() != r.Profile.Name {
+		return nil
+	}
+	var nsList corev1.NamespaceList
+	if err := r.List(ctx, &nsList, client.HasLabels{NamespaceClassLabel}); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list namespaces for cluster profile change")
+		return nil
+	}
+	return namespaceRequests(nsList.Items)
+}
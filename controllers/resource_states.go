@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Resource states reported by namespaceclass_resources
+const (
+	// ResourceStateDesired counts resources the class templates render into its attached namespaces
+	ResourceStateDesired = "desired"
+	// ResourceStateApplied counts inventory entries written from the current class generation
+	ResourceStateApplied = "applied"
+	// ResourceStateDrifted counts inventory entries written from an older class generation
+	ResourceStateDrifted = "drifted"
+	// ResourceStateFailed counts desired resources missing from namespaces whose last sync failed
+	ResourceStateFailed = "failed"
+)
+
+var classResources = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "namespaceclass_resources",
+		Help: "Number of resources managed per class and kind, by state (desired, applied, drifted, failed)",
+	},
+	[]string{"class", "kind", "state"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(classResources)
+}
+
+// resourceCounts accumulates namespaceclass_resources samples keyed by class, kind and state
+type resourceCounts map[[3]string]int
+
+func (c resourceCounts) add(class, kind, state string, n int) {
+	if n > 0 {
+		c[[3]string{class, kind, state}] += n
+	}
+}
+
+// collectResourceStates recomputes namespaceclass_resources from the namespace inventories and sync conditions.
+// A class is fully converged when its applied count equals its desired count for every kind.
+func (c *IntrospectionCollector) collectResourceStates(ctx context.Context, namespaces []corev1.Namespace, classes []akuityv1.NamespaceClass) {
+	byName := make(map[string]*akuityv1.NamespaceClass, len(classes))
+	for i := range classes {
+		byName[classes[i].Name] = &classes[i]
+	}
+	var profile map[string]string
+	if c.Profile != nil {
+		profile, _ = c.Profile.Profile(ctx)
+	}
+
+	counts := resourceCounts{}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		if className := ns.Labels[NamespaceClassLabel]; className != "" {
+			if nsClass, ok := byName[className]; ok && ns.DeletionTimestamp.IsZero() {
+				c.countNamespace(ctx, counts, ns, nsClass, profile)
+			}
+		}
+	}
+
+	classResources.Reset()
+	for key, n := range counts {
+		classResources.WithLabelValues(key[0], key[1], key[2]).Set(float64(n))
+	}
+}
+
+// countNamespace adds the desired resources of nsClass in ns and the state of its inventory entries
+func (c *IntrospectionCollector) countNamespace(ctx context.Context, counts resourceCounts, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, profile map[string]string) {
+	desired := map[string]int{}
+	if objects, err := c.Templates.DecodedTemplates(nsClass); err == nil {
+		for i, obj := range objects {
+			if obj == nil {
+				continue
+			}
+			if cond := nsClass.Spec.Resources[i].Condition; cond != "" {
+				if ok, err := engine.EvaluateCondition(cond, ns, profile); err != nil || !ok {
+					continue
+				}
+			}
+			desired[obj.GetKind()]++
+		}
+	}
+
+	applied := map[string]int{}
+	if ns.Annotations[AttachedClassAnnotation] == nsClass.Name {
+		items, _ := engine.ReadInventory(ctx, c.Reader, ns)
+		for _, item := range items {
+			if item.Adopted || item.Class != "" {
+				continue
+			}
+			// Entries written before generations were recorded count as applied until the next reconcile
+			if item.Generation != 0 && item.Generation < nsClass.Generation {
+				counts.add(nsClass.Name, item.Kind, ResourceStateDrifted, 1)
+				continue
+			}
+			applied[item.Kind]++
+		}
+	}
+
+	failed := syncFailed(ns)
+	for kind, n := range desired {
+		counts.add(nsClass.Name, kind, ResourceStateDesired, n)
+		if failed {
+			counts.add(nsClass.Name, kind, ResourceStateFailed, n-applied[kind])
+		}
+	}
+	for kind, n := range applied {
+		counts.add(nsClass.Name, kind, ResourceStateApplied, n)
+	}
+}
+
+// syncFailed reports whether the last sync of ns failed
+func syncFailed(ns *corev1.Namespace) bool {
+	cond := syncedCondition(ns)
+	return cond != nil && cond.Status == corev1.ConditionFalse
+}
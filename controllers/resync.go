@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"context"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResyncAnnotation on a NamespaceClass requests that every attached namespace re-applies all resources, skipping
+// no-op detection, even though the generation is unchanged. Any new value, e.g. a timestamp, triggers a resync.
+const ResyncAnnotation = "namespaceclass.akuity.io/resync"
+
+// ResyncedAnnotation records on a Namespace the last ResyncAnnotation value it has honored
+const ResyncedAnnotation = "namespaceclass.akuity.io/resynced"
+
+// resyncRequested reports whether nsClass asks for a resync ns has not performed yet
+func resyncRequested(ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) bool {
+	requested := nsClass.Annotations[ResyncAnnotation]
+	return requested != "" && requested != ns.Annotations[ResyncedAnnotation]
+}
+
+// markResynced records that ns has honored the resync requested by nsClass
+func (r *NamespaceReconciler) markResynced(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) error {
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[ResyncedAnnotation] = nsClass.Annotations[ResyncAnnotation]
+	return r.Patch(ctx, ns, patch)
+}
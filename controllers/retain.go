@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	metrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// ReasonPruneRetained is the event reason used when a resource is left in place instead of being pruned because
+// the operator is configured to never delete its kind
+const ReasonPruneRetained = "PruneRetained"
+
+var retainedResourcesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "namespaceclass_retained_resources_total",
+		Help: "Total number of resources left in place instead of pruned because their kind is never pruned",
+	},
+	[]string{"namespace", "class", "kind"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(retainedResourcesTotal)
+}
+
+// reportRetained records the resources a prune left behind because of their kind. They are dropped from the
+// inventory, so this happens once per resource; afterwards they show up as orphans.
+func (r *NamespaceReconciler) reportRetained(ctx context.Context, retained []InventoryItem, class string) {
+	for _, item := range retained {
+		log.FromContext(ctx).Info("Retained resource instead of pruning it; delete or adopt it by hand",
+			"kind", item.Kind, "name", item.Name, "class", class)
+		r.Recorder.Eventf(itemObject(item), corev1.EventTypeWarning, ReasonPruneRetained,
+			"Not pruned because %s is never pruned automatically; NamespaceClass %s no longer manages it, delete or adopt it by hand", item.Kind, class)
+		retainedResourcesTotal.WithLabelValues(item.Namespace, class, item.Kind).Inc()
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReasonRolloutComplete is the reason of the event summarizing a class generation once every attached namespace
+// synced it
+const ReasonRolloutComplete = "RolloutComplete"
+
+// RolloutTracker sums the sync summaries of the namespaces of each class generation, and reports the totals when
+// the rollout of the generation completes. Totals are kept in memory for the last maxHistory generations of each
+// class. A nil RolloutTracker counts nothing.
+type RolloutTracker struct {
+	// Recorder records the RolloutComplete event on the class
+	Recorder record.EventRecorder
+
+	mu       sync.Mutex
+	rollouts map[string]map[int64]*rolloutTotals
+}
+
+// rolloutTotals accumulates the syncs of one class generation
+type rolloutTotals struct {
+	namespaces map[string]bool
+	summary    syncSummary
+}
+
+// record adds the summary of one sync of namespace to generation of class
+func (t *RolloutTracker) record(class string, generation int64, namespace string, s syncSummary) {
+	if t == nil || s == (syncSummary{}) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rollouts == nil {
+		t.rollouts = map[string]map[int64]*rolloutTotals{}
+	}
+	byGeneration := t.rollouts[class]
+	if byGeneration == nil {
+		byGeneration = map[int64]*rolloutTotals{}
+		t.rollouts[class] = byGeneration
+	}
+	totals := byGeneration[generation]
+	if totals == nil {
+		totals = &rolloutTotals{namespaces: map[string]bool{}}
+		byGeneration[generation] = totals
+		for g := range byGeneration {
+			if g <= generation-maxHistory {
+				delete(byGeneration, g)
+			}
+		}
+	}
+	totals.namespaces[namespace] = true
+	totals.summary.applied += s.applied
+	totals.summary.changed += s.changed
+	totals.summary.pruned += s.pruned
+	totals.summary.failed += s.failed
+}
+
+// summary returns the totals of generation of class
+func (t *RolloutTracker) summary(class string, generation int64) *akuityv1.RolloutSummary {
+	out := &akuityv1.RolloutSummary{}
+	if t == nil {
+		return out
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if totals := t.rollouts[class][generation]; totals != nil {
+		out.NamespacesTouched = int32(len(totals.namespaces))
+		out.Applied = int32(totals.summary.applied)
+		out.Changed = int32(totals.summary.changed)
+		out.Pruned = int32(totals.summary.pruned)
+		out.Failures = int32(totals.summary.failed)
+	}
+	return out
+}
+
+// forget drops the totals of classes that no longer exist
+func (t *RolloutTracker) forget(class string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.rollouts, class)
+}
+
+// reportRollout logs the completed rollout of entry and records it as a single Normal event on nsClass
+func (t *RolloutTracker) reportRollout(ctx context.Context, nsClass *akuityv1.NamespaceClass, entry akuityv1.RevisionHistory) {
+	if entry.Summary == nil || entry.CompletedAt == nil {
+		return
+	}
+	s := entry.Summary
+	duration := entry.CompletedAt.Sub(entry.StartedAt.Time).Round(time.Second)
+	log.FromContext(ctx).Info("Rollout complete", "generation", entry.Revision, "duration", duration.String(),
+		"namespacesTouched", s.NamespacesTouched, "applied", s.Applied, "changed", s.Changed, "pruned", s.Pruned,
+		"failures", s.Failures, "failedNamespaces", entry.FailedNamespaces)
+	if t == nil || t.Recorder == nil {
+		return
+	}
+	t.Recorder.Eventf(nsClass, corev1.EventTypeNormal, ReasonRolloutComplete,
+		"Generation %d rolled out in %s: %d namespaces touched, +%d applied, %d changed, %d pruned, %d failures",
+		entry.Revision, duration, s.NamespacesTouched, s.Applied, s.Changed, s.Pruned, s.Failures)
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SelectedByAnnotation names the NamespaceClass whose spec.namespaceSelector set the class label of a namespace.
+// The label is removed again once the namespace no longer matches.
+const SelectedByAnnotation = "namespaceclass.akuity.io/selected-by"
+
+const (
+	// ReasonInvalidSelector is the event reason used when the namespaceSelector of a class cannot be parsed
+	ReasonInvalidSelector = "InvalidSelector"
+	// ReasonSelectorConflict is the event reason used when several classes select the same namespace
+	ReasonSelectorConflict = "SelectorConflict"
+)
+
+// selectorRequest is the single request all class and namespace events map to; selectors are evaluated across all
+// classes at once so conflicts between them are resolved consistently
+var selectorRequest = reconcile.Request{NamespacedName: client.ObjectKey{Name: "namespaceclass-selectors"}}
+
+// ClassSelectorReconciler labels namespaces with the class whose spec.namespaceSelector matches them. When several
+// classes match, the one with the highest spec.priority wins, then the first by name.
+type ClassSelectorReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Denylist names namespaces selectors never attach a class to; nil denies nothing
+	Denylist *NamespaceDenylist
+	// Exclusions records the namespaces that opt out of a class selecting them; share it with
+	// ClassPolicyReconciler so both are listed. Unset, the reconciler uses its own.
+	Exclusions *ExclusionStatus
+
+	// conflicts holds the last conflict reported for each namespace, so it is reported once rather than on every
+	// reconcile
+	conflicts map[string]string
+}
+
+// selectingClass is a class with its namespaceSelector parsed
+type selectingClass struct {
+	class    *akuityv1.NamespaceClass
+	selector labels.Selector
+}
+
+func (r *ClassSelectorReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var classList akuityv1.NamespaceClassList
+	if err := r.List(ctx, &classList); err != nil {
+		return ctrl.Result{}, err
+	}
+	var classes []selectingClass
+	for i := range classList.Items {
+		nsClass := &classList.Items[i]
+		if nsClass.Spec.NamespaceSelector == nil || !nsClass.DeletionTimestamp.IsZero() {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(nsClass.Spec.NamespaceSelector)
+		if err != nil {
+			r.Recorder.Eventf(nsClass, corev1.EventTypeWarning, ReasonInvalidSelector, "Ignoring spec.namespaceSelector: %v", err)
+			continue
+		}
+		if selector.Empty() {
+			continue
+		}
+		classes = append(classes, selectingClass{class: nsClass, selector: selector})
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		a, b := classes[i].class, classes[j].class
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority > b.Spec.Priority
+		}
+		return a.Name < b.Name
+	})
+
+	var nsList corev1.NamespaceList
+	if err := r.List(ctx, &nsList); err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.conflicts == nil {
+		r.conflicts = map[string]string{}
+	}
+	live := map[string]bool{}
+	excluded := map[string][]string{}
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		live[ns.Name] = true
+		if !ns.DeletionTimestamp.IsZero() || Expired(ns) || r.Denylist.Denies(ns.Name) != "" {
+			continue
+		}
+		current, by := ns.Labels[NamespaceClassLabel], ns.Annotations[SelectedByAnnotation]
+		if current != "" && current != by {
+			// Labeled by hand, by a policy or by a claim
+			continue
+		}
+		matches, skipped := selectClasses(classes, ns)
+		for _, name := range skipped {
+			excluded[name] = append(excluded[name], ns.Name)
+		}
+		r.reportConflict(ns, matches)
+		if len(matches) == 0 {
+			if by != "" {
+				r.release(ctx, ns, by)
+			}
+			continue
+		}
+		class := matches[0]
+		if class == current {
+			continue
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Labels[NamespaceClassLabel] = class
+		ns.Annotations[SelectedByAnnotation] = class
+		if err := r.Patch(ctx, ns, patch); err != nil {
+			logger.Error(err, "failed to attach selected class", "namespace", ns.Name, "class", class)
+			continue
+		}
+		logger.Info("Attached NamespaceClass selecting the namespace", "namespace", ns.Name, "class", class, "previousClass", current)
+	}
+
+	for name := range r.conflicts {
+		if !live[name] {
+			delete(r.conflicts, name)
+		}
+	}
+	return ctrl.Result{}, r.Exclusions.Record(ctx, "selectors", excluded)
+}
+
+// selectClasses returns the names of the classes, in precedence order, whose selector matches ns and that ns may
+// attach: classes that spec.allowedNamespaces rules out are left out, and so are classes ns excludes with
+// ExcludeAnnotation, which are returned as the second value
+func selectClasses(classes []selectingClass, ns *corev1.Namespace) ([]string, []string) {
+	exclude := excludedClasses(ns)
+	var out, skipped []string
+	for _, c := range classes {
+		if !c.selector.Matches(labels.Set(ns.Labels)) {
+			continue
+		}
+		if allowed, err := NamespaceAllowed(c.class, ns); err != nil || !allowed {
+			continue
+		}
+		if exclude[c.class.Name] {
+			skipped = append(skipped, c.class.Name)
+			continue
+		}
+		out = append(out, c.class.Name)
+	}
+	return out, skipped
+}
+
+// reportConflict emits a warning on ns when more than one class selects it, naming the class that was attached
+func (r *ClassSelectorReconciler) reportConflict(ns *corev1.Namespace, matches []string) {
+	if len(matches) < 2 {
+		delete(r.conflicts, ns.Name)
+		return
+	}
+	message := fmt.Sprintf("Selected by NamespaceClasses %s; attaching %s, which takes precedence", strings.Join(matches, ", "), matches[0])
+	if r.conflicts[ns.Name] == message {
+		return
+	}
+	r.conflicts[ns.Name] = message
+	r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonSelectorConflict, message)
+}
+
+// release removes the class a selector attached to ns after ns stopped matching it, which cleans up its resources
+func (r *ClassSelectorReconciler) release(ctx context.Context, ns *corev1.Namespace, class string) {
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Labels[NamespaceClassLabel] == class {
+		delete(ns.Labels, NamespaceClassLabel)
+	}
+	delete(ns.Annotations, SelectedByAnnotation)
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		log.FromContext(ctx).Error(err, "failed to detach class no longer selecting the namespace", "namespace", ns.Name, "class", class)
+		return
+	}
+	log.FromContext(ctx).Info("Detached NamespaceClass no longer selecting the namespace", "namespace", ns.Name, "class", class)
+}
+
+// SetupWithManager sets up the selector controller with the Manager
+func (r *ClassSelectorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Recorder = mgr.GetEventRecorderFor(ControllerName)
+	if r.Exclusions == nil {
+		r.Exclusions = &ExclusionStatus{Client: r.Client}
+	}
+	enqueueAll := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{selectorRequest}
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespaceclassselector").
+		Watches(&akuityv1.NamespaceClass{}, enqueueAll, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Namespace{}, enqueueAll, builder.WithPredicates(predicate.Or(
+			predicate.LabelChangedPredicate{},
+			predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetAnnotations()[ExcludeAnnotation] != e.ObjectNew.GetAnnotations()[ExcludeAnnotation]
+			}}))).
+		Complete(r)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// SyncSLOBreachedCondition is set on a Namespace whose pending class change has not been synced within the
+// configured sync SLO, and removed once the namespace syncs
+const SyncSLOBreachedCondition corev1.NamespaceConditionType = "NamespaceClassSyncSLOBreached"
+
+// ReasonSyncSLOBreached is the condition and event reason of a namespace breaching the sync SLO
+const ReasonSyncSLOBreached = "SyncSLOBreached"
+
+var syncLatencySeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "namespaceclass_sync_latency_seconds",
+		Help:    "Time from a class being attached to a namespace or updated until the namespace synced it",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	},
+	[]string{"namespace", "class"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(syncLatencySeconds)
+}
+
+// pendingSync is a class change a namespace has not synced yet
+type pendingSync struct {
+	class string
+	since time.Time
+}
+
+// syncTracker remembers since when each namespace waits for a class change
+type syncTracker struct {
+	mu      sync.Mutex
+	pending map[string]pendingSync
+}
+
+// begin records that namespace waits for a change of class that started at since and returns when the wait
+// started. A namespace that is still waiting for an earlier change of the same class keeps its earlier start, so
+// changes piling up on a stuck namespace are measured from the first.
+func (t *syncTracker) begin(namespace, class string, since time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.pending[namespace]; ok && p.class == class && p.since.Before(since) {
+		return p.since
+	}
+	if t.pending == nil {
+		t.pending = make(map[string]pendingSync)
+	}
+	t.pending[namespace] = pendingSync{class: class, since: since}
+	return since
+}
+
+// finish forgets the change namespace waited for and returns it
+func (t *syncTracker) finish(namespace string) (pendingSync, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[namespace]
+	delete(t.pending, namespace)
+	return p, ok
+}
+
+// pendingSince returns when the change ns waits for started: the rollout start of revision recorded in the class
+// history for an update, or now for a new attachment, which nothing records. ok is false when the inventory of ns
+// already comes from revision, e.g. on a drift check.
+func pendingSince(ns *corev1.Namespace, revision *akuityv1.NamespaceClass, old []InventoryItem, now time.Time) (time.Time, bool) {
+	if ns.Annotations[AttachedClassAnnotation] != revision.Name {
+		return now, true
+	}
+	pending := false
+	for _, item := range old {
+		if !item.Adopted && item.Class == "" && item.Generation != revision.Generation {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		return time.Time{}, false
+	}
+	for _, entry := range revision.Status.History {
+		if entry.Revision == revision.Generation && entry.StartedAt.Time.Before(now) {
+			return entry.StartedAt.Time, true
+		}
+	}
+	return now, true
+}
+
+// trackSync starts timing the class change ns waits for, if any, and sets SyncSLOBreachedCondition once the wait
+// exceeds SyncSLO. A namespace that already runs revision stops being timed.
+func (r *NamespaceReconciler) trackSync(ctx context.Context, ns *corev1.Namespace, revision *akuityv1.NamespaceClass, old []InventoryItem) error {
+	now := time.Now()
+	since, ok := pendingSince(ns, revision, old, now)
+	if !ok {
+		r.syncs.finish(ns.Name)
+		return nil
+	}
+	since = r.syncs.begin(ns.Name, revision.Name, since)
+	if r.SyncSLO <= 0 || now.Sub(since) <= r.SyncSLO {
+		return nil
+	}
+	message := fmt.Sprintf("NamespaceClass %s generation %d not synced within the %s SLO, pending since %s",
+		revision.Name, revision.Generation, r.SyncSLO, since.UTC().Format(time.RFC3339))
+	if cond := namespaceCondition(ns, SyncSLOBreachedCondition); cond == nil || cond.Message != message {
+		r.Recorder.Event(ns, corev1.EventTypeWarning, ReasonSyncSLOBreached, message)
+	}
+	return r.setCondition(ctx, ns, SyncSLOBreachedCondition, corev1.ConditionTrue, ReasonSyncSLOBreached, message)
+}
+
+// completeSync observes how long ns waited for the class change it just synced and clears
+// SyncSLOBreachedCondition
+func (r *NamespaceReconciler) completeSync(ctx context.Context, ns *corev1.Namespace, className string) error {
+	if p, ok := r.syncs.finish(ns.Name); ok && p.class == className {
+		syncLatencySeconds.WithLabelValues(ns.Name, className).Observe(time.Since(p.since).Seconds())
+	}
+	return r.removeCondition(ctx, ns, SyncSLOBreachedCondition)
+}
+
+// namespaceCondition returns the condition of condType on ns, or nil if it is not set
+func namespaceCondition(ns *corev1.Namespace, condType corev1.NamespaceConditionType) *corev1.NamespaceCondition {
+	for i := range ns.Status.Conditions {
+		if ns.Status.Conditions[i].Type == condType {
+			return &ns.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ClassesFirst holds back namespace reconciles after startup until the class cache has synced and every
+// NamespaceClass has been reconciled once, so a burst of namespace reconciles does not race class finalizers and
+// status or report ClassMissing for classes the cache has not delivered yet
+type ClassesFirst struct {
+	Cache cache.Cache
+	// Classes reconciles each NamespaceClass, typically the NamespaceClassReconciler
+	Classes reconcile.Reconciler
+
+	once  sync.Once
+	ready chan struct{}
+}
+
+// NeedLeaderElection matches the controllers, which only run on the leader
+func (g *ClassesFirst) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable
+func (g *ClassesFirst) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("classes-first")
+	defer close(g.readyChan())
+
+	if !g.Cache.WaitForCacheSync(ctx) {
+		return fmt.Errorf("failed to wait for the NamespaceClass cache to sync")
+	}
+	var classList akuityv1.NamespaceClassList
+	if err := g.Cache.List(ctx, &classList); err != nil {
+		// Namespaces must not wait forever; the class controller catches up on its own
+		logger.Error(err, "failed to list NamespaceClasses, starting namespace reconciles")
+		return nil
+	}
+	start := time.Now()
+	for i := range classList.Items {
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&classList.Items[i])}
+		if _, err := g.Classes.Reconcile(ctx, req); err != nil {
+			logger.Error(err, "failed to reconcile NamespaceClass before namespaces", "class", req.Name)
+		}
+	}
+	logger.Info("Reconciled NamespaceClasses, starting namespace reconciles", "classes", len(classList.Items), "duration", time.Since(start))
+	return nil
+}
+
+// Wait blocks until Start has processed every class or ctx is done. A nil gate never blocks.
+func (g *ClassesFirst) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	select {
+	case <-g.readyChan():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *ClassesFirst) readyChan() chan struct{} {
+	g.once.Do(func() { g.ready = make(chan struct{}) })
+	return g.ready
+}
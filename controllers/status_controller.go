@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// defaultStatusInterval is how often ClassStatusReconciler re-aggregates a class when no interval is set
+const defaultStatusInterval = 30 * time.Second
+
+// ClassStatusReconciler periodically folds the NamespaceClassSynced conditions of the namespaces attached to a
+// class into its status (attachedNamespaces, syncedNamespaces, failedNamespaces, lastSyncTime and the Ready and
+// Degraded conditions) and records the last applied baseline. It runs on its own queue with a single worker, so the fan-in over every attached namespace
+// never delays namespace syncs.
+type ClassStatusReconciler struct {
+	client.Client
+	// Interval between aggregations of a class; defaults to 30s
+	Interval time.Duration
+	// RecordLastAppliedSpec keeps a compressed copy of the last fully rolled out spec in status.lastAppliedSpec
+	RecordLastAppliedSpec bool
+	// Rollouts summarizes each rollout as it completes; nil records it without totals
+	Rollouts *RolloutTracker
+}
+
+// Reconcile implements reconcile.Reconciler
+func (r *ClassStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var nsClass akuityv1.NamespaceClass
+	if err := r.Get(ctx, req.NamespacedName, &nsClass); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !nsClass.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// The aggregated counters and the baseline go out in one patch, so a class sees at most one status write per
+	// interval however many of its namespaces synced in between
+	err := patchClassStatus(ctx, r.Client, &nsClass, func(nsClass *akuityv1.NamespaceClass) (bool, error) {
+		return updateClassStatus(ctx, r.Client, nsClass, r.RecordLastAppliedSpec, r.Rollouts)
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultStatusInterval
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// updateClassStatus folds the sync state of the namespaces attached to nsClass into its status, along with the
+// Ready and Degraded conditions and the last applied baseline, and reports whether the status changed
+func updateClassStatus(ctx context.Context, c client.Reader, nsClass *akuityv1.NamespaceClass, recordSpec bool, rollouts *RolloutTracker) (bool, error) {
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList, client.MatchingLabels{NamespaceClassLabel: nsClass.Name}); err != nil {
+		return false, err
+	}
+	status := aggregateStatus(nsClass, nsList.Items)
+	changed := false
+	if status.AttachedNamespaces != nsClass.Status.AttachedNamespaces ||
+		!slices.Equal(status.SyncedNamespaces, nsClass.Status.SyncedNamespaces) ||
+		!slices.Equal(status.FailedNamespaces, nsClass.Status.FailedNamespaces) ||
+		status.FailedNamespaceCount != nsClass.Status.FailedNamespaceCount ||
+		!status.LastSyncTime.Equal(&nsClass.Status.LastSyncTime) {
+		nsClass.Status.AttachedNamespaces = status.AttachedNamespaces
+		nsClass.Status.SyncedNamespaces = status.SyncedNamespaces
+		nsClass.Status.FailedNamespaces = status.FailedNamespaces
+		nsClass.Status.FailedNamespaceCount = status.FailedNamespaceCount
+		nsClass.Status.LastSyncTime = status.LastSyncTime
+		changed = true
+		log.FromContext(ctx).V(1).Info("Updated aggregated status", "attached", status.AttachedNamespaces,
+			"synced", len(status.SyncedNamespaces), "failed", len(status.FailedNamespaces))
+	}
+	if nsClass.Status.ObservedGeneration != nsClass.Generation {
+		nsClass.Status.ObservedGeneration = nsClass.Generation
+		changed = true
+	}
+	if setHealthConditions(nsClass) {
+		changed = true
+	}
+	baselineChanged, err := updateBaseline(ctx, c, nsClass, recordSpec, rollouts)
+	return changed || baselineChanged, err
+}
+
+// patchClassStatus applies update to the status of nsClass and patches it when update reports a change. The patch
+// carries the resourceVersion it was computed from; on a conflict the class is read again and update reapplied, so
+// lists such as status.history are never overwritten with a stale copy.
+func patchClassStatus(ctx context.Context, c client.Client, nsClass *akuityv1.NamespaceClass, update func(*akuityv1.NamespaceClass) (bool, error)) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !first {
+			if err := c.Get(ctx, client.ObjectKeyFromObject(nsClass), nsClass); err != nil {
+				return err
+			}
+		}
+		first = false
+		base := nsClass.DeepCopy()
+		changed, err := update(nsClass)
+		if err != nil || !changed {
+			return err
+		}
+		return c.Status().Patch(ctx, nsClass, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// aggregateStatus summarizes the sync state of the namespaces labeled with nsClass. Terminating namespaces are not
+// counted, and namespaces that have not synced yet count as attached only.
+func aggregateStatus(nsClass *akuityv1.NamespaceClass, namespaces []corev1.Namespace) akuityv1.NamespaceClassStatus {
+	// lastSyncTime only moves forward, so it survives every namespace failing or detaching
+	status := akuityv1.NamespaceClassStatus{LastSyncTime: nsClass.Status.LastSyncTime}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		if !ns.DeletionTimestamp.IsZero() {
+			continue
+		}
+		status.AttachedNamespaces++
+		cond := syncedCondition(ns)
+		switch {
+		case cond == nil:
+		case cond.Status == corev1.ConditionTrue && ns.Annotations[AttachedClassAnnotation] == nsClass.Name:
+			status.SyncedNamespaces = append(status.SyncedNamespaces, ns.Name)
+			if status.LastSyncTime.Before(&cond.LastTransitionTime) {
+				status.LastSyncTime = cond.LastTransitionTime
+			}
+		case cond.Status == corev1.ConditionFalse:
+			status.FailedNamespaces = append(status.FailedNamespaces, ns.Name)
+		}
+	}
+	slices.Sort(status.SyncedNamespaces)
+	slices.Sort(status.FailedNamespaces)
+	status.FailedNamespaceCount = int32(len(status.FailedNamespaces))
+	return status
+}
+
+// SetupWithManager registers the status controller; it is triggered by new classes and spec changes and requeues
+// each class every Interval
+func (r *ClassStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespaceclass-status").
+		For(&akuityv1.NamespaceClass{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// NamespaceClassCRDName is the name of the NamespaceClass CustomResourceDefinition
+const NamespaceClassCRDName = "namespaceclasses.core.akuity.io"
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update;patch
+
+// StorageVersionMigrator rewrites every NamespaceClass in the storage version once at startup and then drops older
+// versions from the CRD's status.storedVersions, so a version can later be removed from the CRD safely
+type StorageVersionMigrator struct {
+	Client client.Client
+}
+
+// NeedLeaderElection makes only the leader rewrite objects
+func (m *StorageVersionMigrator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("storage-migration")
+
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: NamespaceClassCRDName}, crd); err != nil {
+		// Migration is best effort; the controllers keep working with objects stored in any served version
+		logger.Error(err, "failed to get CustomResourceDefinition, skipping storage version migration")
+		return nil
+	}
+	stored, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	storage := akuityv1.GroupVersion.Version
+	if len(stored) == 1 && stored[0] == storage {
+		return nil
+	}
+
+	logger.Info("Migrating NamespaceClasses to the storage version", "storedVersions", stored, "storageVersion", storage)
+	if err := m.rewriteAll(ctx); err != nil {
+		logger.Error(err, "storage version migration failed")
+		return nil
+	}
+
+	patch := client.MergeFrom(crd.DeepCopy())
+	if err := unstructured.SetNestedStringSlice(crd.Object, []string{storage}, "status", "storedVersions"); err != nil {
+		return err
+	}
+	if err := m.Client.Status().Patch(ctx, crd, patch); err != nil {
+		logger.Error(err, "failed to update storedVersions")
+		return nil
+	}
+	logger.Info("Storage version migration complete")
+	return nil
+}
+
+// rewriteAll issues an unchanged update for each NamespaceClass, which makes the API server re-encode it in the
+// storage version
+func (m *StorageVersionMigrator) rewriteAll(ctx context.Context) error {
+	var list akuityv1.NamespaceClassList
+	if err := m.Client.List(ctx, &list); err != nil {
+		return fmt.Errorf("failed to list NamespaceClasses: %w", err)
+	}
+	for _, item := range list.Items {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			var nsClass akuityv1.NamespaceClass
+			if err := m.Client.Get(ctx, types.NamespacedName{Name: item.Name}, &nsClass); err != nil {
+				return err
+			}
+			return m.Client.Update(ctx, &nsClass)
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to rewrite NamespaceClass %s: %w", item.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// StuckResourcesAnnotation lists the resources of a namespace that pruning could not remove
+const StuckResourcesAnnotation = engine.StuckResourcesAnnotation
+
+const (
+	// CleanupBlockedPrune is the cause of a blocked cleanup whose prune left resources behind
+	CleanupBlockedPrune = "prune"
+	// CleanupBlockedFinalizer is the cause of a blocked cleanup of a terminating namespace the operator's cleanup
+	// finalizer still holds
+	CleanupBlockedFinalizer = "finalizer"
+)
+
+var cleanupBlockedSince = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "namespaceclass_cleanup_blocked_since_seconds",
+		Help: "Unix time since when the cleanup of a namespace has been blocked, for namespaces blocked longer than --stuck-cleanup-threshold",
+	},
+	[]string{"namespace", "cause"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(cleanupBlockedSince)
+}
+
+// CleanupBlocked returns why and since when the cleanup of ns has been blocked: since its deletion for a terminating
+// namespace still held by engine.CleanupFinalizer, otherwise since the oldest of its recorded stuck resources. cause
+// is empty when nothing blocks it.
+func CleanupBlocked(ns *corev1.Namespace) (cause string, since time.Time) {
+	if ns.DeletionTimestamp != nil && controllerutil.ContainsFinalizer(ns, engine.CleanupFinalizer) {
+		return CleanupBlockedFinalizer, ns.DeletionTimestamp.Time
+	}
+	stuck, _ := engine.RecordedStuckResources(ns)
+	for _, s := range stuck {
+		if since.IsZero() || s.Since.Time.Before(since) {
+			since = s.Since.Time
+		}
+	}
+	if since.IsZero() {
+		return "", since
+	}
+	return CleanupBlockedPrune, since
+}
+
+// reportBlockedCleanup exports the blocked cleanup of ns once it has been blocked for StuckCleanupThreshold, and
+// forgets it otherwise
+func (r *NamespaceReconciler) reportBlockedCleanup(ns *corev1.Namespace) {
+	cause, since := CleanupBlocked(ns)
+	if cause == "" || time.Since(since) < r.StuckCleanupThreshold {
+		forgetBlockedCleanup(ns.Name)
+		return
+	}
+	for _, other := range []string{CleanupBlockedPrune, CleanupBlockedFinalizer} {
+		if other != cause {
+			cleanupBlockedSince.DeleteLabelValues(ns.Name, other)
+		}
+	}
+	cleanupBlockedSince.WithLabelValues(ns.Name, cause).Set(float64(since.Unix()))
+}
+
+// forgetBlockedCleanup stops exporting the blocked cleanup of namespace
+func forgetBlockedCleanup(namespace string) {
+	cleanupBlockedSince.DeletePartialMatch(prometheus.Labels{"namespace": namespace})
+}
+
+// recordStuck records the resources a prune failure left behind on ns, or clears the record when err reports
+// none. A resource that stays stuck keeps the time it first got stuck, so its age shows how long it has been
+// wedged; the failed sync itself is retried with the controller's backoff.
+func (r *NamespaceReconciler) recordStuck(ctx context.Context, ns *corev1.Namespace, err error) {
+	defer r.reportBlockedCleanup(ns)
+	stuck := engine.StuckResources(err)
+	previous, _ := engine.RecordedStuckResources(ns)
+	if len(stuck) == 0 && ns.Annotations[StuckResourcesAnnotation] == "" {
+		return
+	}
+	for i := range stuck {
+		for _, p := range previous {
+			if p.APIVersion == stuck[i].APIVersion && p.Kind == stuck[i].Kind && p.Name == stuck[i].Name && p.Since.Before(&stuck[i].Since) {
+				stuck[i].Since = p.Since
+			}
+		}
+	}
+
+	patch := client.MergeFrom(ns.DeepCopy())
+	if len(stuck) == 0 {
+		delete(ns.Annotations, StuckResourcesAnnotation)
+	} else {
+		raw, err := json.Marshal(stuck)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to encode stuck resources")
+			return
+		}
+		if ns.Annotations[StuckResourcesAnnotation] == string(raw) {
+			return
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[StuckResourcesAnnotation] = string(raw)
+	}
+	if err := r.Patch(ctx, ns, patch); err != nil {
+		log.FromContext(ctx).Error(err, "failed to record stuck resources")
+	}
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReasonSyncSummary is the reason of the event summarizing what one sync changed in a namespace
+const ReasonSyncSummary = "SyncSummary"
+
+// syncSummary counts the changes of one sync: resources created, changed in place, pruned, and failed steps
+type syncSummary struct {
+	applied int
+	changed int
+	pruned  int
+	failed  int
+}
+
+func (s syncSummary) String() string {
+	return fmt.Sprintf("+%d applied, %d changed, %d pruned, %d failed", s.applied, s.changed, s.pruned, s.failed)
+}
+
+// summarizeApply counts the written results that are new to the inventory as applied and the others as changed
+func summarizeApply(old []InventoryItem, results []engine.ApplyResult) syncSummary {
+	known := make(map[string]bool, len(old))
+	for _, item := range old {
+		known[item.Key()] = true
+	}
+	var s syncSummary
+	for _, res := range results {
+		if res.Outcome != engine.OutcomeApplied {
+			continue
+		}
+		if known[res.Item.Key()] {
+			s.changed++
+		} else {
+			s.applied++
+		}
+	}
+	return s
+}
+
+// reportSummary logs the summary and records it as a single Normal event on the namespace, and adds it to the
+// rollout of generation; fallback syncs pass 0 as they do not roll out the class. Syncs that changed nothing and did
+// not fail stay quiet.
+func (r *NamespaceReconciler) reportSummary(ctx context.Context, ns *corev1.Namespace, className string, generation int64, s syncSummary) {
+	if s == (syncSummary{}) {
+		return
+	}
+	if generation > 0 {
+		r.Rollouts.record(className, generation, ns.Name, s)
+	}
+	log.FromContext(ctx).Info("Sync summary", "class", className, "applied", s.applied, "changed", s.changed, "pruned", s.pruned, "failed", s.failed)
+	r.Recorder.Eventf(ns, corev1.EventTypeNormal, ReasonSyncSummary, "NamespaceClass %s: %s", className, s)
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Causes of a namespace reconcile, reported as the trigger log field and metric label
+const (
+	// TriggerNamespace is a change to the namespace itself
+	TriggerNamespace = "namespace"
+	// TriggerClass is the fan-out of a change to the attached class
+	TriggerClass = "class"
+	// TriggerProfile is the fan-out of a change to the cluster profile
+	TriggerProfile = "profile"
+	// TriggerResync is the periodic informer resync (--sync-period), which redelivers unchanged objects
+	TriggerResync = "resync"
+	// TriggerForceSync is a new value of ResyncAnnotation on the attached class
+	TriggerForceSync = "force-sync"
+	// TriggerDrift is the requeue after spec.driftCheckInterval
+	TriggerDrift = "drift"
+	// TriggerManagedResource is an edit or deletion of a managed resource of a kind in --watch-managed-kinds
+	TriggerManagedResource = "managed-resource"
+	// TriggerRequeue is any other requeue, including retries after errors
+	TriggerRequeue = "requeue"
+)
+
+var reconcileTriggersTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "namespaceclass_reconcile_triggers_total",
+		Help: "Total namespace reconciles by class and the cause that triggered them",
+	},
+	[]string{"class", "cause"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileTriggersTotal)
+}
+
+type triggerKey struct{}
+
+// withTrigger returns ctx carrying the cause of the reconcile
+func withTrigger(ctx context.Context, cause string) context.Context {
+	return context.WithValue(ctx, triggerKey{}, cause)
+}
+
+// TriggerFrom returns the cause of the reconcile ctx belongs to, or "" outside of a namespace reconcile
+func TriggerFrom(ctx context.Context) string {
+	cause, _ := ctx.Value(triggerKey{}).(string)
+	return cause
+}
+
+// triggerTracker remembers why requests were enqueued until they are reconciled. The queue folds repeated requests
+// into one, so the first event cause since the last reconcile is kept; a cause noted for a requeue only applies when
+// no event enqueued the request in the meantime.
+type triggerTracker struct {
+	mu       sync.Mutex
+	events   map[types.NamespacedName]string
+	requeues map[types.NamespacedName]string
+}
+
+// record notes the event cause of req unless an earlier one is pending
+func (t *triggerTracker) record(req reconcile.Request, cause string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.events == nil {
+		t.events = make(map[types.NamespacedName]string)
+	}
+	if _, ok := t.events[req.NamespacedName]; !ok {
+		t.events[req.NamespacedName] = cause
+	}
+}
+
+// recordRequeue notes the cause of the requeue the reconcile of req is about to ask for
+func (t *triggerTracker) recordRequeue(req reconcile.Request, cause string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.requeues == nil {
+		t.requeues = make(map[types.NamespacedName]string)
+	}
+	t.requeues[req.NamespacedName] = cause
+}
+
+// take returns and forgets the cause of req, TriggerRequeue when none was noted
+func (t *triggerTracker) take(req reconcile.Request) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cause, ok := t.events[req.NamespacedName]
+	if !ok {
+		cause, ok = t.requeues[req.NamespacedName]
+	}
+	delete(t.events, req.NamespacedName)
+	delete(t.requeues, req.NamespacedName)
+	if !ok {
+		return TriggerRequeue
+	}
+	return cause
+}
+
+// handler returns an event handler enqueueing the requests mapFn returns for the objects of each event and recording
+// causeOf for them. causeOf gets a nil old object for events other than updates.
+func (t *triggerTracker) handler(mapFn handler.MapFunc, causeOf func(old, obj client.Object) string) handler.EventHandler {
+	add := func(ctx context.Context, q workqueue.TypedRateLimitingInterface[reconcile.Request], cause string, objs ...client.Object) {
+		for _, obj := range objs {
+			for _, req := range mapFn(ctx, obj) {
+				t.record(req, cause)
+				q.Add(req)
+			}
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, causeOf(nil, e.Object), e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, causeOf(e.ObjectOld, e.ObjectNew), e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, causeOf(nil, e.Object), e.Object)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			add(ctx, q, causeOf(nil, e.Object), e.Object)
+		},
+	}
+}
+
+// triggerFor returns a causeOf function reporting cause, or TriggerResync for updates that did not change the object
+func triggerFor(cause string) func(old, obj client.Object) string {
+	return func(old, obj client.Object) string {
+		if old != nil && old.GetResourceVersion() == obj.GetResourceVersion() {
+			return TriggerResync
+		}
+		return cause
+	}
+}
+
+// classTrigger is triggerFor(TriggerClass), except that a changed ResyncAnnotation reports TriggerForceSync
+func classTrigger(old, obj client.Object) string {
+	if old != nil && old.GetAnnotations()[ResyncAnnotation] != obj.GetAnnotations()[ResyncAnnotation] {
+		return TriggerForceSync
+	}
+	return triggerFor(TriggerClass)(old, obj)
+}
+
+// namespaceRequest maps a namespace to its own request
+func namespaceRequest(_ context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetName()}}}
+}
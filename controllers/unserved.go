@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReasonAPIUnavailable is the event reason used when inventory entries are dropped because the API server no
+// longer serves their API version
+const ReasonAPIUnavailable = "APIUnavailable"
+
+// reportUnserved records the inventory entries a prune dropped because their API was removed, e.g. by a cluster
+// upgrade. The event goes to the namespace since the resources themselves can no longer be looked up.
+func (r *NamespaceReconciler) reportUnserved(ctx context.Context, unserved []InventoryItem, class string) {
+	for _, item := range unserved {
+		log.FromContext(ctx).Info("Dropping inventory entry whose API is no longer served",
+			"apiVersion", item.APIVersion, "kind", item.Kind, "name", item.Name, "class", class)
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: item.Namespace}}
+		r.Recorder.Eventf(ns, corev1.EventTypeWarning, ReasonAPIUnavailable,
+			"Dropped %s %s from the inventory of NamespaceClass %s: %s is no longer served by the API server", item.Kind, item.Name, class, item.APIVersion)
+	}
+}
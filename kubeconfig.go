@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// connectionOptions select the cluster and identity the manager talks to. --kubeconfig itself is registered by
+// controller-runtime.
+type connectionOptions struct {
+	context     string
+	as          string
+	asGroups    string
+	safeDryRun  bool
+	kubeconfig  string
+	serviceHost string
+}
+
+func (o *connectionOptions) bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.context, "context", "", "The kubeconfig context to use when running out of cluster.")
+	fs.StringVar(&o.as, "as", "", "Username to impersonate for every API request.")
+	fs.StringVar(&o.asGroups, "as-group", "", "Comma-separated groups to impersonate for every API request. Requires --as.")
+	fs.BoolVar(&o.safeDryRun, "out-of-cluster-dry-run", true,
+		"When running out of cluster, send every write as a server-side dry run and skip leader election so a local "+
+			"run cannot change the cluster or take over from the deployed operator.")
+}
+
+// outOfCluster reports whether the manager runs from a kubeconfig rather than its service account
+func (o *connectionOptions) outOfCluster() bool {
+	if f := flag.Lookup(config.KubeconfigFlagName); f != nil && f.Value.String() != "" {
+		return true
+	}
+	return os.Getenv("KUBECONFIG") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") == ""
+}
+
+// dryRun reports whether writes must be dry runs
+func (o *connectionOptions) dryRun() bool {
+	return o.safeDryRun && o.outOfCluster()
+}
+
+// restConfig loads the client configuration for --context and applies the impersonation flags
+func (o *connectionOptions) restConfig() (*rest.Config, error) {
+	cfg, err := config.GetConfigWithContext(o.context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client configuration: %w", err)
+	}
+	if o.asGroups != "" && o.as == "" {
+		return nil, fmt.Errorf("--as-group requires --as")
+	}
+	if o.as != "" {
+		cfg.Impersonate = rest.ImpersonationConfig{UserName: o.as}
+		if o.asGroups != "" {
+			cfg.Impersonate.Groups = strings.Split(o.asGroups, ",")
+		}
+	}
+	return cfg, nil
+}
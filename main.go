@@ -3,15 +3,33 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	v1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/api/v1beta1"
 	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	"github.com/lixu/namespaceclass-operator/query"
+	"github.com/lixu/namespaceclass-operator/webhooks"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var (
@@ -21,38 +39,270 @@ var (
 
 func init() {
 	_ = corev1.AddToScheme(scheme)
+	_ = autoscalingv2.AddToScheme(scheme)
+	_ = authenticationv1.AddToScheme(scheme)
+	_ = authorizationv1.AddToScheme(scheme)
 	_ = v1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
 }
 
 func main() {
 	var enableLeaderElection bool
 	var probeAddr string
+	var metricsAddr string
+	var pprofAddr string
+	var localhostOnly string
+	var ipFamily string
 
 	var concurrentNsReconciles int
 	var concurrentNsClassReconciles int
+	var adaptiveConcurrency bool
+	var minNsReconciles int
+	var minNsClassReconciles int
+	var classMissingRequeue time.Duration
+	var skipUnchangedApplies bool
+	var fieldConflicts string
+	var inventoryStore string
+	var inventoryLabelFallback bool
+	var maxDiscoveredPrune int
+	var heapLogInterval time.Duration
+	var enableWebhooks bool
+	var webhookPort int
+	var webhookCertDir string
+	var migrateStorageVersion bool
+	var annotateSource bool
+	var resourceEvents bool
+	var syncPeriod time.Duration
+	var respectAutoscalers bool
+	var operatorNamespace string
+	var clusterProfile string
+	var denyProtectedNamespaceDeletion bool
+	var queryAPIAddr string
+	var queryAPICertDir string
+	var dashboard bool
+	var enableNotifications bool
+	var enableClassPolicies bool
+	var enableClassSelectors bool
+	var enableClassClaims bool
+	var autoLabel string
+	var recordLastAppliedSpec bool
+	var renderHashAnnotation bool
+	var classStatusInterval time.Duration
+	var collisionInterval time.Duration
+	var classStatusBatch time.Duration
+	var syncSLO time.Duration
+	var stuckCleanupThreshold time.Duration
+	var classPriorities bool
+	var clientSideApplyKinds string
+	var neverPruneKinds string
+	var applyOnceKinds string
+	var watchManagedKinds string
+	var forbiddenKinds string
+	var registryMirrors string
+	var externalRenderers string
+	var adoptExisting bool
+	var externalRenderTimeout time.Duration
+	var applyLimits string
+	var faultInjection string
+	var startupPacing string
+	var deniedNamespaces string
+	var deniedNamespacesFile string
+	var protectOperator bool
+	var instanceID string
+	var classesFirst bool
+	var labelGeneration bool
+	var allowCRDTemplates bool
 
 	flag.StringVar(&probeAddr, "health-probe-addr", ":8081", "The address the health probe endpoint binds to.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
+		"The address the metrics endpoint binds to, e.g. :8080 (all addresses of both IP families) or [::1]:8080. Use 0 to disable.")
+	flag.StringVar(&pprofAddr, "pprof-bind-address", "", "The address the pprof endpoint binds to. Disabled when empty.")
+	flag.StringVar(&localhostOnly, "localhost-only", "",
+		"Comma-separated endpoints (probes, metrics, pprof) that only listen on the loopback interface.")
+	flag.StringVar(&ipFamily, "ip-family", "auto",
+		"Loopback address family for --localhost-only: auto, ipv4 or ipv6. auto picks IPv6 only when the node has no IPv4 loopback.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election to ensure high availability.")
 
-	//concurrentNsReconciles and concurrentNsClassReconciles are used to set the MaxConcurrentReconciles.
+	// concurrentNsReconciles and concurrentNsClassReconciles set the MaxConcurrentReconciles of their own controller
 	flag.IntVar(&concurrentNsReconciles, "concurrent-ns-reconciles", 10, "The max number of concurrent Reconciles for Namespace objects.")
 	flag.IntVar(&concurrentNsClassReconciles, "concurrent-nsclass-reconciles", 5, "The max number of concurrent Reconciles for NamespaceClass objects.")
+	flag.BoolVar(&adaptiveConcurrency, "adaptive-concurrency", false,
+		"Scale each controller's concurrent Reconciles with its queue depth, between the --min-* value and the --concurrent-* value.")
+	flag.IntVar(&minNsReconciles, "min-ns-reconciles", 2, "The min number of concurrent Reconciles for Namespace objects with --adaptive-concurrency.")
+	flag.IntVar(&minNsClassReconciles, "min-nsclass-reconciles", 1, "The min number of concurrent Reconciles for NamespaceClass objects with --adaptive-concurrency.")
+	flag.DurationVar(&classMissingRequeue, "class-missing-requeue", time.Minute,
+		"How often to re-check a namespace whose NamespaceClass does not exist. Zero disables requeueing.")
+	flag.StringVar(&inventoryStore, "inventory-store", "annotation",
+		"Where each namespace's inventory is kept: annotation on the Namespace, or configmap for a namespaceclass-inventory ConfigMap in it. Switching to configmap moves existing inventories as namespaces sync.")
+	flag.BoolVar(&inventoryLabelFallback, "inventory-label-fallback", false,
+		"When a namespace has no inventory, prune and clean up the resources labeled as managed by its class instead, so cleanup still works after the inventory was lost.")
+	flag.IntVar(&maxDiscoveredPrune, "max-discovered-prune", 10,
+		"The most resources --inventory-label-fallback may prune from one namespace at once; larger prunes wait for the namespace to be annotated namespaceclass.akuity.io/allow-mass-prune=<namespace>. Zero allows any number.")
+	flag.StringVar(&fieldConflicts, "field-conflicts", "Force",
+		"What applies do with fields another field manager owns: Force takes them over, Respect fails the apply instead. Kinds in a class's spec.forceOwnershipKinds are always forced.")
+	flag.BoolVar(&skipUnchangedApplies, "skip-unchanged-applies", true,
+		"Skip server-side applies when the fields owned by the controller already match the class templates.")
+	flag.DurationVar(&heapLogInterval, "heap-log-interval", 0,
+		"How often to log a heap summary for sizing memory limits. Zero disables heap logging.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the validating admission webhooks. Requires a serving certificate in --webhook-cert-dir.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server binds to.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "",
+		"Directory containing tls.crt and tls.key for the webhook server. Defaults to <tmp>/k8s-webhook-server/serving-certs.")
+	flag.BoolVar(&migrateStorageVersion, "migrate-storage-version", true,
+		"Rewrite NamespaceClasses stored in an older API version at startup and prune them from the CRD's storedVersions.")
+	flag.BoolVar(&annotateSource, "annotate-source", false,
+		"Annotate applied resources with the generation of the NamespaceClass they were rendered from.")
+	flag.BoolVar(&resourceEvents, "resource-events", false,
+		"Emit a Normal event on every resource the controller writes, naming its NamespaceClass and generation.")
+	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Hour,
+		"How often every namespace is reconciled even without changes. Classes can re-check drift sooner with spec.driftCheckInterval.")
+	flag.BoolVar(&respectAutoscalers, "respect-autoscalers", true,
+		"Leave spec.replicas of HorizontalPodAutoscaler targets and container resources of VerticalPodAutoscaler targets to the autoscalers.")
+	flag.StringVar(&operatorNamespace, "operator-namespace", envOr("POD_NAMESPACE", "namespaceclass-operator"),
+		"The namespace the operator runs in, holding the cluster profile ConfigMap.")
+	flag.StringVar(&clusterProfile, "cluster-profile", "namespaceclass-cluster-profile",
+		"Name of the ConfigMap in --operator-namespace whose data is the cluster profile for template conditions. Empty disables it.")
+	flag.BoolVar(&denyProtectedNamespaceDeletion, "deny-protected-namespace-deletion", false,
+		"Reject deleting a namespace that holds resources with deletionProtection instead of only warning. Requires --enable-webhooks.")
+	flag.StringVar(&queryAPIAddr, "query-api-addr", "",
+		"The address the read-only query API binds to, e.g. :8443. Empty disables it.")
+	flag.StringVar(&queryAPICertDir, "query-api-cert-dir", "",
+		"Directory holding tls.crt and tls.key for the query API. Empty serves plain HTTP.")
+	flag.BoolVar(&dashboard, "dashboard", false,
+		"Serve a read-only status page under /ui/ of the query API. Requires --query-api-addr.")
+	flag.BoolVar(&enableNotifications, "enable-notifications", false,
+		"Run the controller sending NamespaceClassNotification alerts. Requires the NamespaceClassNotification CRD.")
+	flag.BoolVar(&enableClassPolicies, "enable-class-policies", false,
+		"Run the controller assigning classes to namespaces from NamespaceClassPolicies. Requires the NamespaceClassPolicy CRD.")
+	flag.BoolVar(&enableClassSelectors, "enable-class-selectors", true,
+		"Run the controller attaching classes to the namespaces their spec.namespaceSelector matches.")
+	flag.BoolVar(&enableClassClaims, "enable-class-claims", false,
+		"Run the controller binding NamespaceClassClaims to their namespaces. Requires the NamespaceClassClaim CRD.")
+	flag.StringVar(&autoLabel, "auto-label-namespaces", "",
+		"Comma-separated pattern=class pairs, e.g. pr-*=ephemeral. New namespaces matching a glob pattern get the class of the first match.")
+	flag.BoolVar(&recordLastAppliedSpec, "record-last-applied-spec", false,
+		"Keep a compressed copy of each class's last fully rolled out spec in status.lastAppliedSpec for diffing.")
+	flag.BoolVar(&renderHashAnnotation, "render-hash-annotation", true,
+		"Stamp every managed resource with the namespaceclass.akuity.io/render-hash annotation.")
+	flag.BoolVar(&labelGeneration, "label-class-generation", false,
+		"Label managed resources with namespaceclass.akuity.io/class-generation and prune labeled resources of earlier generations missing from the inventory. Rewrites every resource when a class changes.")
+	flag.BoolVar(&allowCRDTemplates, "allow-crd-templates", true,
+		"Let classes template CustomResourceDefinitions. They are applied before other resources and waited on until established.")
+	flag.BoolVar(&classesFirst, "classes-first", true,
+		"On startup, reconcile every NamespaceClass once before namespace reconciles begin.")
+	flag.StringVar(&clientSideApplyKinds, "client-side-apply-kinds", "",
+		"Comma-separated kinds, as Kind.version.group or Kind.version for core kinds, applied with three-way merge patches instead of server-side apply.")
+	flag.StringVar(&neverPruneKinds, "never-prune-kinds", "",
+		"Comma-separated kinds, as Kind.group or Kind for core kinds (e.g. PersistentVolumeClaim), that are never deleted when pruning. Such resources are dropped from the inventory and reported with a PruneRetained event instead.")
+	flag.StringVar(&applyOnceKinds, "apply-once-kinds", "",
+		"Comma-separated kinds, as Kind.group or Kind for core kinds, that are created once and never re-applied or drift-corrected, as if every template of them set updatePolicy IfNotPresent.")
+	flag.StringVar(&watchManagedKinds, "watch-managed-kinds", "",
+		"Comma-separated kinds, as Kind.version.group or Kind.version for core kinds (e.g. ConfigMap.v1,Role.v1.rbac.authorization.k8s.io), whose managed resources are watched so that editing or deleting one reverts it right away instead of at the next --sync-period.")
+	flag.StringVar(&forbiddenKinds, "forbidden-template-kinds", "",
+		"Comma-separated kinds, as Kind.group or Kind for core kinds (e.g. ClusterRoleBinding.rbac.authorization.k8s.io), that classes may not template. The class webhook rejects such classes and their renders fail.")
+	flag.StringVar(&registryMirrors, "image-registry-mirrors", "",
+		"Comma-separated registry=mirror pairs, e.g. docker.io=mirror.internal/dockerhub or *=mirror.internal, that container images of rendered workloads are rewritten to pull from. The first matching registry wins.")
+	flag.BoolVar(&adoptExisting, "adopt-existing-resources", false,
+		"On the first sync of a namespace created before the operator started, record the resources that already exist in its inventory instead of rewriting them. They are written once their template changes or a resync is requested.")
+	flag.StringVar(&externalRenderers, "external-renderers", "",
+		"Comma-separated name=exec:<path> or name=<http(s) URL> pairs configuring the external renderers that classes select with spec.renderer.")
+	flag.DurationVar(&externalRenderTimeout, "external-render-timeout", engine.DefaultExternalRenderTimeout,
+		"How long a call to an external renderer may take before the render fails.")
+	flag.StringVar(&applyLimits, "apply-concurrency-limits", "",
+		"Comma-separated target=max pairs capping concurrent applies across all namespace reconciles per API group or kind (Kind.group), e.g. external-secrets.io=20,ExternalSecret.external-secrets.io=5. A kind limit takes precedence over its group's.")
+	flag.StringVar(&startupPacing, "startup-pacing", "",
+		"Pace namespace reconciles after the operator starts, e.g. rate=5,max=50,ramp=5m,jitter=1s: start at rate reconciles per second, grow linearly to max over ramp, and delay each by up to jitter.")
+	flag.StringVar(&faultInjection, "fault-injection", "",
+		"For testing only: inject apply failures and latency, e.g. errors=0.2,reason=Timeout,latency=2s,kind=ConfigMap,namespace=staging-*. Never enable in production.")
+	flag.StringVar(&deniedNamespaces, "denied-namespaces", "",
+		"Comma-separated namespaces that never get a class, as glob patterns (openshift-*), regular expressions between slashes (/^team-[0-9]+$/) or well-known for the system namespaces of Kubernetes and common distributions.")
+	flag.StringVar(&deniedNamespacesFile, "denied-namespaces-file", "",
+		"File with one --denied-namespaces entry per line, e.g. a mounted ConfigMap key, added to --denied-namespaces.")
+	flag.StringVar(&instanceID, "instance-id", "",
+		"Name of this operator instance when several run side by side, recorded in the namespaceclass.akuity.io/applied-by annotation of the resources it applies and the namespaces it syncs, and in the namespaceclass_instance_info metric. Namespaces recorded for another instance report an InstanceConflict.")
+	flag.BoolVar(&protectOperator, "protect-operator", true,
+		"Keep classes out of --operator-namespace and reject templates of the operator's own cluster-scoped resources: its Namespace, namespaceclass-operator* ClusterRoles, ClusterRoleBindings and webhook configurations, and its CustomResourceDefinitions.")
+	flag.BoolVar(&classPriorities, "class-priorities", false,
+		"Order queued namespace and class reconciles by the spec.priority of the class, highest first.")
+	flag.DurationVar(&classStatusInterval, "class-status-interval", 30*time.Second,
+		"How often a separate controller aggregates the sync state of attached namespaces into NamespaceClass status. 0 records only the last applied baseline, on every namespace change.")
+	flag.DurationVar(&classStatusBatch, "class-status-batch-interval", 5*time.Second,
+		"With --class-status-interval=0, how long namespace changes are collected before their class status is updated in one write.")
+	flag.DurationVar(&collisionInterval, "class-collision-interval", 5*time.Minute,
+		"How often to check whether NamespaceClasses template the same resources and set TemplatesCollide on them. 0 disables the check.")
+	flag.DurationVar(&syncSLO, "sync-slo", 0,
+		"How long a namespace may take to sync a class attachment or update before NamespaceClassSyncSLOBreached is set on it. 0 disables the condition.")
+	flag.DurationVar(&stuckCleanupThreshold, "stuck-cleanup-threshold", 15*time.Minute,
+		"How long the cleanup of a namespace may be blocked by resources pruning cannot remove, or by the cleanup finalizer of a terminating namespace, before it is reported in namespaceclass_cleanup_blocked_since_seconds and GET /stuck.")
+	var conn connectionOptions
+	conn.bind(flag.CommandLine)
 	opts := zap.Options{Development: true}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	cfg := ctrl.GetConfigOrDie()
+	binds, err := newBindOptions(localhostOnly, ipFamily)
+	if err == nil {
+		probeAddr, err = binds.address(endpointProbes, probeAddr)
+	}
+	if err == nil {
+		metricsAddr, err = binds.address(endpointMetrics, metricsAddr)
+	}
+	if err == nil {
+		pprofAddr, err = binds.address(endpointPprof, pprofAddr)
+	}
+	if err != nil {
+		setupLog.Error(err, "invalid bind address")
+		os.Exit(1)
+	}
+
+	cfg, err := conn.restConfig()
+	if err != nil {
+		setupLog.Error(err, "unable to get kubeconfig")
+		os.Exit(1)
+	}
 	cfg.QPS = 20
 	cfg.Burst = 50
 
+	// A selector cannot match the profile's name or the inventory label, so when classes may be attached in the
+	// operator's own namespace every ConfigMap there is cached, keeping its inventory ConfigMap visible
+	operatorConfigMaps := cache.Config{FieldSelector: fields.OneTermEqualSelector("metadata.name", clusterProfile)}
+	if !protectOperator {
+		operatorConfigMaps = cache.Config{}
+	}
 	mgrOpts := ctrl.Options{
 		Scheme:                 scheme,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "namespaceclass-operator-lock.core.akuity.io",
 		HealthProbeBindAddress: probeAddr,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		PprofBindAddress:       pprofAddr,
+		Cache: cache.Options{
+			SyncPeriod: &syncPeriod,
+			// Only the cluster profile and inventories are read as typed ConfigMaps; don't cache every ConfigMap in the
+			// cluster
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.ConfigMap{}: {
+					Namespaces: map[string]cache.Config{
+						operatorNamespace:   operatorConfigMaps,
+						cache.AllNamespaces: {LabelSelector: labels.SelectorFromSet(labels.Set{engine.InventoryConfigMapLabel: "true"})},
+					},
+				},
+			},
+		},
+		Client: client.Options{DryRun: ptr.To(conn.dryRun())},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+		}),
+	}
+
+	if conn.dryRun() {
+		setupLog.Info("Running out of cluster: all writes are dry runs and leader election is disabled; pass --out-of-cluster-dry-run=false to make changes")
+		mgrOpts.LeaderElection = false
 	}
 
 	mgr, err := ctrl.NewManager(cfg, mgrOpts)
@@ -61,24 +311,322 @@ func main() {
 		os.Exit(1)
 	}
 
+	csaKinds, err := engine.ParseKinds(clientSideApplyKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid --client-side-apply-kinds")
+		os.Exit(1)
+	}
+	retainKinds, err := engine.ParseGroupKinds(neverPruneKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid --never-prune-kinds")
+		os.Exit(1)
+	}
+	onceKinds, err := engine.ParseGroupKinds(applyOnceKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid --apply-once-kinds")
+		os.Exit(1)
+	}
+	watchKinds, err := engine.ParseKinds(watchManagedKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid --watch-managed-kinds")
+		os.Exit(1)
+	}
+	forbidden, err := engine.ParseGroupKinds(forbiddenKinds)
+	if err != nil {
+		setupLog.Error(err, "invalid --forbidden-template-kinds")
+		os.Exit(1)
+	}
+	mirrors, err := engine.ParseRegistryMirrors(registryMirrors)
+	if err != nil {
+		setupLog.Error(err, "invalid --image-registry-mirrors")
+		os.Exit(1)
+	}
+	renderPlugins, err := engine.ParseExternalRenderers(externalRenderers)
+	if err != nil {
+		setupLog.Error(err, "invalid --external-renderers")
+		os.Exit(1)
+	}
+	if inventoryStore != "annotation" && inventoryStore != "configmap" {
+		setupLog.Error(nil, "invalid --inventory-store, expected annotation or configmap", "value", inventoryStore)
+		os.Exit(1)
+	}
+	if fieldConflicts != "Force" && fieldConflicts != "Respect" {
+		setupLog.Error(nil, "invalid --field-conflicts, expected Force or Respect", "value", fieldConflicts)
+		os.Exit(1)
+	}
+	limits, err := engine.ParseApplyLimits(applyLimits)
+	if err != nil {
+		setupLog.Error(err, "invalid --apply-concurrency-limits")
+		os.Exit(1)
+	}
+	denyEntries := strings.Split(deniedNamespaces, ",")
+	if deniedNamespacesFile != "" {
+		entries, err := controllers.ReadNamespaceDenylist(deniedNamespacesFile)
+		if err != nil {
+			setupLog.Error(err, "invalid --denied-namespaces-file")
+			os.Exit(1)
+		}
+		denyEntries = append(denyEntries, entries...)
+	}
+	var protected []engine.ProtectedObject
+	if protectOperator {
+		denyEntries = append(denyEntries, operatorNamespace)
+		protected = engine.OperatorObjects(operatorNamespace)
+	}
+	denylist, err := controllers.ParseNamespaceDenylist(denyEntries)
+	if err != nil {
+		setupLog.Error(err, "invalid namespace deny list")
+		os.Exit(1)
+	}
+	var pacing *controllers.StartupPacing
+	if startupPacing != "" {
+		if pacing, err = controllers.ParseStartupPacing(startupPacing); err != nil {
+			setupLog.Error(err, "invalid --startup-pacing")
+			os.Exit(1)
+		}
+	}
+	var faults *engine.Faults
+	if faultInjection != "" {
+		if faults, err = engine.ParseFaults(faultInjection); err != nil {
+			setupLog.Error(err, "invalid --fault-injection")
+			os.Exit(1)
+		}
+		setupLog.Info("Fault injection is enabled, applies will fail or slow down on purpose", "faults", faultInjection)
+	}
+	// Templates are checked for removed APIs against the cluster version; without it every finding is reported as
+	// a deprecation rather than a removal
+	var kubernetesMinor int
+	if dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig()); err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+	} else if version, err := dc.ServerVersion(); err != nil {
+		setupLog.Error(err, "unable to read the Kubernetes version")
+	} else {
+		kubernetesMinor = engine.ParseMinorVersion(version.Minor)
+	}
+
+	templateCache := engine.NewTemplateCache()
+	renderCache := engine.NewRenderCache(controllers.DriftDetectedAnnotation, controllers.ResyncedAnnotation, controllers.AppliedByAnnotation,
+		controllers.AttachedAdditionalClassesAnnotation)
+	var profile *controllers.ClusterProfile
+	if clusterProfile != "" {
+		profile = &controllers.ClusterProfile{Reader: mgr.GetClient(), Namespace: operatorNamespace, Name: clusterProfile}
+	}
+
+	// Namespace syncs add to the rollout totals the class and status reconcilers report on completion
+	rollouts := &controllers.RolloutTracker{Recorder: mgr.GetEventRecorderFor(controllers.ControllerName)}
+	classReconciler := &controllers.NamespaceClassReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: concurrentNsClassReconciles,
+		RecordLastAppliedSpec:   recordLastAppliedSpec,
+		Rollouts:                rollouts,
+		AggregateStatus:         classStatusInterval > 0,
+		StatusBatchInterval:     classStatusBatch,
+		PrioritizeClasses:       classPriorities,
+		KubernetesMinor:         kubernetesMinor,
+	}
+	var nsConcurrency *controllers.AdaptiveConcurrency
+	if adaptiveConcurrency {
+		classReconciler.Concurrency = &controllers.AdaptiveConcurrency{Name: "namespaceclass", Min: minNsClassReconciles, Max: concurrentNsClassReconciles}
+		nsConcurrency = &controllers.AdaptiveConcurrency{Name: "namespace", Min: minNsReconciles, Max: concurrentNsReconciles}
+	}
+	var startup *controllers.ClassesFirst
+	if classesFirst {
+		startup = &controllers.ClassesFirst{Cache: mgr.GetCache(), Classes: classReconciler}
+		if err := mgr.Add(startup); err != nil {
+			setupLog.Error(err, "unable to add startup ordering")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controllers.NamespaceReconciler{
 		Client:                  mgr.GetClient(),
 		Scheme:                  mgr.GetScheme(),
 		MaxConcurrentReconciles: concurrentNsReconciles,
+		ClassMissingRequeue:     classMissingRequeue,
+		SkipUnchangedApplies:    skipUnchangedApplies,
+		RespectConflicts:        fieldConflicts == "Respect",
+		Templates:               templateCache,
+		Renders:                 renderCache,
+		AnnotateSource:          annotateSource,
+		LabelGeneration:         labelGeneration,
+		DenyCRDs:                !allowCRDTemplates,
+		ForbiddenKinds:          forbidden,
+		Protected:               protected,
+		Instance:                instanceID,
+		ResourceEvents:          resourceEvents,
+		RespectAutoscalers:      respectAutoscalers,
+		HashAnnotation:          renderHashAnnotation,
+		Profile:                 profile,
+		Startup:                 startup,
+		PrioritizeClasses:       classPriorities,
+		ClientSideApplyKinds:    csaKinds,
+		RetainKinds:             retainKinds,
+		ApplyOnceKinds:          onceKinds,
+		WatchKinds:              watchKinds,
+		RegistryMirrors:         mirrors,
+		ExternalRenderers:       renderPlugins,
+		ExternalRenderTimeout:   externalRenderTimeout,
+		AdoptExisting:           adoptExisting,
+		InventoryConfigMaps:     inventoryStore == "configmap",
+		InventoryLabelFallback:  inventoryLabelFallback,
+		MaxDiscoveredPrune:      maxDiscoveredPrune,
+		Rollouts:                rollouts,
+		Pacing:                  pacing,
+		SyncSLO:                 syncSLO,
+		StuckCleanupThreshold:   stuckCleanupThreshold,
+		ApplyLimits:             limits,
+		Faults:                  faults,
+		Denylist:                denylist,
+		Concurrency:             nsConcurrency,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create ns controller", "controller", "Namespace")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.NamespaceClassReconciler{
-		Client:                  mgr.GetClient(),
-		Scheme:                  mgr.GetScheme(),
-		MaxConcurrentReconciles: concurrentNsClassReconciles,
-	}).SetupWithManager(mgr); err != nil {
+	if err = classReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create ns class controller", "controller", "Namespace")
 		os.Exit(1)
 	}
 
+	if enableNotifications {
+		if err = (&controllers.NotificationReconciler{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Namespace: operatorNamespace,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceClassNotification")
+			os.Exit(1)
+		}
+	}
+
+	// Policies and selectors both record the namespaces opting out of a class in its status
+	exclusions := &controllers.ExclusionStatus{Client: mgr.GetClient()}
+	if enableClassPolicies {
+		if err = (&controllers.ClassPolicyReconciler{
+			Client:     mgr.GetClient(),
+			Denylist:   denylist,
+			Exclusions: exclusions,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceClassPolicy")
+			os.Exit(1)
+		}
+	}
+
+	if enableClassSelectors {
+		if err = (&controllers.ClassSelectorReconciler{
+			Client:     mgr.GetClient(),
+			Denylist:   denylist,
+			Exclusions: exclusions,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceClassSelector")
+			os.Exit(1)
+		}
+	}
+
+	if autoLabel != "" {
+		rules, err := controllers.ParseAutoLabelRules(autoLabel)
+		if err != nil {
+			setupLog.Error(err, "invalid --auto-label-namespaces")
+			os.Exit(1)
+		}
+		if err = (&controllers.AutoLabelReconciler{
+			Client: mgr.GetClient(),
+			Rules:  rules,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "namespace-autolabel")
+			os.Exit(1)
+		}
+	}
+
+	if classStatusInterval > 0 {
+		if err = (&controllers.ClassStatusReconciler{
+			Client:                mgr.GetClient(),
+			Interval:              classStatusInterval,
+			RecordLastAppliedSpec: recordLastAppliedSpec,
+			Rollouts:              rollouts,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "namespaceclass-status")
+			os.Exit(1)
+		}
+	}
+
+	if enableClassClaims {
+		if err = (&controllers.ClaimReconciler{
+			Client:   mgr.GetClient(),
+			Denylist: denylist,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceClassClaim")
+			os.Exit(1)
+		}
+	}
+
+	if enableWebhooks {
+		if err = (&webhooks.NamespaceValidator{
+			Client:                mgr.GetClient(),
+			DenyProtectedDeletion: denyProtectedNamespaceDeletion,
+			Denylist:              denylist,
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Namespace")
+			os.Exit(1)
+		}
+		templatePolicy := engine.TemplatePolicy{Mapper: mgr.GetRESTMapper(), ForbiddenKinds: forbidden, Protected: protected}
+		if !allowCRDTemplates {
+			templatePolicy.ForbiddenKinds = append(templatePolicy.ForbiddenKinds, schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"})
+		}
+		if err = (&webhooks.NamespaceClassValidator{KubernetesMinor: kubernetesMinor, Templates: templatePolicy}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NamespaceClass")
+			os.Exit(1)
+		}
+	}
+
+	if migrateStorageVersion {
+		if err := mgr.Add(&controllers.StorageVersionMigrator{Client: mgr.GetClient()}); err != nil {
+			setupLog.Error(err, "unable to set up storage version migration")
+			os.Exit(1)
+		}
+	}
+
+	if collisionInterval > 0 {
+		if err := mgr.Add(&controllers.CollisionDetector{
+			Client:    mgr.GetClient(),
+			Templates: templateCache,
+			Interval:  collisionInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up template collision detection")
+			os.Exit(1)
+		}
+	}
+
+	if queryAPIAddr != "" {
+		if err := mgr.Add(&query.Server{
+			Reader:    mgr.GetCache(),
+			Client:    mgr.GetClient(),
+			Addr:      queryAPIAddr,
+			CertDir:   queryAPICertDir,
+			Dashboard: dashboard,
+
+			StuckCleanupThreshold: stuckCleanupThreshold,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up query API")
+			os.Exit(1)
+		}
+	}
+
+	collector := &controllers.IntrospectionCollector{
+		Reader:          mgr.GetCache(),
+		Templates:       templateCache,
+		Renders:         renderCache,
+		HeapLogInterval: heapLogInterval,
+	}
+	if profile != nil {
+		collector.Profile = profile
+	}
+	if err := mgr.Add(collector); err != nil {
+		setupLog.Error(err, "unable to set up introspection metrics")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting NamespaceClass controller")
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -95,3 +643,11 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// envOr returns the value of the environment variable key, or fallback when it is unset
+func envOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
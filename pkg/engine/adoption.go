@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// adoptionKey marks a context whose applies leave discovered resources alone, see WithAdoption
+type adoptionKey struct{}
+
+type adoption struct {
+	// existing records resources that already exist as discovered instead of writing them
+	existing bool
+	// discovered holds the discovered items of the previous inventory by key
+	discovered map[string]InventoryItem
+}
+
+// WithAdoption returns a context under which Apply does not write resources discovered by an earlier adoption while
+// their render is unchanged. With existing set, resources that already exist in the cluster are discovered: they
+// are recorded in the inventory as they are, without being written, so installing the operator into a cluster whose
+// namespaces already hold what their classes render does not rewrite all of it. Forced applies and dry runs ignore
+// adoption.
+func WithAdoption(ctx context.Context, old []InventoryItem, existing bool) context.Context {
+	a := &adoption{existing: existing, discovered: map[string]InventoryItem{}}
+	for _, item := range old {
+		if item.Discovered {
+			a.discovered[item.Key()] = item
+		}
+	}
+	if !existing && len(a.discovered) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, adoptionKey{}, a)
+}
+
+// discover returns item marked as discovered when it must not be written under the adoption of ctx
+func (e *Engine) discover(ctx context.Context, obj *unstructured.Unstructured, item InventoryItem) (InventoryItem, bool, error) {
+	a, _ := ctx.Value(adoptionKey{}).(*adoption)
+	if a == nil || ForceApply(ctx) || DryRun(ctx) {
+		return item, false, nil
+	}
+	// A changed render is applied like any other, which takes the resource over for good
+	if prev, ok := a.discovered[item.Key()]; ok && prev.Hash == item.Hash {
+		item.Discovered = true
+		item.Unowned = prev.Unowned
+		return item, true, nil
+	}
+	if !a.existing {
+		return item, false, nil
+	}
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := e.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if errors.IsNotFound(err) {
+			return item, false, nil
+		}
+		return item, false, err
+	}
+	item.Discovered = true
+	// The live object keeps the owner references it was created with, not the ones the operator renders
+	item.Unowned = len(live.GetOwnerReferences()) == 0
+	return item, true, nil
+}
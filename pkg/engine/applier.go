@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ServerSideApplier applies resources with server-side apply, honouring their updatePolicy
+type ServerSideApplier struct {
+	Client       client.Client
+	FieldManager string
+	// SkipUnchanged skips the apply when the fields owned by FieldManager already match the intent
+	SkipUnchanged bool
+	// RespectConflicts fails applies that would take over fields owned by another field manager instead of
+	// forcing them, except for resources marked ForceOwnership
+	RespectConflicts bool
+}
+
+var _ Applier = &ServerSideApplier{}
+
+// forceApplyKey marks a context whose applies must not be skipped as unchanged
+type forceApplyKey struct{}
+
+// WithForceApply returns a context under which ServerSideApplier writes every resource, even when it appears
+// unchanged, e.g. to recover from drift the no-op detection cannot see
+func WithForceApply(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceApplyKey{}, true)
+}
+
+// ForceApply reports whether ctx was created by WithForceApply
+func ForceApply(ctx context.Context) bool {
+	forced, _ := ctx.Value(forceApplyKey{}).(bool)
+	return forced
+}
+
+// dryRunKey marks a context whose applies are only dry-run on the server
+type dryRunKey struct{}
+
+// WithDryRun returns a context under which ServerSideApplier sends every apply as a server-side dry run and reports
+// whether it would have changed the live object, without persisting anything
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// DryRun reports whether ctx was created by WithDryRun
+func DryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// Apply writes res unless its updatePolicy or an unchanged live state makes the write unnecessary
+func (a *ServerSideApplier) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	logger := log.FromContext(ctx)
+	obj := res.Object
+
+	// The live object is only needed to honour the updatePolicy, to detect unchanged applies and to diff dry runs.
+	// Resources that are not re-applied are still tracked once they exist so cleanup removes them.
+	applyOnce := res.UpdatePolicy == akuityv1.UpdatePolicyIfNotPresent || res.UpdatePolicy == akuityv1.UpdatePolicyNever
+	var live *unstructured.Unstructured
+	fetched := applyOnce || (a.SkipUnchanged && !ForceApply(ctx)) || DryRun(ctx)
+	if fetched {
+		var err error
+		if live, err = a.getLiveObject(ctx, obj); err != nil {
+			return "", fmt.Errorf("failed to get resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+
+	if applyOnce {
+		if live != nil {
+			return OutcomeSkipped, nil
+		}
+		if res.UpdatePolicy == akuityv1.UpdatePolicyNever {
+			return OutcomeAbsent, nil
+		}
+	}
+
+	// Skip the write entirely when none of the fields the controller owns would change
+	if a.SkipUnchanged && live != nil && !ForceApply(ctx) && !renderHashChanged(live, obj) {
+		noop, err := applyIsNoop(live, obj, a.FieldManager)
+		if err != nil {
+			logger.V(1).Info("No-op detection failed, applying", "kind", obj.GetKind(), "name", obj.GetName(), "error", err.Error())
+		}
+		if noop {
+			return OutcomeUnchanged, nil
+		}
+	}
+
+	// Server-Side Apply (SSA)
+	// Use Patch instead of Create to update resources when Class changes
+	// Force=true means controller takes precedence in case of field conflicts
+	force := !a.RespectConflicts || res.ForceOwnership
+	patchOpts := &client.PatchOptions{
+		FieldManager: a.FieldManager,
+		Force:        &force,
+	}
+	if DryRun(ctx) {
+		return a.dryRun(ctx, obj, live, patchOpts)
+	}
+	if err := a.Client.Patch(ctx, obj, client.Apply, patchOpts); err != nil {
+		err = fmt.Errorf("failed to apply resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		if fetched {
+			err = &liveObjectError{err: err, live: live}
+		}
+		return "", err
+	}
+	return OutcomeApplied, nil
+}
+
+// liveObjectError is a failed apply carrying the live object ServerSideApplier read beforehand, so strategies
+// handling the failure do not read it again
+type liveObjectError struct {
+	err  error
+	live *unstructured.Unstructured
+}
+
+func (e *liveObjectError) Error() string { return e.err.Error() }
+func (e *liveObjectError) Unwrap() error { return e.err }
+
+// liveObjectOf returns the live object read before the failed apply behind err, nil if it did not exist, and
+// whether it was read at all
+func liveObjectOf(err error) (*unstructured.Unstructured, bool) {
+	var le *liveObjectError
+	if !stderrors.As(err, &le) {
+		return nil, false
+	}
+	return le.live, true
+}
+
+// dryRun sends the apply of obj as a server-side dry run and compares the result with live, reporting
+// OutcomeApplied when the apply would create or change the object
+func (a *ServerSideApplier) dryRun(ctx context.Context, obj, live *unstructured.Unstructured, patchOpts *client.PatchOptions) (Outcome, error) {
+	patchOpts.DryRun = []string{metav1.DryRunAll}
+	result := obj.DeepCopy()
+	if err := a.Client.Patch(ctx, result, client.Apply, patchOpts); err != nil {
+		return "", fmt.Errorf("failed to dry-run resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	if live == nil {
+		return OutcomeApplied, nil
+	}
+	if equality.Semantic.DeepEqual(withoutServerMetadata(live), withoutServerMetadata(result)) {
+		return OutcomeUnchanged, nil
+	}
+	return OutcomeApplied, nil
+}
+
+// withoutServerMetadata returns the content of obj without the metadata the API server maintains on every write
+func withoutServerMetadata(obj *unstructured.Unstructured) map[string]interface{} {
+	out := obj.DeepCopy().Object
+	for _, field := range []string{"managedFields", "resourceVersion", "generation"} {
+		unstructured.RemoveNestedField(out, "metadata", field)
+	}
+	return out
+}
+
+// getLiveObject fetches the current state of the object identified by obj, or nil if it does not exist
+func (a *ServerSideApplier) getLiveObject(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := a.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return live, nil
+}
+
+// renderHashChanged reports whether live and intent both carry a render hash and they differ, in which case the
+// intent changed since the last apply and the managed fields comparison can be skipped
+func renderHashChanged(live, intent *unstructured.Unstructured) bool {
+	liveHash, intentHash := live.GetAnnotations()[RenderHashAnnotation], intent.GetAnnotations()[RenderHashAnnotation]
+	return liveHash != "" && intentHash != "" && liveHash != intentHash
+}
@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"slices"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+)
+
+// applyOnce returns res with updatePolicy IfNotPresent when its kind is one of Engine.ApplyOnceKinds. Templates
+// that set Never keep it, since it is stricter.
+func (e *Engine) applyOnce(res Resource) Resource {
+	if len(e.ApplyOnceKinds) == 0 || res.UpdatePolicy == akuityv1.UpdatePolicyNever {
+		return res
+	}
+	if slices.Contains(e.ApplyOnceKinds, res.Object.GroupVersionKind().GroupKind()) {
+		res.UpdatePolicy = akuityv1.UpdatePolicyIfNotPresent
+	}
+	return res
+}
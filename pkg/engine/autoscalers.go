@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var vpaListGVK = schema.GroupVersionKind{Group: "autoscaling.k8s.io", Version: "v1", Kind: "VerticalPodAutoscalerList"}
+
+// AutoscalerFilter drops fields that autoscalers in the namespace manage from rendered workloads, so re-applying
+// a class does not undo their decisions: spec.replicas of an HPA target and container resources of a VPA target
+// that is not in "Off" mode.
+type AutoscalerFilter struct {
+	Base   Renderer
+	Reader client.Reader
+}
+
+var _ Renderer = &AutoscalerFilter{}
+
+// autoscalerTarget identifies a scale target by group, kind and name
+type autoscalerTarget struct {
+	group, kind, name string
+}
+
+func targetOf(apiVersion, kind, name string) autoscalerTarget {
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	return autoscalerTarget{group: gv.Group, kind: kind, name: name}
+}
+
+// Render implements Renderer
+func (f *AutoscalerFilter) Render(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error) {
+	rendered, err := f.Base.Render(ctx, ns, nsClass)
+	if err != nil {
+		return nil, err
+	}
+	// Only workloads with a pod template can be autoscaled; skip the lookups for classes without any
+	hasWorkload := false
+	for _, res := range rendered {
+		if _, ok := podSpecPaths[res.Object.GetKind()]; ok {
+			hasWorkload = true
+			break
+		}
+	}
+	if !hasWorkload {
+		return rendered, nil
+	}
+
+	hpaTargets, err := f.hpaTargets(ctx, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	vpaTargets, err := f.vpaTargets(ctx, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.FromContext(ctx)
+	for _, res := range rendered {
+		obj := res.Object
+		target := targetOf(obj.GetAPIVersion(), obj.GetKind(), obj.GetName())
+		if hpaTargets[target] {
+			logger.V(1).Info("Leaving replicas to HorizontalPodAutoscaler", "kind", obj.GetKind(), "name", obj.GetName())
+			unstructured.RemoveNestedField(obj.Object, "spec", "replicas")
+		}
+		if vpaTargets[target] {
+			path, ok := podSpecPaths[obj.GetKind()]
+			if !ok {
+				continue
+			}
+			logger.V(1).Info("Leaving container resources to VerticalPodAutoscaler", "kind", obj.GetKind(), "name", obj.GetName())
+			if spec := nestedMapRef(obj.Object, path...); spec != nil {
+				for _, field := range []string{"containers", "initContainers"} {
+					for _, c := range mapSlice(spec[field]) {
+						delete(c, "resources")
+					}
+				}
+			}
+		}
+	}
+	return rendered, nil
+}
+
+// hpaTargets returns the objects scaled by HorizontalPodAutoscalers in the namespace
+func (f *AutoscalerFilter) hpaTargets(ctx context.Context, namespace string) (map[autoscalerTarget]bool, error) {
+	var hpas autoscalingv2.HorizontalPodAutoscalerList
+	if err := f.Reader.List(ctx, &hpas, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list HorizontalPodAutoscalers: %w", err)
+	}
+	targets := make(map[autoscalerTarget]bool, len(hpas.Items))
+	for _, hpa := range hpas.Items {
+		ref := hpa.Spec.ScaleTargetRef
+		targets[targetOf(ref.APIVersion, ref.Kind, ref.Name)] = true
+	}
+	return targets, nil
+}
+
+// vpaTargets returns the objects whose resources are updated by VerticalPodAutoscalers in the namespace. Clusters
+// without the VPA CRD have none.
+func (f *AutoscalerFilter) vpaTargets(ctx context.Context, namespace string) (map[autoscalerTarget]bool, error) {
+	vpas := &unstructured.UnstructuredList{}
+	vpas.SetGroupVersionKind(vpaListGVK)
+	if err := f.Reader.List(ctx, vpas, client.InNamespace(namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list VerticalPodAutoscalers: %w", err)
+	}
+	targets := make(map[autoscalerTarget]bool, len(vpas.Items))
+	for _, vpa := range vpas.Items {
+		if mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode"); mode == "Off" {
+			continue
+		}
+		ref, ok, _ := unstructured.NestedStringMap(vpa.Object, "spec", "targetRef")
+		if !ok {
+			continue
+		}
+		targets[targetOf(ref["apiVersion"], ref["kind"], ref["name"])] = true
+	}
+	return targets, nil
+}
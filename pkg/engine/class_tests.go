@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClassTestResult is the outcome of one test of a class
+type ClassTestResult struct {
+	Name string
+	// Failures lists the assertions that did not hold, or the render error; empty when the test passed
+	Failures []string
+}
+
+// RunClassTests renders nsClass for the sample namespace of each of its spec.tests and checks the assertions of
+// the test against what it renders. Classes rendered by an external renderer are not tested and return nil.
+func RunClassTests(ctx context.Context, nsClass *akuityv1.NamespaceClass) []ClassTestResult {
+	if nsClass.Spec.Renderer != "" {
+		return nil
+	}
+	results := make([]ClassTestResult, 0, len(nsClass.Spec.Tests))
+	for _, test := range nsClass.Spec.Tests {
+		results = append(results, ClassTestResult{Name: test.Name, Failures: runClassTest(ctx, nsClass, test)})
+	}
+	return results
+}
+
+// runClassTest returns the failures of a single test
+func runClassTest(ctx context.Context, nsClass *akuityv1.NamespaceClass, test akuityv1.ClassTest) []string {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        test.Namespace.Name,
+		Labels:      test.Namespace.Labels,
+		Annotations: test.Namespace.Annotations,
+	}}
+	if ns.Name == "" {
+		ns.Name = test.Name
+	}
+	renderer := &TemplateRenderer{Profile: StaticProfile(test.Profile)}
+	rendered, err := renderer.Render(ctx, ns, nsClass)
+	if err != nil {
+		return []string{fmt.Sprintf("render failed: %v", err)}
+	}
+
+	var failures []string
+	if want := test.Expect.ResourceCount; want != nil && int(*want) != len(rendered) {
+		failures = append(failures, fmt.Sprintf("renders %d resources, expected %d", len(rendered), *want))
+	}
+	for _, want := range test.Expect.Contains {
+		if !rendersResource(rendered, want) {
+			failures = append(failures, fmt.Sprintf("does not render %s", describeTestResource(want)))
+		}
+	}
+	for _, unwanted := range test.Expect.Excludes {
+		if rendersResource(rendered, unwanted) {
+			failures = append(failures, fmt.Sprintf("renders %s", describeTestResource(unwanted)))
+		}
+	}
+	return failures
+}
+
+// rendersResource reports whether any rendered resource matches want
+func rendersResource(rendered []Resource, want akuityv1.ClassTestResource) bool {
+	for _, res := range rendered {
+		if res.Object.GetKind() == want.Kind && (want.Name == "" || res.Object.GetName() == want.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeTestResource(r akuityv1.ClassTestResource) string {
+	if r.Name == "" {
+		return "any " + r.Kind
+	}
+	return r.Kind + "/" + r.Name
+}
+
+// ClassTestsError summarizes the failed tests of results, or returns nil when all of them passed
+func ClassTestsError(results []ClassTestResult) error {
+	var failed []string
+	for _, result := range results {
+		if len(result.Failures) > 0 {
+			failed = append(failed, fmt.Sprintf("%s: %s", result.Name, strings.Join(result.Failures, ", ")))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d tests failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+}
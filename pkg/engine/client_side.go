@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/mergepatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LastAppliedAnnotation records the intent a ClientSideApplier last wrote, the "original" of its three-way merges
+const LastAppliedAnnotation = "namespaceclass.akuity.io/last-applied"
+
+// ClientSideApplier applies resources with three-way JSON merge patches computed from the last applied intent, the
+// rendered intent and the live object, like kubectl apply without --server-side. It stands in for server-side apply
+// on kinds served by aggregated API servers whose SSA support is unreliable; register it per kind with
+// Registry.RegisterBase.
+type ClientSideApplier struct {
+	Client       client.Client
+	FieldManager string
+}
+
+var _ Applier = &ClientSideApplier{}
+
+// Apply implements Applier, honouring updatePolicy like ServerSideApplier
+func (a *ClientSideApplier) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	obj := res.Object
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := a.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); client.IgnoreNotFound(err) != nil {
+		return "", fmt.Errorf("failed to get resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	} else if err != nil {
+		live = nil
+	}
+
+	if res.UpdatePolicy == akuityv1.UpdatePolicyIfNotPresent || res.UpdatePolicy == akuityv1.UpdatePolicyNever {
+		if live != nil {
+			return OutcomeSkipped, nil
+		}
+		if res.UpdatePolicy == akuityv1.UpdatePolicyNever {
+			return OutcomeAbsent, nil
+		}
+	}
+
+	modified, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	intent := obj.DeepCopy()
+	annotations := intent.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[LastAppliedAnnotation] = string(modified)
+	intent.SetAnnotations(annotations)
+
+	if live == nil {
+		if err := a.Client.Create(ctx, intent, client.FieldOwner(a.FieldManager)); err != nil {
+			return "", fmt.Errorf("failed to create resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		return OutcomeApplied, nil
+	}
+
+	// The annotation is part of the intent so the patch keeps it current
+	modified, err = json.Marshal(intent.Object)
+	if err != nil {
+		return "", err
+	}
+	current, err := json.Marshal(live.Object)
+	if err != nil {
+		return "", err
+	}
+	original := []byte(live.GetAnnotations()[LastAppliedAnnotation])
+	patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current,
+		mergepatch.RequireKeyUnchanged("apiVersion"),
+		mergepatch.RequireKeyUnchanged("kind"),
+		mergepatch.RequireMetadataKeyUnchanged("name"))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute patch for %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	if string(patch) == "{}" {
+		return OutcomeUnchanged, nil
+	}
+	if err := a.Client.Patch(ctx, live, client.RawPatch(types.MergePatchType, patch), client.FieldOwner(a.FieldManager)); err != nil {
+		return "", fmt.Errorf("failed to patch resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return OutcomeApplied, nil
+}
+
+// ParseKinds parses a comma-separated list of kinds written as Kind.version.group, or Kind.version for the core
+// group, e.g. "ConfigMap.v1,Widget.v1alpha1.example.com"
+func ParseKinds(list string) ([]schema.GroupVersionKind, error) {
+	var kinds []schema.GroupVersionKind
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ".", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid kind %q: expected Kind.version or Kind.version.group", entry)
+		}
+		gvk := schema.GroupVersionKind{Kind: parts[0], Version: parts[1]}
+		if len(parts) == 3 {
+			gvk.Group = parts[2]
+		}
+		kinds = append(kinds, gvk)
+	}
+	return kinds, nil
+}
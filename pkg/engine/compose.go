@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AdditionalClassesAnnotation lists, comma-separated, classes a namespace attaches on top of the one named by its
+// class label, e.g. networking-baseline,observability
+const AdditionalClassesAnnotation = "namespaceclass.akuity.io/additional-classes"
+
+// ReasonClassConflict is the failure reason used when classes attached to the same namespace define the same
+// resource
+const ReasonClassConflict = "ClassConflict"
+
+// ClassComposer renders the additional classes of a namespace along with its class, each on its own, so every
+// resource carries the values, common metadata and source class label of the class defining it, and is recorded in
+// the inventory with the generation of that class. Resources of all classes share the inventory of the namespace,
+// so a class dropped from the annotation, deleted or missing has its resources pruned like any other resource no
+// longer rendered. Two classes defining the same resource fail the render.
+type ClassComposer struct {
+	Base Renderer
+	// Class returns the revision of the additional class called name to render for ns, nil when the class is
+	// detached, e.g. because it is missing or being deleted, or an error when ns may not attach it
+	Class func(ctx context.Context, ns *corev1.Namespace, name string) (*akuityv1.NamespaceClass, error)
+}
+
+var _ Renderer = &ClassComposer{}
+
+// Render implements Renderer
+func (c *ClassComposer) Render(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error) {
+	rendered, err := c.Base.Render(ctx, ns, nsClass)
+	if err != nil {
+		return nil, err
+	}
+	additional := AdditionalClasses(ns, nsClass.Name)
+	if len(additional) == 0 {
+		return rendered, nil
+	}
+	definedBy := make(map[string]string, len(rendered))
+	for _, res := range rendered {
+		definedBy[resourceIdentity(res.Object.GroupVersionKind().GroupKind(), res.Object.GetName())] = nsClass.Name
+	}
+	for _, name := range additional {
+		class, err := c.Class(ctx, ns, name)
+		if err != nil {
+			return nil, WithReason(ReasonRenderError, fmt.Errorf("additional class %s: %w", name, err))
+		}
+		if class == nil {
+			continue
+		}
+		resources, err := c.Base.Render(ctx, ns, class)
+		if err != nil {
+			return nil, fmt.Errorf("additional class %s: %w", name, err)
+		}
+		for i := range resources {
+			resources[i].Class, resources[i].Generation = name, class.Generation
+		}
+		for _, res := range resources {
+			gk := res.Object.GroupVersionKind().GroupKind()
+			id := resourceIdentity(gk, res.Object.GetName())
+			if other, ok := definedBy[id]; ok {
+				return nil, WithReason(ReasonClassConflict, fmt.Errorf("%s %s is defined by both NamespaceClass %s and %s",
+					gk, res.Object.GetName(), other, name))
+			}
+			definedBy[id] = name
+		}
+		rendered = append(rendered, resources...)
+	}
+	return rendered, nil
+}
+
+// AdditionalClasses returns the classes ns lists in AdditionalClassesAnnotation, without duplicates and without
+// primary, the class it is labeled with
+func AdditionalClasses(ns *corev1.Namespace, primary string) []string {
+	value := ns.Annotations[AdditionalClassesAnnotation]
+	if value == "" {
+		return nil
+	}
+	var out []string
+	seen := map[string]bool{primary: true}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
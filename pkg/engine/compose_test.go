@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// classRenderer renders the ConfigMaps listed for each class name
+type classRenderer map[string][]string
+
+func (r classRenderer) Render(_ context.Context, _ *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error) {
+	return testResources(r[nsClass.Name]...), nil
+}
+
+// classOf returns the class that renders the ConfigMap called name
+func (r classRenderer) classOf(name string) string {
+	for class, names := range r {
+		if slices.Contains(names, name) {
+			return class
+		}
+	}
+	return ""
+}
+
+func TestClassComposer(t *testing.T) {
+	tests := []struct {
+		name       string
+		additional string
+		renders    classRenderer
+		missing    []string
+		wantReason string
+		want       []string
+	}{
+		{
+			name:    "no additional classes renders the class alone",
+			renders: classRenderer{"base": {"a"}},
+			want:    []string{"a"},
+		},
+		{
+			name:       "additional classes are appended in order",
+			additional: "net, obs,net",
+			renders:    classRenderer{"base": {"a"}, "net": {"b"}, "obs": {"c"}},
+			want:       []string{"a", "b", "c"},
+		},
+		{
+			name:       "the labeled class listed again is ignored",
+			additional: "base",
+			renders:    classRenderer{"base": {"a"}},
+			want:       []string{"a"},
+		},
+		{
+			name:       "detached classes are skipped",
+			additional: "net,obs",
+			renders:    classRenderer{"base": {"a"}, "net": {"b"}, "obs": {"c"}},
+			missing:    []string{"net"},
+			want:       []string{"a", "c"},
+		},
+		{
+			name:       "conflict with the labeled class",
+			additional: "net",
+			renders:    classRenderer{"base": {"a"}, "net": {"a"}},
+			wantReason: ReasonClassConflict,
+		},
+		{
+			name:       "conflict between additional classes",
+			additional: "net,obs",
+			renders:    classRenderer{"base": {"a"}, "net": {"b"}, "obs": {"b"}},
+			wantReason: ReasonClassConflict,
+		},
+		{
+			name:       "a class the namespace may not attach fails the render",
+			additional: "denied",
+			renders:    classRenderer{"base": {"a"}},
+			wantReason: ReasonRenderError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-a",
+				Annotations: map[string]string{AdditionalClassesAnnotation: tt.additional},
+			}}
+			composer := &ClassComposer{
+				Base: tt.renders,
+				Class: func(_ context.Context, _ *corev1.Namespace, name string) (*akuityv1.NamespaceClass, error) {
+					if name == "denied" {
+						return nil, fmt.Errorf("namespace is not allowed to attach NamespaceClass %s", name)
+					}
+					if slices.Contains(tt.missing, name) {
+						return nil, nil
+					}
+					return testClass(name, 2), nil
+				},
+			}
+
+			got, err := composer.Render(context.Background(), ns, testClass("base", 1))
+			if tt.wantReason != "" {
+				if err == nil {
+					t.Fatalf("Render succeeded, want %s", tt.wantReason)
+				}
+				if reason := ClassifyError(err); reason != tt.wantReason {
+					t.Errorf("reason = %s, want %s (err %v)", reason, tt.wantReason, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			var names []string
+			for _, res := range got {
+				names = append(names, res.Object.GetName())
+				// Resources of additional classes carry the class and generation they were rendered from
+				wantClass, wantGeneration := tt.renders.classOf(res.Object.GetName()), int64(2)
+				if wantClass == "base" {
+					wantClass, wantGeneration = "", 0
+				}
+				if res.Class != wantClass || res.Generation != wantGeneration {
+					t.Errorf("%s: class = %q, generation = %d, want %q, %d", res.Object.GetName(), res.Class, res.Generation, wantClass, wantGeneration)
+				}
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("resources = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ProfileSource provides the key/values describing the cluster, e.g. env=prod or region=eu
+type ProfileSource interface {
+	Profile(ctx context.Context) (map[string]string, error)
+}
+
+// StaticProfile is a fixed cluster profile
+type StaticProfile map[string]string
+
+// Profile implements ProfileSource
+func (p StaticProfile) Profile(context.Context) (map[string]string, error) {
+	return p, nil
+}
+
+var (
+	conditionEnvOnce sync.Once
+	conditionEnv     *cel.Env
+	conditionEnvErr  error
+	// conditionPrograms caches compiled conditions by expression
+	conditionPrograms sync.Map
+)
+
+// conditionEnvironment declares the variables a template condition can reference:
+// profile (map of string to string) and namespace (name, labels and annotations)
+func conditionEnvironment() (*cel.Env, error) {
+	conditionEnvOnce.Do(func() {
+		conditionEnv, conditionEnvErr = cel.NewEnv(
+			cel.Variable("profile", cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable("namespace", cel.MapType(cel.StringType, cel.DynType)),
+		)
+	})
+	return conditionEnv, conditionEnvErr
+}
+
+// CompileCondition checks that expr is a valid template condition returning a bool
+func CompileCondition(expr string) (cel.Program, error) {
+	if prg, ok := conditionPrograms.Load(expr); ok {
+		return prg.(cel.Program), nil
+	}
+	env, err := conditionEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("condition must evaluate to a bool, got %s", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	conditionPrograms.Store(expr, prg)
+	return prg, nil
+}
+
+// EvaluateCondition reports whether a template with condition expr applies to ns under profile
+func EvaluateCondition(expr string, ns *corev1.Namespace, profile map[string]string) (bool, error) {
+	prg, err := CompileCondition(expr)
+	if err != nil {
+		return false, err
+	}
+	if profile == nil {
+		profile = map[string]string{}
+	}
+	labels, annotations := ns.Labels, ns.Annotations
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	out, _, err := prg.Eval(map[string]interface{}{
+		"profile": profile,
+		"namespace": map[string]interface{}{
+			"name":        ns.Name,
+			"labels":      labels,
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("condition returned %v instead of a bool", out.Value())
+	}
+	return result, nil
+}
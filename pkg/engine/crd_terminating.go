@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// crdTerminating reports whether gvk is served by a CustomResourceDefinition that is being deleted. Applies of such
+// kinds fail until the CRD is gone, and then fail for lack of it, so Apply leaves them alone meanwhile. Built-in
+// groups, which have no dot, are never looked up, and seen remembers the answer for each kind of one apply. Lookup
+// failures answer false, leaving the apply to report them.
+func (e *Engine) crdTerminating(ctx context.Context, gvk schema.GroupVersionKind, seen map[schema.GroupKind]bool) bool {
+	gk := gvk.GroupKind()
+	if !strings.Contains(gk.Group, ".") {
+		return false
+	}
+	if terminating, ok := seen[gk]; ok {
+		return terminating
+	}
+	terminating := false
+	if mapping, err := e.Client.RESTMapper().RESTMapping(gk, gvk.Version); err == nil {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(crdGVK)
+		err := e.Client.Get(ctx, client.ObjectKey{Name: mapping.Resource.Resource + "." + gk.Group}, crd)
+		switch {
+		case err == nil:
+			terminating = crd.GetDeletionTimestamp() != nil
+		case errors.IsNotFound(err):
+			// An aggregated API server serves the kind
+		default:
+			log.FromContext(ctx).V(1).Info("Failed to look up CustomResourceDefinition", "kind", gk.String(), "error", err.Error())
+		}
+	}
+	seen[gk] = terminating
+	return terminating
+}
+
+// blockedItem returns item marked as blocked by a terminating CustomResourceDefinition. Its render was never
+// written, so no Hash is recorded for it.
+func blockedItem(item InventoryItem) InventoryItem {
+	item.Blocked = true
+	item.Hash = ""
+	return item
+}
+
+// Blocked returns the items of results that were not applied because the CustomResourceDefinition of their kind is
+// being deleted
+func Blocked(results []ApplyResult) []InventoryItem {
+	var blocked []InventoryItem
+	for _, res := range results {
+		if res.Outcome == OutcomeBlocked {
+			blocked = append(blocked, res.Item)
+		}
+	}
+	return blocked
+}
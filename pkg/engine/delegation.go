@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// delegationData is what delegation subject templates are executed against
+type delegationData struct {
+	Namespace delegationNamespace
+}
+
+type delegationNamespace struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// ValidateDelegation checks the static parts of a class's delegation: kinds, role and binding names and that every
+// subject template parses. Values that depend on the namespace are checked when the class is rendered.
+func ValidateDelegation(delegations []akuityv1.Delegation) error {
+	names := map[string]bool{}
+	for i, d := range delegations {
+		if d.ClusterRole == "" {
+			return fmt.Errorf("delegation[%d]: clusterRole is required", i)
+		}
+		name := delegationName(d)
+		if errs := path.ValidatePathSegmentName(name, false); len(errs) > 0 {
+			return fmt.Errorf("delegation[%d]: invalid RoleBinding name %q: %s", i, name, strings.Join(errs, ", "))
+		}
+		if names[name] {
+			return fmt.Errorf("delegation[%d]: RoleBinding name %q is used by another delegation", i, name)
+		}
+		names[name] = true
+		if len(d.Subjects) == 0 {
+			return fmt.Errorf("delegation[%d]: at least one subject is required", i)
+		}
+		for j, s := range d.Subjects {
+			switch s.Kind {
+			case rbacv1.UserKind, rbacv1.GroupKind, rbacv1.ServiceAccountKind:
+			default:
+				return fmt.Errorf("delegation[%d].subjects[%d]: kind must be User, Group or ServiceAccount, got %q", i, j, s.Kind)
+			}
+			if strings.HasPrefix(s.Name, "system:") {
+				return fmt.Errorf("delegation[%d].subjects[%d]: subject %q is reserved for the system", i, j, s.Name)
+			}
+			for _, text := range []string{s.Name, s.Namespace} {
+				if _, err := parseSubjectTemplate(text); err != nil {
+					return fmt.Errorf("delegation[%d].subjects[%d]: %w", i, j, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// delegationBindings renders one RoleBinding per delegation of nsClass for ns
+func delegationBindings(ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]*unstructured.Unstructured, error) {
+	if len(nsClass.Spec.Delegation) == 0 {
+		return nil, nil
+	}
+	if err := ValidateDelegation(nsClass.Spec.Delegation); err != nil {
+		return nil, err
+	}
+	data := delegationData{Namespace: delegationNamespace{Name: ns.Name, Labels: ns.Labels, Annotations: ns.Annotations}}
+
+	var out []*unstructured.Unstructured
+	for i, d := range nsClass.Spec.Delegation {
+		binding := &rbacv1.RoleBinding{
+			TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: d.ClusterRole},
+		}
+		binding.Name = delegationName(d)
+		for j, s := range d.Subjects {
+			subject, err := renderSubject(s, data)
+			if err != nil {
+				return nil, fmt.Errorf("delegation[%d].subjects[%d]: %w", i, j, err)
+			}
+			binding.Subjects = append(binding.Subjects, subject)
+		}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(binding)
+		if err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// renderSubject executes the templates of s and validates the resulting subject
+func renderSubject(s akuityv1.DelegationSubject, data delegationData) (rbacv1.Subject, error) {
+	name, err := executeSubjectTemplate(s.Name, data)
+	if err != nil {
+		return rbacv1.Subject{}, err
+	}
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return rbacv1.Subject{}, fmt.Errorf("subject name %q rendered from %q is empty or contains whitespace", name, s.Name)
+	}
+	if strings.HasPrefix(name, "system:") {
+		return rbacv1.Subject{}, fmt.Errorf("subject %q rendered from %q is reserved for the system", name, s.Name)
+	}
+	subject := rbacv1.Subject{Kind: s.Kind, Name: name}
+	if s.Kind != rbacv1.ServiceAccountKind {
+		subject.APIGroup = rbacv1.GroupName
+		return subject, nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return rbacv1.Subject{}, fmt.Errorf("invalid ServiceAccount name %q: %s", name, strings.Join(errs, ", "))
+	}
+	subject.Namespace = data.Namespace.Name
+	if s.Namespace != "" {
+		if subject.Namespace, err = executeSubjectTemplate(s.Namespace, data); err != nil {
+			return rbacv1.Subject{}, err
+		}
+		if errs := validation.IsDNS1123Label(subject.Namespace); len(errs) > 0 {
+			return rbacv1.Subject{}, fmt.Errorf("invalid ServiceAccount namespace %q: %s", subject.Namespace, strings.Join(errs, ", "))
+		}
+	}
+	return subject, nil
+}
+
+func parseSubjectTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("subject").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template %q: %w", text, err)
+	}
+	return tmpl, nil
+}
+
+func executeSubjectTemplate(text string, data delegationData) (string, error) {
+	tmpl, err := parseSubjectTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render subject template %q: %w", text, err)
+	}
+	return buf.String(), nil
+}
+
+// delegationName is the RoleBinding name of d
+func delegationName(d akuityv1.Delegation) string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return "namespaceclass-" + d.ClusterRole
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// APIDeprecation records when Kubernetes stopped serving a kind in an API version, following the upstream
+// deprecated API migration guide
+type APIDeprecation struct {
+	GroupVersion string
+	Kind         string
+	// Removed is the minor version of Kubernetes 1.x that no longer serves the API
+	Removed int
+	// Replacement is the API version to migrate to; empty when the kind was removed altogether
+	Replacement string
+}
+
+// Describe explains the deprecation for a cluster running Kubernetes 1.<minor>; zero minor means unknown
+func (d APIDeprecation) Describe(minor int) string {
+	msg := fmt.Sprintf("%s %s is removed in 1.%d", d.GroupVersion, d.Kind, d.Removed)
+	if minor >= d.Removed {
+		msg = fmt.Sprintf("%s %s was removed in 1.%d and is not served by this cluster (1.%d)", d.GroupVersion, d.Kind, d.Removed, minor)
+	}
+	if d.Replacement != "" {
+		return msg + ", use " + d.Replacement
+	}
+	return msg + " without replacement"
+}
+
+// apiDeprecations lists the removed built-in APIs classes are most likely to still template
+var apiDeprecations = []APIDeprecation{
+	{"extensions/v1beta1", "Deployment", 16, "apps/v1"},
+	{"extensions/v1beta1", "DaemonSet", 16, "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", 16, "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", 16, "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", 16, "policy/v1beta1"},
+	{"apps/v1beta1", "Deployment", 16, "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", 16, "apps/v1"},
+	{"apps/v1beta2", "Deployment", 16, "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", 16, "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", 16, "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", 16, "apps/v1"},
+	{"extensions/v1beta1", "Ingress", 22, "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", 22, "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", 22, "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", 22, "rbac.authorization.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", 22, "apiextensions.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", 22, "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", 22, "admissionregistration.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", 22, "scheduling.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", 22, "coordination.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", 25, "batch/v1"},
+	{"policy/v1beta1", "PodDisruptionBudget", 25, "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", 25, ""},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", 25, "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", 25, "events.k8s.io/v1"},
+	{"node.k8s.io/v1beta1", "RuntimeClass", 25, "node.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", 25, "autoscaling/v2"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", 26, "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", 26, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", 26, "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", 27, "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", 29, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", 29, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", 32, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", 32, "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// DeprecationOf returns the removal of the API serving gvk, if it is a known removed API
+func DeprecationOf(gvk schema.GroupVersionKind) (APIDeprecation, bool) {
+	gv := gvk.GroupVersion().String()
+	for _, d := range apiDeprecations {
+		if d.GroupVersion == gv && d.Kind == gvk.Kind {
+			return d, true
+		}
+	}
+	return APIDeprecation{}, false
+}
+
+// DeprecatedTemplates describes every template of nsClass that uses a removed API, for a cluster running Kubernetes
+// 1.<minor> (zero when unknown). removed reports whether any of them is already unavailable on that cluster.
+func DeprecatedTemplates(nsClass *akuityv1.NamespaceClass, minor int) (findings []string, removed bool) {
+	for i, tmpl := range nsClass.Spec.Resources {
+		var typeMeta struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}
+		if err := json.Unmarshal(tmpl.Template.Raw, &typeMeta); err != nil {
+			continue
+		}
+		d, ok := DeprecationOf(schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind))
+		if !ok {
+			continue
+		}
+		findings = append(findings, fmt.Sprintf("resources[%d]: %s", i, d.Describe(minor)))
+		if minor >= d.Removed && minor > 0 {
+			removed = true
+		}
+	}
+	return findings, removed
+}
+
+// ParseMinorVersion parses the minor field of the API server version, which providers such as GKE and EKS suffix
+// with "+"
+func ParseMinorVersion(minor string) int {
+	n, err := strconv.Atoi(strings.TrimRight(minor, "+"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,269 @@
+// Package engine renders NamespaceClass templates for a namespace, applies the result, prunes what is no longer
+// rendered and tracks the managed resources in an inventory. The controllers and the kubectl plugin share it.
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// ManagerName identifies the operator as server-side apply field manager and in the managed-by label
+	ManagerName             = "namespace-class-controller"
+	ManagedByLabel          = "namespaceclass.akuity.io/managed-by"
+	SourceClassLabel        = "namespaceclass.akuity.io/source-class"
+	InventoryAnnotation     = "namespaceclass.akuity.io/inventory"
+	AttachedClassAnnotation = "namespaceclass.akuity.io/attached-class"
+	// AppliedByAnnotation records the operator instance that applied a resource, when instances are named
+	AppliedByAnnotation = "namespaceclass.akuity.io/applied-by"
+	// SourceGenerationAnnotation records the class generation a resource was rendered from
+	SourceGenerationAnnotation = "namespaceclass.akuity.io/source-generation"
+	// RenderHashAnnotation carries the hash of the rendered intent of a resource, the same value recorded in the
+	// inventory, so audit tools can spot outdated resources from object metadata alone
+	RenderHashAnnotation = "namespaceclass.akuity.io/render-hash"
+)
+
+// Resource is a class template rendered for one namespace, with the options governing how it is applied
+type Resource struct {
+	Object       *unstructured.Unstructured
+	UpdatePolicy akuityv1.UpdatePolicy
+	AppendHash   bool
+	Protected    bool
+	// ForceOwnership takes over conflicting fields from other managers even when the applier respects conflicts
+	ForceOwnership bool
+	// Class and Generation identify the additional class that rendered the resource, see ClassComposer; both are
+	// empty for resources of the class being applied
+	Class      string
+	Generation int64
+}
+
+// Renderer turns the templates of a class into the resources for one namespace
+type Renderer interface {
+	Render(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error)
+}
+
+// Outcome describes what an Applier did with a resource
+type Outcome string
+
+const (
+	// OutcomeApplied means the resource was written
+	OutcomeApplied Outcome = "Applied"
+	// OutcomeUnchanged means the write was skipped because the owned fields already matched
+	OutcomeUnchanged Outcome = "Unchanged"
+	// OutcomeSkipped means the resource exists and its updatePolicy forbids overwriting it
+	OutcomeSkipped Outcome = "Skipped"
+	// OutcomeAbsent means the resource does not exist and its updatePolicy forbids creating it
+	OutcomeAbsent Outcome = "Absent"
+	// OutcomeDiscovered means the resource was recorded as it exists in the cluster, without being written, see
+	// WithAdoption
+	OutcomeDiscovered Outcome = "Discovered"
+	// OutcomeBlocked means the resource was not written because the CustomResourceDefinition of its kind is being
+	// deleted, see Blocked
+	OutcomeBlocked Outcome = "Blocked"
+)
+
+// Applier writes one rendered resource to the cluster
+type Applier interface {
+	Apply(ctx context.Context, res Resource) (Outcome, error)
+}
+
+// InventoryStore persists which resources the operator manages in a namespace
+type InventoryStore interface {
+	// Get returns the inventory of the namespace
+	Get(ctx context.Context, ns *corev1.Namespace) ([]InventoryItem, error)
+	// Set records the inventory of the namespace and the class it was produced by; no items clears it
+	Set(ctx context.Context, ns *corev1.Namespace, className string, items []InventoryItem) error
+}
+
+// ApplyResult records what happened to one rendered resource
+type ApplyResult struct {
+	Item    InventoryItem
+	Outcome Outcome
+}
+
+// Engine ties a Renderer, an Applier and an InventoryStore together
+type Engine struct {
+	Client    client.Client
+	Renderer  Renderer
+	Applier   Applier
+	Inventory InventoryStore
+	// HashAnnotation stamps every applied resource with RenderHashAnnotation
+	HashAnnotation bool
+	// DryRunApplier handles DryRun. Strategies that delete and re-create objects cannot be previewed, so it is a
+	// plain ServerSideApplier rather than the registry Applier usually is.
+	DryRunApplier *ServerSideApplier
+	// RetainKinds are never deleted by Prune, Cleanup or PruneStale, whatever the class says. Prune still drops them
+	// from the inventory, so they are left labeled as managed but untracked, the way `kubectl nsclass orphans`
+	// reports them, until someone deletes or adopts them.
+	RetainKinds []schema.GroupKind
+	// ApplyOnceKinds are created once and never re-applied, whatever the class says, as if their templates set
+	// updatePolicy IfNotPresent. It suits kinds other controllers mutate heavily, where ignoreFields would have to
+	// list most of the object.
+	ApplyOnceKinds []schema.GroupKind
+}
+
+// Apply renders the class for the namespace and applies every resource in order. On failure the results of the
+// resources handled so far are returned alongside the error.
+func (e *Engine) Apply(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]ApplyResult, error) {
+	return e.apply(ctx, ns, nsClass, e.Applier)
+}
+
+// DryRun is Apply with every write sent as a server-side dry run through DryRunApplier; the outcomes tell which
+// resources an Apply would create or change
+func (e *Engine) DryRun(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]ApplyResult, error) {
+	if e.DryRunApplier == nil {
+		return nil, fmt.Errorf("dry run is not configured")
+	}
+	return e.apply(WithDryRun(ctx), ns, nsClass, e.DryRunApplier)
+}
+
+func (e *Engine) apply(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, applier Applier) ([]ApplyResult, error) {
+	logger := log.FromContext(ctx)
+
+	resources, err := e.Renderer.Render(ctx, ns, nsClass)
+	if err != nil {
+		return nil, &renderFailure{err: err}
+	}
+
+	var results []ApplyResult
+	crds := map[schema.GroupKind]bool{}
+	for _, res := range crdsFirst(resources) {
+		res = e.applyOnce(res)
+		obj := res.Object
+		hash, err := RenderHash(obj)
+		if err != nil {
+			return results, err
+		}
+		item := ItemFor(obj)
+		item.Generation = nsClass.Generation
+		if res.Class != "" {
+			item.Class = res.Class
+			item.Generation = res.Generation
+		}
+		item.Protected = res.Protected
+		item.Hash = hash
+		item.Unowned = len(obj.GetOwnerReferences()) == 0
+		if e.crdTerminating(ctx, obj.GroupVersionKind(), crds) {
+			logger.Info("Not applying resource, the CustomResourceDefinition of its kind is being deleted", "kind", obj.GetKind(), "name", obj.GetName())
+			results = append(results, ApplyResult{Item: blockedItem(item), Outcome: OutcomeBlocked})
+			continue
+		}
+		if discovered, ok, err := e.discover(ctx, obj, item); err != nil {
+			return results, &applyFailure{kind: obj.GetKind(), err: err}
+		} else if ok {
+			logger.V(1).Info("Leaving discovered resource alone", "kind", obj.GetKind(), "name", obj.GetName())
+			results = append(results, ApplyResult{Item: discovered, Outcome: OutcomeDiscovered})
+			continue
+		}
+		if e.HashAnnotation {
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[RenderHashAnnotation] = hash
+			obj.SetAnnotations(annotations)
+		}
+		outcome, err := applier.Apply(ctx, res)
+		if err != nil {
+			if d, ok := DeprecationOf(obj.GroupVersionKind()); ok && meta.IsNoMatchError(err) {
+				err = fmt.Errorf("%s: %w", d.Describe(0), err)
+			}
+			return results, &applyFailure{kind: obj.GetKind(), err: err}
+		}
+		logger.V(1).Info("Handled resource", "kind", obj.GetKind(), "name", obj.GetName(), "outcome", outcome)
+		if outcome == OutcomeAbsent {
+			continue
+		}
+		results = append(results, ApplyResult{Item: item, Outcome: outcome})
+	}
+	return results, nil
+}
+
+// Prune deletes the resources in old that are not in keep, in reverse dependency order, and returns the ones it
+// removed. Resources of RetainKinds are skipped, see Retained, and resources whose API is no longer served are
+// dropped without being deleted, see Unserved. Resources that fail to delete, or that finalizers keep around, are
+// not returned; Prune carries on with the others and reports them all in a *PruneError.
+func (e *Engine) Prune(ctx context.Context, old, keep []InventoryItem) ([]InventoryItem, error) {
+	logger := log.FromContext(ctx)
+	keepMap := make(map[string]bool)
+	for _, k := range keep {
+		keepMap[k.Key()] = true
+	}
+
+	var pruned []InventoryItem
+	stuck := &PruneError{}
+	for _, item := range pruneOrder(old) {
+		if keepMap[item.Key()] {
+			continue
+		}
+		if e.retains(item) {
+			logger.Info("Not pruning resource of a retained kind", "kind", item.Kind, "name", item.Name)
+			continue
+		}
+		if !e.served(item) {
+			logger.Info("Dropping resource whose API is no longer served", "apiVersion", item.APIVersion, "kind", item.Kind, "name", item.Name)
+			continue
+		}
+
+		// Build object to delete
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(item.APIVersion)
+		u.SetKind(item.Kind)
+		u.SetName(item.Name)
+		u.SetNamespace(item.Namespace)
+
+		logger.Info("Pruning orphaned resource", "kind", item.Kind, "name", item.Name)
+		if err := e.delete(ctx, u); err != nil {
+			if !errors.IsNotFound(err) {
+				stuck.add(item, err)
+				continue
+			}
+		}
+		live, err := e.terminating(ctx, u)
+		if err != nil {
+			stuck.add(item, err)
+			continue
+		}
+		if live != nil {
+			logger.Info("Pruned resource is held by finalizers", "kind", item.Kind, "name", item.Name, "finalizers", live.GetFinalizers())
+			stuck.addTerminating(item, live)
+			continue
+		}
+		pruned = append(pruned, item)
+	}
+	if len(stuck.Stuck) > 0 {
+		return pruned, stuck
+	}
+	return pruned, nil
+}
+
+// delete removes obj through the applier when it prunes specially, or directly otherwise
+func (e *Engine) delete(ctx context.Context, obj *unstructured.Unstructured) error {
+	if p, ok := e.Applier.(Pruner); ok {
+		return p.Delete(ctx, obj)
+	}
+	return e.Client.Delete(ctx, obj)
+}
+
+// Cleanup removes every resource in the namespace inventory and clears the inventory
+func (e *Engine) Cleanup(ctx context.Context, ns *corev1.Namespace) ([]InventoryItem, error) {
+	old, err := e.Inventory.Get(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	// Set keep list to nil to delete all resources
+	pruned, err := e.Prune(ctx, old, nil)
+	if err != nil {
+		return pruned, err
+	}
+	return pruned, e.Inventory.Set(ctx, ns, "", nil)
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func configMap(name string, finalizers ...string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Finalizers: finalizers}}
+}
+
+func item(apiVersion, kind, name string) InventoryItem {
+	return InventoryItem{APIVersion: apiVersion, Kind: kind, Name: name, Namespace: "team-a"}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		objects     []client.Object
+		retainKinds []schema.GroupKind
+		old         []InventoryItem
+		keep        []InventoryItem
+		wantPruned  []string
+		wantStuck   []string
+		wantLive    []string
+	}{
+		{
+			name:       "prunes items not kept",
+			objects:    []client.Object{configMap("a"), configMap("b")},
+			old:        []InventoryItem{item("v1", "ConfigMap", "a"), item("v1", "ConfigMap", "b")},
+			keep:       []InventoryItem{item("v1", "ConfigMap", "b")},
+			wantPruned: []string{"a"},
+			wantLive:   []string{"b"},
+		},
+		{
+			name:       "counts a missing object as pruned",
+			old:        []InventoryItem{item("v1", "ConfigMap", "gone")},
+			wantPruned: []string{"gone"},
+		},
+		{
+			name:        "leaves retained kinds in place",
+			objects:     []client.Object{configMap("a")},
+			retainKinds: []schema.GroupKind{{Kind: "ConfigMap"}},
+			old:         []InventoryItem{item("v1", "ConfigMap", "a")},
+			wantLive:    []string{"a"},
+		},
+		{
+			name: "drops unserved kinds without deleting",
+			old:  []InventoryItem{item("example.com/v1beta1", "Widget", "w")},
+		},
+		{
+			name:      "reports objects held by finalizers",
+			objects:   []client.Object{configMap("a", "example.com/hold")},
+			old:       []InventoryItem{item("v1", "ConfigMap", "a")},
+			wantStuck: []string{"a"},
+			wantLive:  []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			// Only ConfigMaps are served; every other kind is unknown to the RESTMapper
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+			c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithRESTMapper(mapper).WithObjects(tt.objects...).Build()
+			e := &Engine{Client: c, RetainKinds: tt.retainKinds}
+
+			pruned, err := e.Prune(ctx, tt.old, tt.keep)
+			if got := itemNames(pruned); !slices.Equal(got, tt.wantPruned) {
+				t.Errorf("pruned = %v, want %v", got, tt.wantPruned)
+			}
+			var stuck []string
+			for _, s := range StuckResources(err) {
+				if s.Reason != ReasonFinalizerPending {
+					t.Errorf("stuck %s reason = %s, want %s", s.Name, s.Reason, ReasonFinalizerPending)
+				}
+				stuck = append(stuck, s.Name)
+			}
+			if !slices.Equal(stuck, tt.wantStuck) {
+				t.Errorf("stuck = %v, want %v (err %v)", stuck, tt.wantStuck, err)
+			}
+			if len(tt.wantStuck) == 0 && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			for _, name := range tt.wantLive {
+				if err := c.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, &corev1.ConfigMap{}); err != nil {
+					t.Errorf("ConfigMap %s: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func itemNames(items []InventoryItem) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RenderRequestVersion identifies the request format sent to external renderers
+const RenderRequestVersion = "render.namespaceclass.akuity.io/v1"
+
+// maxRenderResponse caps what an external renderer may return, so a runaway plugin cannot exhaust the
+// operator's memory
+const maxRenderResponse = 8 << 20
+
+// DefaultExternalRenderTimeout bounds a call to an external renderer when ExternalRenderer.Timeout is unset
+const DefaultExternalRenderTimeout = 10 * time.Second
+
+// RenderRequest is what an external renderer receives: the class and the metadata of the namespace to render it for
+type RenderRequest struct {
+	APIVersion string                   `json:"apiVersion"`
+	Namespace  metav1.ObjectMeta        `json:"namespace"`
+	Class      *akuityv1.NamespaceClass `json:"class"`
+}
+
+// RenderResponse is what an external renderer returns: the objects to apply in the namespace
+type RenderResponse struct {
+	Resources []runtime.RawExtension `json:"resources"`
+}
+
+// RenderPlugin calls an external renderer with an encoded RenderRequest and returns its encoded RenderResponse
+type RenderPlugin interface {
+	Call(ctx context.Context, request []byte) ([]byte, error)
+}
+
+// ParseExternalRenderers parses comma-separated name=exec:<path> and name=<http or https URL> pairs into the
+// plugins they configure
+func ParseExternalRenderers(spec string) (map[string]RenderPlugin, error) {
+	plugins := map[string]RenderPlugin{}
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("external renderer %q must be name=exec:<path> or name=<url>", pair)
+		}
+		if _, ok := plugins[name]; ok {
+			return nil, fmt.Errorf("external renderer %s is defined more than once", name)
+		}
+		if path, ok := strings.CutPrefix(target, "exec:"); ok {
+			plugins[name] = &ExecPlugin{Path: path}
+			continue
+		}
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("external renderer %q must be name=exec:<path> or name=<url>", pair)
+		}
+		plugins[name] = &HTTPPlugin{URL: target, Client: http.DefaultClient}
+	}
+	return plugins, nil
+}
+
+// ExecPlugin runs a renderer binary, e.g. one shipped in a sidecar's shared volume, with the request on stdin and
+// the response on stdout. The binary runs in an empty temporary directory with only PATH in its environment, so it
+// sees neither the operator's working directory nor its configuration.
+type ExecPlugin struct {
+	Path string
+}
+
+// Call implements RenderPlugin
+func (p *ExecPlugin) Call(ctx context.Context, request []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "nsclass-render-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renderer working directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir, "TMPDIR=" + dir}
+	cmd.Stdin = bytes.NewReader(request)
+	stdout := &limitedBuffer{max: maxRenderResponse}
+	stderr := &limitedBuffer{max: 4 << 10}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// Children the renderer leaves behind must not hold up the reconcile once it is killed
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.exceeded {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxRenderResponse)
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer keeps the first max bytes written to it and drops the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.exceeded = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// HTTPPlugin POSTs the request to a renderer service and reads the response from a 200 reply
+type HTTPPlugin struct {
+	URL    string
+	Client *http.Client
+}
+
+// Call implements RenderPlugin
+func (p *HTTPPlugin) Call(ctx context.Context, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRenderResponse+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("renderer returned %s: %s", resp.Status, strings.TrimSpace(string(body[:min(len(body), 512)])))
+	}
+	if len(body) > maxRenderResponse {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxRenderResponse)
+	}
+	return body, nil
+}
+
+// ExternalRenderer renders classes that set spec.renderer with the RenderPlugin of that name, for organizations
+// with their own templating system. The returned objects are validated and then handed to Base as the class
+// templates, so they get the operator's metadata, budget and duplicate checks like any other template. Classes
+// without spec.renderer go to Base unchanged.
+type ExternalRenderer struct {
+	Base    Renderer
+	Plugins map[string]RenderPlugin
+	// Timeout bounds each call; DefaultExternalRenderTimeout when zero
+	Timeout time.Duration
+}
+
+var _ Renderer = &ExternalRenderer{}
+
+// Render implements Renderer
+func (e *ExternalRenderer) Render(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error) {
+	name := nsClass.Spec.Renderer
+	if name == "" {
+		return e.Base.Render(ctx, ns, nsClass)
+	}
+	plugin, ok := e.Plugins[name]
+	if !ok {
+		return nil, WithReason(ReasonRenderError, fmt.Errorf("external renderer %s is not configured on the operator", name))
+	}
+
+	request, err := json.Marshal(RenderRequest{
+		APIVersion: RenderRequestVersion,
+		Namespace:  metav1.ObjectMeta{Name: ns.Name, UID: ns.UID, Labels: ns.Labels, Annotations: ns.Annotations},
+		Class:      nsClass,
+	})
+	if err != nil {
+		return nil, WithReason(ReasonRenderError, fmt.Errorf("failed to encode render request: %w", err))
+	}
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExternalRenderTimeout
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	raw, err := plugin.Call(callCtx, request)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, WithReason(ReasonTimeout, fmt.Errorf("external renderer %s did not respond within %s", name, timeout))
+		}
+		return nil, WithReason(ReasonRenderError, fmt.Errorf("external renderer %s failed: %w", name, err))
+	}
+	templates, err := decodeRenderResponse(raw, ns)
+	if err != nil {
+		return nil, WithReason(ReasonRenderError, fmt.Errorf("invalid response from external renderer %s: %w", name, err))
+	}
+
+	// Without a UID the template and render caches leave the copy alone; its templates change per namespace
+	rendered := nsClass.DeepCopy()
+	rendered.UID = ""
+	rendered.Spec.Resources = templates
+	return e.Base.Render(ctx, ns, rendered)
+}
+
+// decodeRenderResponse checks that every object of a response is a complete namespaced object meant for ns and
+// returns them as templates
+func decodeRenderResponse(raw []byte, ns *corev1.Namespace) ([]akuityv1.ResourceTemplate, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var resp RenderResponse
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, err
+	}
+	templates := make([]akuityv1.ResourceTemplate, 0, len(resp.Resources))
+	for i, res := range resp.Resources {
+		var meta struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name         string `json:"name"`
+				GenerateName string `json:"generateName"`
+				Namespace    string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(res.Raw, &meta); err != nil {
+			return nil, fmt.Errorf("resources[%d]: %w", i, err)
+		}
+		switch {
+		case meta.APIVersion == "" || meta.Kind == "" || meta.Metadata.Name == "":
+			return nil, fmt.Errorf("resources[%d]: apiVersion, kind and metadata.name are required", i)
+		case meta.Metadata.GenerateName != "":
+			return nil, fmt.Errorf("resources[%d]: metadata.generateName is not supported", i)
+		case meta.Metadata.Namespace != "" && meta.Metadata.Namespace != ns.Name:
+			return nil, fmt.Errorf("resources[%d]: %s/%s targets namespace %s", i, meta.Kind, meta.Metadata.Name, meta.Metadata.Namespace)
+		case meta.Kind == "Namespace":
+			return nil, fmt.Errorf("resources[%d]: Namespace objects cannot be rendered", i)
+		}
+		templates = append(templates, akuityv1.ResourceTemplate{Template: res})
+	}
+	return templates, nil
+}
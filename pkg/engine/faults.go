@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// faultWebhook is the webhook named by injected AdmissionDenied failures
+const faultWebhook = "fault-injection.namespaceclass.akuity.io"
+
+// Faults configures the failures a FaultInjector injects. It is a testing aid for exercising alerting, retries and
+// fallbacks in staging and must not be enabled in production.
+type Faults struct {
+	// ErrorRate is the fraction of applies, between 0 and 1, that fail without reaching the API server
+	ErrorRate float64
+	// Reason is the failure reason, as classified by ClassifyError, the injected errors report: Unknown, Timeout,
+	// Conflict, AdmissionDenied, RBACDenied or QuotaExceeded
+	Reason string
+	// Latency delays every affected apply, failing or not, to simulate a slow API server or admission webhook
+	Latency time.Duration
+	// Kinds restricts the faults to these kinds; empty affects every kind
+	Kinds []schema.GroupKind
+	// Namespaces restricts the faults to namespaces matching one of these glob patterns; empty affects every
+	// namespace
+	Namespaces []string
+}
+
+// ParseFaults parses comma-separated key=value pairs into Faults, e.g.
+// "errors=0.2,reason=Timeout,latency=2s,kind=ExternalSecret.external-secrets.io,namespace=staging-*".
+// kind and namespace may be repeated.
+func ParseFaults(spec string) (*Faults, error) {
+	f := &Faults{Reason: ReasonUnknown}
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("fault %q must be key=value", pair)
+		}
+		switch key {
+		case "errors":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("fault %q must be a rate between 0 and 1", pair)
+			}
+			f.ErrorRate = rate
+		case "reason":
+			if _, ok := faultErrors[value]; !ok {
+				return nil, fmt.Errorf("fault %q: unsupported reason", pair)
+			}
+			f.Reason = value
+		case "latency":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("fault %q must be a duration", pair)
+			}
+			f.Latency = d
+		case "kind":
+			f.Kinds = append(f.Kinds, schema.ParseGroupKind(value))
+		case "namespace":
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("fault %q: %w", pair, err)
+			}
+			f.Namespaces = append(f.Namespaces, value)
+		default:
+			return nil, fmt.Errorf("fault %q: unknown key %s", pair, key)
+		}
+	}
+	return f, nil
+}
+
+// faultErrors builds the error injected for each supported reason, shaped like the API server's own so that
+// classification, events and metrics treat it like the real failure
+var faultErrors = map[string]func(obj *unstructured.Unstructured) error{
+	ReasonUnknown: func(obj *unstructured.Unstructured) error {
+		return errors.NewInternalError(fmt.Errorf("injected fault"))
+	},
+	ReasonTimeout: func(obj *unstructured.Unstructured) error {
+		return errors.NewTimeoutError("injected fault", 1)
+	},
+	ReasonConflict: func(obj *unstructured.Unstructured) error {
+		return errors.NewConflict(resourceOf(obj), obj.GetName(), fmt.Errorf("injected fault"))
+	},
+	ReasonAdmissionDenied: func(obj *unstructured.Unstructured) error {
+		return errors.NewForbidden(resourceOf(obj), obj.GetName(),
+			fmt.Errorf("admission webhook %q denied the request: injected fault", faultWebhook))
+	},
+	ReasonRBACDenied: func(obj *unstructured.Unstructured) error {
+		return errors.NewForbidden(resourceOf(obj), obj.GetName(), fmt.Errorf("injected fault"))
+	},
+	ReasonQuotaExceeded: func(obj *unstructured.Unstructured) error {
+		return errors.NewForbidden(resourceOf(obj), obj.GetName(), fmt.Errorf("exceeded quota: injected fault"))
+	},
+}
+
+// resourceOf approximates the resource of obj for error messages, which need no RESTMapper precision
+func resourceOf(obj *unstructured.Unstructured) schema.GroupResource {
+	gvk := obj.GroupVersionKind()
+	return schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind) + "s"}
+}
+
+// FaultInjector delays and fails applies of Base as configured by Faults, without a misbehaving API server.
+// Deletes pass through unchanged.
+type FaultInjector struct {
+	Base   Applier
+	Faults *Faults
+	// Client deletes resources when Base is not a Pruner
+	Client client.Client
+}
+
+var _ Applier = &FaultInjector{}
+var _ Pruner = &FaultInjector{}
+
+// affects reports whether obj is in scope of the faults
+func (f *FaultInjector) affects(obj *unstructured.Unstructured) bool {
+	if len(f.Faults.Kinds) > 0 && !slices.Contains(f.Faults.Kinds, obj.GroupVersionKind().GroupKind()) {
+		return false
+	}
+	if len(f.Faults.Namespaces) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(f.Faults.Namespaces, func(pattern string) bool {
+		ok, _ := path.Match(pattern, obj.GetNamespace())
+		return ok
+	})
+}
+
+// Apply implements Applier
+func (f *FaultInjector) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	obj := res.Object
+	if !f.affects(obj) {
+		return f.Base.Apply(ctx, res)
+	}
+	if f.Faults.Latency > 0 {
+		select {
+		case <-time.After(f.Faults.Latency):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if f.Faults.ErrorRate > 0 && rand.Float64() < f.Faults.ErrorRate {
+		log.FromContext(ctx).Info("Injecting apply failure", "kind", obj.GetKind(), "name", obj.GetName(), "reason", f.Faults.Reason)
+		return "", faultErrors[f.Faults.Reason](obj)
+	}
+	return f.Base.Apply(ctx, res)
+}
+
+// Delete implements Pruner
+func (f *FaultInjector) Delete(ctx context.Context, obj *unstructured.Unstructured) error {
+	if p, ok := f.Base.(Pruner); ok {
+		return p.Delete(ctx, obj)
+	}
+	return f.Client.Delete(ctx, obj)
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// goTemplateData is what the strings of goTemplate resources are executed against
+type goTemplateData struct {
+	Namespace delegationNamespace
+	// Values are the class values with the namespace's overrides, see classValues
+	Values map[string]string
+}
+
+// ValidateGoTemplates checks that every string of the resource templates with goTemplate set parses as a Go template.
+// References to labels or values a namespace may lack are checked when the class is rendered.
+func ValidateGoTemplates(nsClass *akuityv1.NamespaceClass) error {
+	for i, tmpl := range nsClass.Spec.Resources {
+		if !tmpl.GoTemplate {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(tmpl.Template.Raw, &obj); err != nil {
+			return fmt.Errorf("resources[%d]: %w", i, err)
+		}
+		var invalid error
+		walkStrings(obj, func(s string) string {
+			if _, err := parseGoTemplate(s); err != nil && invalid == nil {
+				invalid = err
+			}
+			return s
+		})
+		if invalid != nil {
+			return fmt.Errorf("resources[%d]: %w", i, invalid)
+		}
+	}
+	return nil
+}
+
+// executeGoTemplates executes every string of obj holding a Go template against the metadata of ns and values, map
+// keys excluded
+func executeGoTemplates(obj map[string]interface{}, ns *corev1.Namespace, values map[string]string) error {
+	data := goTemplateData{
+		Namespace: delegationNamespace{Name: ns.Name, Labels: ns.Labels, Annotations: ns.Annotations},
+		Values:    values,
+	}
+	var failed error
+	walkStrings(obj, func(s string) string {
+		if failed != nil {
+			return s
+		}
+		tmpl, err := parseGoTemplate(s)
+		if err != nil {
+			failed = err
+			return s
+		}
+		if tmpl == nil {
+			return s
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			failed = fmt.Errorf("failed to execute template %q: %w", s, err)
+			return s
+		}
+		return buf.String()
+	})
+	return failed
+}
+
+// parseGoTemplate parses s, or returns nil for strings without an action. A missing map key is an error; index
+// returns the empty string instead, e.g. {{ index .Namespace.Labels "team" }}.
+func parseGoTemplate(s string) (*template.Template, error) {
+	if !strings.Contains(s, "{{") {
+		return nil, nil
+	}
+	tmpl, err := template.New("resource").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", s, err)
+	}
+	return tmpl, nil
+}
+
+// walkStrings replaces every string value in node, map keys excluded, with what fn returns for it
+func walkStrings(node interface{}, fn func(string) string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			n[k] = walkStrings(v, fn)
+		}
+	case []interface{}:
+		for i, v := range n {
+			n[i] = walkStrings(v, fn)
+		}
+	case string:
+		return fn(n)
+	}
+	return node
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// podSpecPaths locates the pod spec inside the workload kinds whose references are rewritten
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// appendContentHashes renames ConfigMaps and Secrets marked with appendHash to <name>-<content hash> and rewrites
+// references to them in sibling pod templates, so a content change rolls the dependent workloads.
+// The previous hashed object drops out of the inventory and is pruned like any removed resource.
+func appendContentHashes(rendered []Resource) error {
+	configMaps := make(map[string]string)
+	secrets := make(map[string]string)
+	for _, res := range rendered {
+		if !res.AppendHash {
+			continue
+		}
+		obj := res.Object
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != "" || (gvk.Kind != "ConfigMap" && gvk.Kind != "Secret") {
+			return fmt.Errorf("appendHash is only supported for ConfigMaps and Secrets, not %s/%s", gvk.Kind, obj.GetName())
+		}
+		hash, err := contentHash(obj.Object)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s/%s: %w", gvk.Kind, obj.GetName(), err)
+		}
+		hashed := obj.GetName() + "-" + hash
+		if gvk.Kind == "ConfigMap" {
+			configMaps[obj.GetName()] = hashed
+		} else {
+			secrets[obj.GetName()] = hashed
+		}
+		obj.SetName(hashed)
+	}
+	if len(configMaps) == 0 && len(secrets) == 0 {
+		return nil
+	}
+
+	for _, res := range rendered {
+		path, ok := podSpecPaths[res.Object.GetKind()]
+		if !ok {
+			continue
+		}
+		if spec := nestedMapRef(res.Object.Object, path...); spec != nil {
+			rewritePodSpecReferences(spec, configMaps, secrets)
+		}
+	}
+	return nil
+}
+
+// contentHash hashes the payload of a ConfigMap or Secret; encoding/json sorts map keys so the result is stable
+func contentHash(object map[string]interface{}) (string, error) {
+	content := make(map[string]interface{})
+	for _, field := range []string{"data", "binaryData", "stringData", "type"} {
+		if v, ok := object[field]; ok {
+			content[field] = v
+		}
+	}
+	b, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:10], nil
+}
+
+// rewritePodSpecReferences renames every ConfigMap and Secret reference a pod spec can carry
+func rewritePodSpecReferences(spec map[string]interface{}, configMaps, secrets map[string]string) {
+	for _, vol := range mapSlice(spec["volumes"]) {
+		renameRef(asMap(vol["configMap"]), "name", configMaps)
+		renameRef(asMap(vol["secret"]), "secretName", secrets)
+		if projected := asMap(vol["projected"]); projected != nil {
+			for _, src := range mapSlice(projected["sources"]) {
+				renameRef(asMap(src["configMap"]), "name", configMaps)
+				renameRef(asMap(src["secret"]), "name", secrets)
+			}
+		}
+	}
+	for _, key := range []string{"containers", "initContainers", "ephemeralContainers"} {
+		for _, c := range mapSlice(spec[key]) {
+			for _, envFrom := range mapSlice(c["envFrom"]) {
+				renameRef(asMap(envFrom["configMapRef"]), "name", configMaps)
+				renameRef(asMap(envFrom["secretRef"]), "name", secrets)
+			}
+			for _, env := range mapSlice(c["env"]) {
+				valueFrom := asMap(env["valueFrom"])
+				renameRef(asMap(valueFrom["configMapKeyRef"]), "name", configMaps)
+				renameRef(asMap(valueFrom["secretKeyRef"]), "name", secrets)
+			}
+		}
+	}
+	for _, pullSecret := range mapSlice(spec["imagePullSecrets"]) {
+		renameRef(pullSecret, "name", secrets)
+	}
+}
+
+func renameRef(ref map[string]interface{}, field string, names map[string]string) {
+	if ref == nil {
+		return
+	}
+	if name, ok := ref[field].(string); ok {
+		if hashed, ok := names[name]; ok {
+			ref[field] = hashed
+		}
+	}
+}
+
+// nestedMapRef walks into obj without copying, unlike unstructured.NestedMap, so the result can be mutated in place
+func nestedMapRef(obj map[string]interface{}, path ...string) map[string]interface{} {
+	cur := obj
+	for _, p := range path {
+		next, ok := cur[p].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = next
+	}
+	return cur
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func mapSlice(v interface{}) []map[string]interface{} {
+	items, _ := v.([]interface{})
+	out := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceIdentity is a resource a class renders under a name known before rendering
+type ResourceIdentity struct {
+	schema.GroupKind
+	Name string
+	// Conditional is set for templates with a condition, which may not render for every namespace
+	Conditional bool
+}
+
+// Key is how the API server tells objects of a namespace apart; all versions of a kind share objects
+func (i ResourceIdentity) Key() string {
+	return resourceIdentity(i.GroupKind, i.Name)
+}
+
+// String returns Kind/name
+func (i ResourceIdentity) String() string {
+	return i.Kind + "/" + i.Name
+}
+
+func resourceIdentity(gk schema.GroupKind, name string) string {
+	return gk.String() + "/" + name
+}
+
+// ClassIdentities returns the resources nsClass renders under fixed names: its templates, except those with
+// appendHash, which are renamed by their content, and its delegation RoleBindings
+func ClassIdentities(nsClass *akuityv1.NamespaceClass, templates *TemplateCache) ([]ResourceIdentity, error) {
+	decoded, err := templates.DecodedTemplates(nsClass)
+	if err != nil {
+		return nil, err
+	}
+	var ids []ResourceIdentity
+	for i, tmpl := range nsClass.Spec.Resources {
+		obj := decoded[i]
+		if obj == nil || tmpl.AppendHash {
+			continue
+		}
+		ids = append(ids, ResourceIdentity{
+			GroupKind:   obj.GroupVersionKind().GroupKind(),
+			Name:        obj.GetName(),
+			Conditional: tmpl.Condition != "",
+		})
+	}
+	roleBinding := rbacv1.SchemeGroupVersion.WithKind("RoleBinding").GroupKind()
+	for _, d := range nsClass.Spec.Delegation {
+		ids = append(ids, ResourceIdentity{GroupKind: roleBinding, Name: delegationName(d)})
+	}
+	return ids, nil
+}
+
+// checkDuplicateIdentities rejects a render with two resources of the same kind and name. Server-side apply would
+// let the later one silently overwrite the earlier, and the inventory would record the object twice.
+func checkDuplicateIdentities(rendered []Resource) error {
+	seen := make(map[string]bool, len(rendered))
+	for _, res := range rendered {
+		id := resourceIdentity(res.Object.GroupVersionKind().GroupKind(), res.Object.GetName())
+		if seen[id] {
+			return fmt.Errorf("class renders %s/%s more than once", res.Object.GetKind(), res.Object.GetName())
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// ValidateResourceIdentities rejects a class with two templates, or a template and a delegation RoleBinding, of the
+// same kind and name that always render together. Pairs where both templates have a condition may be mutually
+// exclusive, so those are only checked at render time.
+func ValidateResourceIdentities(nsClass *akuityv1.NamespaceClass) error {
+	ids, err := ClassIdentities(nsClass, nil)
+	if err != nil {
+		return err
+	}
+	// conditional maps each identity to whether every template seen with it so far has a condition
+	conditional := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if prev, ok := conditional[id.Key()]; ok && (!prev || !id.Conditional) {
+			return fmt.Errorf("%s is defined more than once", id)
+		}
+		conditional[id.Key()] = id.Conditional
+	}
+	return nil
+}
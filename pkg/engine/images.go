@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultRegistry is the registry of image references that do not name one
+const defaultRegistry = "docker.io"
+
+// RegistryMirror redirects images pulled from Registry to Mirror, a registry host optionally followed by a path
+// prefix. Registry "*" matches every registry and keeps the original registry as the first path segment.
+type RegistryMirror struct {
+	Registry string
+	Mirror   string
+}
+
+// ParseRegistryMirrors parses comma-separated registry=mirror pairs, e.g.
+// "docker.io=mirror.internal/dockerhub,*=mirror.internal/all"
+func ParseRegistryMirrors(spec string) ([]RegistryMirror, error) {
+	var mirrors []RegistryMirror
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		registry, mirror, ok := strings.Cut(pair, "=")
+		mirror = strings.TrimSuffix(mirror, "/")
+		if !ok || registry == "" || mirror == "" {
+			return nil, fmt.Errorf("registry mirror %q must be registry=mirror", pair)
+		}
+		mirrors = append(mirrors, RegistryMirror{Registry: registry, Mirror: mirror})
+	}
+	return mirrors, nil
+}
+
+// ImageRewriter points the container images of rendered workloads at registry mirrors, so classes shared across
+// clusters can be consumed by air-gapped ones without per-cluster copies. The first mirror matching the registry of
+// an image wins; images already pulled from a mirror are left alone.
+type ImageRewriter struct {
+	Base    Renderer
+	Mirrors []RegistryMirror
+}
+
+var _ Renderer = &ImageRewriter{}
+
+// Render implements Renderer
+func (r *ImageRewriter) Render(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error) {
+	rendered, err := r.Base.Render(ctx, ns, nsClass)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range rendered {
+		path, ok := podSpecPaths[res.Object.GetKind()]
+		if !ok {
+			continue
+		}
+		spec := nestedMapRef(res.Object.Object, path...)
+		if spec == nil {
+			continue
+		}
+		for _, key := range []string{"containers", "initContainers", "ephemeralContainers"} {
+			for _, c := range mapSlice(spec[key]) {
+				if image, ok := c["image"].(string); ok && image != "" {
+					c["image"] = r.rewrite(image)
+				}
+			}
+		}
+	}
+	return rendered, nil
+}
+
+// rewrite returns image pulled from the first mirror of its registry, or image unchanged when none applies
+func (r *ImageRewriter) rewrite(image string) string {
+	for _, m := range r.Mirrors {
+		if strings.HasPrefix(image, m.Mirror+"/") {
+			return image
+		}
+	}
+	registry, repository := splitImage(image)
+	for _, m := range r.Mirrors {
+		if m.Registry == registry {
+			return m.Mirror + "/" + repository
+		}
+		if m.Registry == "*" {
+			// Keep the registry in the path so repositories of different registries do not collide
+			return m.Mirror + "/" + registry + "/" + repository
+		}
+	}
+	return image
+}
+
+// splitImage splits an image reference into its registry and the repository path with tag or digest, applying the
+// Docker defaults: nginx is docker.io/library/nginx
+func splitImage(image string) (string, string) {
+	first, rest, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first, rest
+	}
+	if !ok {
+		return defaultRegistry, "library/" + image
+	}
+	return defaultRegistry, image
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ClassGetter returns the class called name, as it should be inherited from
+type ClassGetter func(name string) (*akuityv1.NamespaceClass, error)
+
+// ResolveInheritance returns nsClass with the resources and values of the classes in its spec.inheritFrom, and
+// theirs in turn, merged in. Parents are merged in order, so a later parent overrides an earlier one, and the class
+// itself overrides all of them: resources by group, kind and name, values by key. Classes without inheritFrom are
+// returned as they are. The result has no UID, so the template and render caches, which cannot tell when a parent
+// changes, leave it alone.
+func ResolveInheritance(nsClass *akuityv1.NamespaceClass, get ClassGetter) (*akuityv1.NamespaceClass, error) {
+	if len(nsClass.Spec.InheritFrom) == 0 {
+		return nsClass, nil
+	}
+	spec, err := inheritedSpec(nsClass, get, []string{nsClass.Name})
+	if err != nil {
+		return nil, err
+	}
+	resolved := nsClass.DeepCopy()
+	resolved.UID = ""
+	resolved.Spec.Resources = spec.Resources
+	resolved.Spec.Values = spec.Values
+	resolved.Spec.InheritFrom = nil
+	return resolved, nil
+}
+
+// inheritedSpec merges the parents of nsClass and then nsClass itself; chain lists the classes being resolved, to
+// detect cycles
+func inheritedSpec(nsClass *akuityv1.NamespaceClass, get ClassGetter, chain []string) (*akuityv1.NamespaceClassSpec, error) {
+	merged := &akuityv1.NamespaceClassSpec{}
+	for _, parentName := range nsClass.Spec.InheritFrom {
+		for _, seen := range chain {
+			if seen == parentName {
+				return nil, fmt.Errorf("inheritance cycle: %s -> %s", strings.Join(chain, " -> "), parentName)
+			}
+		}
+		parent, err := get(parentName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent class %s of %s: %w", parentName, nsClass.Name, err)
+		}
+		spec, err := inheritedSpec(parent, get, append(chain[:len(chain):len(chain)], parentName))
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeSpec(merged, spec); err != nil {
+			return nil, fmt.Errorf("class %s: %w", parentName, err)
+		}
+	}
+	if err := mergeSpec(merged, &nsClass.Spec); err != nil {
+		return nil, fmt.Errorf("class %s: %w", nsClass.Name, err)
+	}
+	return merged, nil
+}
+
+// mergeSpec adds the resources and values of src to dst, replacing the ones dst already has in place
+func mergeSpec(dst, src *akuityv1.NamespaceClassSpec) error {
+	index := make(map[string]int, len(dst.Resources))
+	for i, tmpl := range dst.Resources {
+		id, err := templateIdentity(tmpl)
+		if err != nil {
+			return err
+		}
+		index[id] = i
+	}
+	for _, tmpl := range src.Resources {
+		id, err := templateIdentity(tmpl)
+		if err != nil {
+			return err
+		}
+		var copied akuityv1.ResourceTemplate
+		tmpl.DeepCopyInto(&copied)
+		if i, ok := index[id]; ok {
+			dst.Resources[i] = copied
+			continue
+		}
+		index[id] = len(dst.Resources)
+		dst.Resources = append(dst.Resources, copied)
+	}
+	for key, value := range src.Values {
+		if dst.Values == nil {
+			dst.Values = map[string]string{}
+		}
+		dst.Values[key] = value
+	}
+	return nil
+}
+
+// templateIdentity returns the group, kind and name a resource template renders, the identity a child template
+// overrides a parent one by; versions are left out so a child can move a resource to a newer API version
+func templateIdentity(tmpl akuityv1.ResourceTemplate) (string, error) {
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	// Templates built in-process may carry a decoded object instead of raw JSON
+	raw, err := tmpl.Template.MarshalJSON()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resource template: %w", err)
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return "", fmt.Errorf("failed to decode resource template: %w", err)
+	}
+	gv, err := schema.ParseGroupVersion(meta.APIVersion)
+	if err != nil {
+		return "", err
+	}
+	return gv.Group + "/" + meta.Kind + "/" + meta.Metadata.Name, nil
+}
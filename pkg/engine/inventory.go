@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// InventoryItem identifies one resource the controller manages in a Namespace
+type InventoryItem struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	// Adopted marks a pre-existing resource taken under management by `kubectl nsclass adopt`.
+	// It is kept across reconciles even though no template renders it, and removed on cleanup.
+	Adopted bool `json:"adopted,omitempty"`
+	// Generation is the class generation the resource was last rendered from
+	Generation int64 `json:"generation,omitempty"`
+	// Class names the additional class the resource was rendered from, whose generation Generation is; empty for
+	// the class the namespace is labeled with
+	Class string `json:"class,omitempty"`
+	// Hash identifies the rendered content, so a changed render is visible without fetching the object
+	Hash string `json:"hash,omitempty"`
+	// Created is when the resource was first recorded in the inventory
+	Created *metav1.Time `json:"created,omitempty"`
+	// Protected marks a resource whose template has deletionProtection set
+	Protected bool `json:"protected,omitempty"`
+	// Unowned marks a resource rendered without the Namespace ownerReference, which garbage collection does not
+	// remove with the namespace
+	Unowned bool `json:"unowned,omitempty"`
+	// Discovered marks a resource recorded as it already existed when the operator first synced the namespace. It is
+	// not written until its render changes from the one recorded in Hash, see WithAdoption.
+	Discovered bool `json:"discovered,omitempty"`
+	// Blocked marks a resource that was not applied at the last sync because the CustomResourceDefinition of its kind
+	// was being deleted. It is kept in the inventory, without a Hash, until it can be applied again.
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+// CleanupFinalizer holds a namespace whose inventory has resources garbage collection does not remove with it, see
+// NeedsCleanup, until the operator has deleted them
+const CleanupFinalizer = "namespaceclass.akuity.io/cleanup"
+
+// NeedsCleanup reports whether item has to be deleted by the operator when its namespace is deleted: it is unowned
+// or adopted, so it carries no ownerReference to the namespace
+func NeedsCleanup(item InventoryItem) bool {
+	return item.Unowned || item.Adopted
+}
+
+// Key returns the identity of the item used to compare inventories
+func (i InventoryItem) Key() string {
+	return fmt.Sprintf("%s|%s|%s|%s", i.APIVersion, i.Kind, i.Namespace, i.Name)
+}
+
+// ItemFor returns the inventory identity of an object
+func ItemFor(obj *unstructured.Unstructured) InventoryItem {
+	return InventoryItem{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+	}
+}
+
+// RenderHash returns a short digest of a rendered object
+func RenderHash(obj *unstructured.Unstructured) (string, error) {
+	b, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// CarryOverCreated keeps the creation time of items already in the old inventory and stamps new ones with now
+func CarryOverCreated(old, items []InventoryItem, now metav1.Time) []InventoryItem {
+	created := make(map[string]*metav1.Time, len(old))
+	for _, item := range old {
+		if item.Created != nil {
+			created[item.Key()] = item.Created
+		}
+	}
+	for i := range items {
+		if t, ok := created[items[i].Key()]; ok {
+			items[i].Created = t
+		} else if items[i].Created == nil {
+			items[i].Created = now.DeepCopy()
+		}
+	}
+	return items
+}
+
+// Items extracts the inventory from apply results
+func Items(results []ApplyResult) []InventoryItem {
+	items := make([]InventoryItem, 0, len(results))
+	for _, res := range results {
+		items = append(items, res.Item)
+	}
+	return items
+}
+
+// CarryOverAdopted appends adopted items from the old inventory that no template rendered this time
+func CarryOverAdopted(old, applied []InventoryItem) []InventoryItem {
+	seen := make(map[string]bool, len(applied))
+	for _, item := range applied {
+		seen[item.Key()] = true
+	}
+	for _, item := range old {
+		if item.Adopted && !seen[item.Key()] {
+			applied = append(applied, item)
+		}
+	}
+	return applied
+}
+
+// NamespaceInventory decodes the resource inventory stored in the Namespace annotations
+func NamespaceInventory(ns *corev1.Namespace) ([]InventoryItem, error) {
+	ann := ns.GetAnnotations()
+	if ann == nil {
+		return nil, nil
+	}
+	raw, ok := ann[InventoryAnnotation]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	var items []InventoryItem
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AnnotationStore keeps the inventory in annotations on the Namespace itself
+type AnnotationStore struct {
+	Client       client.Client
+	FieldManager string
+}
+
+var _ InventoryStore = &AnnotationStore{}
+
+// Get retrieves resource inventory from Namespace annotations. Inventories in an older format are upgraded in memory;
+// the next Set persists them in the current format.
+func (s *AnnotationStore) Get(ctx context.Context, ns *corev1.Namespace) ([]InventoryItem, error) {
+	version, err := InventoryVersion(ns)
+	if err != nil {
+		return nil, err
+	}
+	items, err := NamespaceInventory(ns)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 && version < CurrentInventoryVersion {
+		return MigrateInventory(ctx, s.Client, items, version)
+	}
+	return items, nil
+}
+
+// Set updates Namespace annotations with current resource inventory
+func (s *AnnotationStore) Set(ctx context.Context, ns *corev1.Namespace, className string, items []InventoryItem) error {
+	if len(items) == 0 {
+		return applyNamespaceInventory(ctx, s.Client, s.FieldManager, ns.Name, nil, false)
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return applyNamespaceInventory(ctx, s.Client, s.FieldManager, ns.Name, map[string]string{
+		InventoryAnnotation:        string(b),
+		InventoryVersionAnnotation: strconv.Itoa(CurrentInventoryVersion),
+		AttachedClassAnnotation:    className,
+	}, slices.ContainsFunc(items, NeedsCleanup))
+}
+
+// applyNamespaceInventory applies the inventory annotations of a Namespace, and CleanupFinalizer when cleanup is set.
+// Applying without an annotation releases it, which removes it from the Namespace.
+func applyNamespaceInventory(ctx context.Context, c client.Client, fieldManager, name string, annotations map[string]string, cleanup bool) error {
+	patch := &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+		},
+	}
+	// Finalizers are a set, so applying without ours removes only ours once nothing needs cleanup
+	if cleanup {
+		patch.Finalizers = []string{CleanupFinalizer}
+	}
+
+	patchOpts := &client.PatchOptions{
+		FieldManager: fieldManager,
+	}
+	//aligned with controller
+	force := true
+	patchOpts.Force = &force
+
+	return c.Patch(ctx, patch, client.Apply, patchOpts, client.ForceOwnership)
+}
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// InventoryConfigMapName is the ConfigMap in each namespace holding its inventory, see ConfigMapStore
+	InventoryConfigMapName = "namespaceclass-inventory"
+	// InventoryConfigMapLabel marks inventory ConfigMaps, so they can be cached without caching every ConfigMap
+	InventoryConfigMapLabel = "namespaceclass.akuity.io/inventory"
+	// inventoryConfigMapKey is the data key holding the JSON inventory
+	inventoryConfigMapKey = "inventory"
+)
+
+// ConfigMapStore keeps the inventory in the InventoryConfigMapName ConfigMap of each namespace. A ConfigMap holds up
+// to 1MiB, where all annotations of a Namespace share 256KiB, and the Namespace no longer grows with its class.
+// The Namespace keeps AttachedClassAnnotation and CleanupFinalizer, which are read without the inventory.
+//
+// Inventories found in Namespace annotations, written by AnnotationStore, are read from there until the next Set
+// moves them to the ConfigMap and removes the annotations.
+type ConfigMapStore struct {
+	Client       client.Client
+	FieldManager string
+}
+
+var _ InventoryStore = &ConfigMapStore{}
+
+// Get retrieves the resource inventory from the ConfigMap of ns, or from its annotations before it was moved
+func (s *ConfigMapStore) Get(ctx context.Context, ns *corev1.Namespace) ([]InventoryItem, error) {
+	cm, err := InventoryConfigMap(ctx, s.Client, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	if cm == nil {
+		return (&AnnotationStore{Client: s.Client, FieldManager: s.FieldManager}).Get(ctx, ns)
+	}
+	items, err := ConfigMapInventory(cm)
+	if err != nil {
+		return nil, err
+	}
+	if version, _ := strconv.Atoi(cm.Annotations[InventoryVersionAnnotation]); version > 0 && version < CurrentInventoryVersion {
+		return MigrateInventory(ctx, s.Client, items, version)
+	}
+	return items, nil
+}
+
+// Set writes the resource inventory to the ConfigMap of ns and records the class on the Namespace; no items
+// deletes the ConfigMap
+func (s *ConfigMapStore) Set(ctx context.Context, ns *corev1.Namespace, className string, items []InventoryItem) error {
+	if len(items) == 0 {
+		if err := s.deleteConfigMap(ctx, ns.Name); err != nil {
+			return err
+		}
+		return applyNamespaceInventory(ctx, s.Client, s.FieldManager, ns.Name, nil, false)
+	}
+
+	cleanup := slices.ContainsFunc(items, NeedsCleanup)
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      InventoryConfigMapName,
+			Namespace: ns.Name,
+			Labels:    map[string]string{InventoryConfigMapLabel: "true"},
+			Annotations: map[string]string{
+				InventoryVersionAnnotation: strconv.Itoa(CurrentInventoryVersion),
+			},
+		},
+	}
+	if err := SetConfigMapInventory(cm, items); err != nil {
+		return err
+	}
+	// The namespace is deleted along with its content, so the ConfigMap is held until the cleanup finalizer of the
+	// Namespace has read it
+	if cleanup {
+		cm.Finalizers = []string{CleanupFinalizer}
+	}
+	if err := s.Client.Patch(ctx, cm, client.Apply, client.FieldOwner(s.FieldManager), client.ForceOwnership); err != nil {
+		return fmt.Errorf("failed to write inventory ConfigMap: %w", err)
+	}
+
+	if ns.Annotations[InventoryAnnotation] != "" {
+		log.FromContext(ctx).Info("Moved inventory from namespace annotations to ConfigMap", "configMap", InventoryConfigMapName)
+	}
+	// Applying without the inventory annotations removes any written by AnnotationStore
+	return applyNamespaceInventory(ctx, s.Client, s.FieldManager, ns.Name, map[string]string{
+		AttachedClassAnnotation: className,
+	}, cleanup)
+}
+
+// deleteConfigMap removes the inventory ConfigMap of namespace, releasing its finalizer first
+func (s *ConfigMapStore) deleteConfigMap(ctx context.Context, namespace string) error {
+	cm, err := InventoryConfigMap(ctx, s.Client, namespace)
+	if err != nil || cm == nil {
+		return err
+	}
+	if controllerutil.RemoveFinalizer(cm, CleanupFinalizer) {
+		if err := s.Client.Update(ctx, cm); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to release inventory ConfigMap: %w", err)
+		}
+	}
+	if err := s.Client.Delete(ctx, cm); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete inventory ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// InventoryConfigMap returns the inventory ConfigMap of namespace, or nil when there is none
+func InventoryConfigMap(ctx context.Context, c client.Reader, namespace string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: InventoryConfigMapName}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get inventory ConfigMap: %w", err)
+	}
+	return cm, nil
+}
+
+// ConfigMapInventory decodes the resource inventory stored in an inventory ConfigMap
+func ConfigMapInventory(cm *corev1.ConfigMap) ([]InventoryItem, error) {
+	raw := cm.Data[inventoryConfigMapKey]
+	if raw == "" {
+		return nil, nil
+	}
+	var items []InventoryItem
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SetConfigMapInventory encodes items into an inventory ConfigMap
+func SetConfigMapInventory(cm *corev1.ConfigMap, items []InventoryItem) error {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[inventoryConfigMapKey] = string(b)
+	return nil
+}
+
+// ReadInventory returns the inventory of ns from whichever store holds it: its annotations, or its inventory
+// ConfigMap when the namespace is attached to a class without them. Unlike InventoryStore.Get it does not upgrade
+// older formats.
+func ReadInventory(ctx context.Context, c client.Reader, ns *corev1.Namespace) ([]InventoryItem, error) {
+	if ns.Annotations[InventoryAnnotation] != "" || ns.Annotations[AttachedClassAnnotation] == "" {
+		return NamespaceInventory(ns)
+	}
+	cm, err := InventoryConfigMap(ctx, c, ns.Name)
+	if err != nil || cm == nil {
+		return nil, err
+	}
+	return ConfigMapInventory(cm)
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// InventoryVersionAnnotation records the format version of the inventory stored on a Namespace
+	InventoryVersionAnnotation = "namespaceclass.akuity.io/inventory-version"
+	// CurrentInventoryVersion is the inventory format written by this build
+	CurrentInventoryVersion = 3
+)
+
+// InventoryMigration upgrades an inventory from version From to From+1. It may read the cluster, e.g. to fill in
+// fields a newer format records about each resource.
+type InventoryMigration struct {
+	From    int
+	Migrate func(ctx context.Context, c client.Client, items []InventoryItem) ([]InventoryItem, error)
+}
+
+// inventoryMigrations are applied in order to inventories older than CurrentInventoryVersion
+var inventoryMigrations = []InventoryMigration{
+	{
+		// Version 1 predates the version annotation; its items are unchanged in version 2
+		From: 1,
+		Migrate: func(_ context.Context, _ client.Client, items []InventoryItem) ([]InventoryItem, error) {
+			return items, nil
+		},
+	},
+	{
+		// Version 3 records generation, hash and creation time per item. Generation and hash are filled in by the
+		// apply that follows; the creation time is taken from the live object.
+		From: 2,
+		Migrate: func(ctx context.Context, c client.Client, items []InventoryItem) ([]InventoryItem, error) {
+			for i, item := range items {
+				if item.Created != nil {
+					continue
+				}
+				obj := &unstructured.Unstructured{}
+				obj.SetAPIVersion(item.APIVersion)
+				obj.SetKind(item.Kind)
+				if err := c.Get(ctx, client.ObjectKey{Namespace: item.Namespace, Name: item.Name}, obj); err != nil {
+					if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+						continue
+					}
+					return nil, err
+				}
+				created := obj.GetCreationTimestamp()
+				items[i].Created = &created
+			}
+			return items, nil
+		},
+	},
+}
+
+// InventoryVersion returns the format version of the inventory stored on ns: 0 when it has none, 1 for inventories
+// written before versioning was introduced
+func InventoryVersion(ns *corev1.Namespace) (int, error) {
+	ann := ns.GetAnnotations()
+	if ann[InventoryAnnotation] == "" {
+		return 0, nil
+	}
+	raw, ok := ann[InventoryVersionAnnotation]
+	if !ok {
+		return 1, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid %s annotation %q", InventoryVersionAnnotation, raw)
+	}
+	return v, nil
+}
+
+// MigrateInventory upgrades items from version from to CurrentInventoryVersion
+func MigrateInventory(ctx context.Context, c client.Client, items []InventoryItem, from int) ([]InventoryItem, error) {
+	if from > CurrentInventoryVersion {
+		return nil, fmt.Errorf("inventory version %d is newer than the supported version %d", from, CurrentInventoryVersion)
+	}
+	for _, m := range inventoryMigrations {
+		if m.From < from {
+			continue
+		}
+		var err error
+		if items, err = m.Migrate(ctx, c, items); err != nil {
+			return nil, fmt.Errorf("failed to migrate inventory from version %d: %w", m.From, err)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ApplyLimit caps how many resources of one API group, or of one kind when GroupKind.Kind is set, are applied at
+// the same time across all reconciles
+type ApplyLimit struct {
+	GroupKind schema.GroupKind
+	Max       int
+}
+
+// ParseApplyLimits parses comma-separated target=max pairs, where a target is an API group or a kind written as
+// Kind.group, or Kind for core kinds, e.g. "external-secrets.io=20,ExternalSecret.external-secrets.io=5"
+func ParseApplyLimits(spec string) ([]ApplyLimit, error) {
+	var limits []ApplyLimit
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		target, value, ok := strings.Cut(pair, "=")
+		if !ok || target == "" {
+			return nil, fmt.Errorf("apply limit %q must be target=max", pair)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("apply limit %q must be a positive number", pair)
+		}
+		gk := schema.GroupKind{Group: target}
+		// Kinds are capitalized, API groups are DNS names
+		if unicode.IsUpper(rune(target[0])) {
+			gk = schema.ParseGroupKind(target)
+		}
+		limits = append(limits, ApplyLimit{GroupKind: gk, Max: n})
+	}
+	return limits, nil
+}
+
+// ApplyLimiter bounds the concurrent applies per API group or kind, so parallel namespace reconciles of a class
+// heavy in one kind do not overwhelm the admission webhook or controller serving that kind. A kind limit takes
+// precedence over the limit of its group; other kinds are applied without limit. Deletes are not limited.
+type ApplyLimiter struct {
+	Base Applier
+	// Client deletes resources when Base is not a Pruner
+	Client client.Client
+
+	slots map[schema.GroupKind]chan struct{}
+}
+
+var _ Applier = &ApplyLimiter{}
+var _ Pruner = &ApplyLimiter{}
+
+// NewApplyLimiter returns an ApplyLimiter enforcing limits around base
+func NewApplyLimiter(c client.Client, base Applier, limits []ApplyLimit) *ApplyLimiter {
+	l := &ApplyLimiter{Base: base, Client: c, slots: make(map[schema.GroupKind]chan struct{}, len(limits))}
+	for _, limit := range limits {
+		l.slots[limit.GroupKind] = make(chan struct{}, limit.Max)
+	}
+	return l
+}
+
+// slot returns the semaphore limiting gk, or nil if gk is not limited
+func (l *ApplyLimiter) slot(gk schema.GroupKind) chan struct{} {
+	if s, ok := l.slots[gk]; ok {
+		return s
+	}
+	return l.slots[schema.GroupKind{Group: gk.Group}]
+}
+
+// Apply implements Applier, waiting for a free slot of the group or kind of res first
+func (l *ApplyLimiter) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	gk := res.Object.GroupVersionKind().GroupKind()
+	slot := l.slot(gk)
+	if slot == nil {
+		return l.Base.Apply(ctx, res)
+	}
+	select {
+	case slot <- struct{}{}:
+	default:
+		log.FromContext(ctx).V(1).Info("Waiting for an apply slot", "kind", gk.String(), "limit", cap(slot))
+		select {
+		case slot <- struct{}{}:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	defer func() { <-slot }()
+	return l.Base.Apply(ctx, res)
+}
+
+// Delete implements Pruner
+func (l *ApplyLimiter) Delete(ctx context.Context, obj *unstructured.Unstructured) error {
+	if p, ok := l.Base.(Pruner); ok {
+		return p.Delete(ctx, obj)
+	}
+	return l.Client.Delete(ctx, obj)
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// TemplateKinds returns an item, without a name, for each kind nsClass renders through its templates and
+// delegation. Kinds only known once rendered, such as those of Go templates or an external renderer, are missing.
+func TemplateKinds(nsClass *akuityv1.NamespaceClass, templates *TemplateCache) ([]InventoryItem, error) {
+	objects, err := templates.DecodedTemplates(nsClass)
+	if err != nil {
+		return nil, err
+	}
+	var kinds []InventoryItem
+	for _, obj := range objects {
+		if obj == nil || obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			continue
+		}
+		kinds = append(kinds, InventoryItem{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()})
+	}
+	if len(nsClass.Spec.Delegation) > 0 {
+		kinds = append(kinds, InventoryItem{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"})
+	}
+	return kinds, nil
+}
+
+// DiscoverManaged finds the resources of class in namespace by their ManagedByLabel and SourceClassLabel, standing
+// in for an inventory that was lost, e.g. when its annotation was wiped. Only the kinds of the items in kinds are
+// searched, and resources of RetainKinds are left out. Unlike an inventory the labels can be copied onto resources
+// the operator never created, so callers should bound what they prune from the result.
+func (e *Engine) DiscoverManaged(ctx context.Context, namespace, class string, kinds []InventoryItem) ([]InventoryItem, error) {
+	selector := labels.SelectorFromSet(labels.Set{ManagedByLabel: ManagerName, SourceClassLabel: class})
+	labeled, err := e.listLabeled(ctx, namespace, selector, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover managed resources: %w", err)
+	}
+	var found []InventoryItem
+	for _, item := range labeled {
+		if !e.retains(item) {
+			found = append(found, item)
+		}
+	}
+	return found, nil
+}
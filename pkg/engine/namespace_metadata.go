@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespaceMetadataManager is the field manager of the labels and annotations classes set on namespaces. It differs
+// from the manager the inventory stores apply the Namespace with, whose applies would otherwise remove them.
+const NamespaceMetadataManager = ManagerName + "-metadata"
+
+// operatorPrefix is the prefix of the labels and annotations the operator itself keeps on namespaces
+const operatorPrefix = "namespaceclass.akuity.io/"
+
+// ValidateNamespaceMetadata rejects namespace metadata that is not valid label or annotation syntax, or that would
+// overwrite the operator's own keys, such as the class label
+func ValidateNamespaceMetadata(md *akuityv1.NamespaceMetadata) error {
+	if md == nil {
+		return nil
+	}
+	path := field.NewPath("spec", "namespaceMetadata")
+	errs := metav1validation.ValidateLabels(md.Labels, path.Child("labels"))
+	for key := range md.Annotations {
+		errs = append(errs, metav1validation.ValidateLabelName(key, path.Child("annotations"))...)
+	}
+	if len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	for _, keys := range []map[string]string{md.Labels, md.Annotations} {
+		for key := range keys {
+			if strings.HasPrefix(key, operatorPrefix) {
+				return fmt.Errorf("%s is reserved for the operator", key)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyNamespaceMetadata sets the labels and annotations of md on ns, removing those an earlier call set that md no
+// longer lists; nil md removes all of them. The write is skipped when it would not change ns. It reports whether
+// ns changed.
+func ApplyNamespaceMetadata(ctx context.Context, c client.Client, ns *corev1.Namespace, md *akuityv1.NamespaceMetadata) (bool, error) {
+	intent := &unstructured.Unstructured{}
+	intent.SetAPIVersion("v1")
+	intent.SetKind("Namespace")
+	intent.SetName(ns.Name)
+	if md != nil {
+		if len(md.Labels) > 0 {
+			intent.SetLabels(md.Labels)
+		}
+		if len(md.Annotations) > 0 {
+			intent.SetAnnotations(md.Annotations)
+		}
+	}
+	if len(intent.GetLabels()) == 0 && len(intent.GetAnnotations()) == 0 && !HasNamespaceMetadata(ns) {
+		return false, nil
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ns)
+	if err != nil {
+		return false, err
+	}
+	live := &unstructured.Unstructured{Object: obj}
+	live.SetAPIVersion("v1")
+	live.SetKind("Namespace")
+	if noop, err := applyIsNoop(live, intent, NamespaceMetadataManager); err == nil && noop {
+		return false, nil
+	}
+	if err := c.Patch(ctx, intent, client.Apply, client.FieldOwner(NamespaceMetadataManager), client.ForceOwnership); err != nil {
+		return false, fmt.Errorf("failed to apply namespace metadata: %w", err)
+	}
+	// The patch returns the whole Namespace
+	return !maps.Equal(intent.GetLabels(), ns.Labels) || !maps.Equal(intent.GetAnnotations(), ns.Annotations), nil
+}
+
+// HasNamespaceMetadata reports whether ns carries labels or annotations set by ApplyNamespaceMetadata
+func HasNamespaceMetadata(ns *corev1.Namespace) bool {
+	for _, mf := range ns.ManagedFields {
+		if mf.Manager == NamespaceMetadataManager && mf.Operation == metav1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/client-go/applyconfigurations"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/structured-merge-diff/v6/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v6/typed"
+)
+
+var (
+	// builtinTypeConverter knows the schemas of built-in kinds, including list keys, so extraction is precise
+	builtinTypeConverter = applyconfigurations.NewTypeConverter(clientgoscheme.Scheme)
+	// deducedTypeConverter treats every list as atomic; used for custom resources
+	deducedTypeConverter = managedfields.NewDeducedTypeConverter()
+)
+
+// applyIsNoop reports whether server-side applying intent would leave every field manager owns on live
+// unchanged. It extracts the fields of manager from live using its managedFields entry, the same way the typed
+// Extract<Kind> helpers of client-go do, and compares them with the rendered intent.
+// Any doubt (no managed fields, unknown type, extraction failure) answers false so the apply still happens.
+func applyIsNoop(live, intent *unstructured.Unstructured, manager string) (bool, error) {
+	var entry *metav1.ManagedFieldsEntry
+	for i, mf := range live.GetManagedFields() {
+		if mf.Manager == manager && mf.Operation == metav1.ManagedFieldsOperationApply && mf.Subresource == "" {
+			entry = &live.GetManagedFields()[i]
+			break
+		}
+	}
+	if entry == nil || entry.FieldsV1 == nil {
+		return false, nil
+	}
+	owned := &fieldpath.Set{}
+	if err := owned.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+		return false, fmt.Errorf("failed to decode managed fields: %w", err)
+	}
+
+	converter := builtinTypeConverter
+	liveTyped, err := converter.ObjectToTyped(live, typed.AllowDuplicates)
+	if err != nil {
+		converter = deducedTypeConverter
+		if liveTyped, err = converter.ObjectToTyped(live, typed.AllowDuplicates); err != nil {
+			return false, err
+		}
+	}
+
+	extracted, ok := liveTyped.ExtractItems(owned.Leaves()).AsValue().Unstructured().(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	// Identity fields are never "owned" but are always part of the apply intent
+	current := &unstructured.Unstructured{Object: extracted}
+	current.SetAPIVersion(live.GetAPIVersion())
+	current.SetKind(live.GetKind())
+	current.SetName(live.GetName())
+	current.SetNamespace(live.GetNamespace())
+
+	currentTyped, err := converter.ObjectToTyped(current, typed.AllowDuplicates)
+	if err != nil {
+		return false, err
+	}
+	intentTyped, err := converter.ObjectToTyped(intent, typed.AllowDuplicates)
+	if err != nil {
+		return false, err
+	}
+	cmp, err := currentTyped.Compare(intentTyped)
+	if err != nil {
+		return false, err
+	}
+	return cmp.IsSame(), nil
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// installOrder ranks kinds so that a kind only depends on kinds ranked before it: namespace policy and identity
+// first, then configuration and RBAC, then services and workloads. Kinds not listed, such as custom resources,
+// rank after all of them.
+var installOrder = []string{
+	"ResourceQuota",
+	"LimitRange",
+	"NetworkPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"PersistentVolumeClaim",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"Pod",
+	"ReplicaSet",
+	"Deployment",
+	"StatefulSet",
+	"DaemonSet",
+	"Job",
+	"CronJob",
+	"HorizontalPodAutoscaler",
+	"VerticalPodAutoscaler",
+	"Ingress",
+}
+
+var installRank = func() map[string]int {
+	ranks := make(map[string]int, len(installOrder))
+	for i, kind := range installOrder {
+		ranks[kind] = i
+	}
+	return ranks
+}()
+
+// kindRank returns the install rank of kind
+func kindRank(kind string) int {
+	if rank, ok := installRank[kind]; ok {
+		return rank
+	}
+	return len(installOrder)
+}
+
+// crdsFirst moves CustomResourceDefinitions ahead of every other resource, keeping the order otherwise, so custom
+// resources of a class can be applied in the same pass as the definitions it bundles
+func crdsFirst(resources []Resource) []Resource {
+	ordered := make([]Resource, 0, len(resources))
+	for _, res := range resources {
+		if isCRD(res.Object.GroupVersionKind()) {
+			ordered = append(ordered, res)
+		}
+	}
+	for _, res := range resources {
+		if !isCRD(res.Object.GroupVersionKind()) {
+			ordered = append(ordered, res)
+		}
+	}
+	return ordered
+}
+
+func isCRD(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+// pruneOrder returns items in reverse dependency order: custom resources and workloads before the RoleBindings,
+// ServiceAccounts and Secrets their terminating pods may still need. Items of the same rank are deleted in
+// reverse inventory order.
+func pruneOrder(items []InventoryItem) []InventoryItem {
+	ordered := make([]InventoryItem, len(items))
+	for i := range items {
+		ordered[i] = items[len(items)-1-i]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return kindRank(ordered[i].Kind) > kindRank(ordered[j].Kind)
+	})
+	return ordered
+}
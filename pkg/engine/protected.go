@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// OperatorNamePrefix is the name prefix of the cluster-scoped resources the operator is installed with, such as its
+// ClusterRole, ClusterRoleBinding and webhook configuration
+const OperatorNamePrefix = "namespaceclass-operator"
+
+// ProtectedObject matches resources classes may not template, so a class cannot take over the resources the
+// operator itself depends on
+type ProtectedObject struct {
+	GroupKind schema.GroupKind
+	// Name is a glob pattern matched against the resource name
+	Name string
+}
+
+// OperatorObjects returns the resources of an operator running in namespace: its Namespace, its cluster-scoped RBAC
+// and webhook configurations, and the CustomResourceDefinitions of its API group. Its namespaced resources, such as
+// the Deployment, are protected by keeping classes out of namespace altogether.
+func OperatorObjects(namespace string) []ProtectedObject {
+	return []ProtectedObject{
+		{GroupKind: schema.GroupKind{Kind: "Namespace"}, Name: namespace},
+		{GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}, Name: OperatorNamePrefix + "*"},
+		{GroupKind: schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}, Name: OperatorNamePrefix + "*"},
+		{GroupKind: schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}, Name: OperatorNamePrefix + "*"},
+		{GroupKind: schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}, Name: OperatorNamePrefix + "*"},
+		{GroupKind: schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}, Name: "*.core.akuity.io"},
+	}
+}
+
+// checkProtected rejects obj when one of protected matches it
+func checkProtected(protected []ProtectedObject, obj *unstructured.Unstructured) error {
+	gk := obj.GroupVersionKind().GroupKind()
+	for _, p := range protected {
+		if ok, _ := path.Match(p.Name, obj.GetName()); ok && p.GroupKind == gk {
+			return fmt.Errorf("%s %s belongs to the operator and is not allowed in class templates", gk, obj.GetName())
+		}
+	}
+	return nil
+}
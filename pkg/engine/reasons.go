@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// Failure reasons used consistently in conditions, events and the reason metric label
+const (
+	ReasonRenderError     = "RenderError"
+	ReasonRBACDenied      = "RBACDenied"
+	ReasonQuotaExceeded   = "QuotaExceeded"
+	ReasonAdmissionDenied = "AdmissionDenied"
+	ReasonConflict        = "Conflict"
+	ReasonMissingCRD      = "MissingCRD"
+	ReasonTimeout         = "Timeout"
+	ReasonBudgetExceeded  = "BudgetExceeded"
+	ReasonUnknown         = "Unknown"
+)
+
+// reasonError attaches a failure reason to an error raised by the engine or the controllers
+type reasonError struct {
+	reason string
+	err    error
+}
+
+func (e *reasonError) Error() string { return e.err.Error() }
+func (e *reasonError) Unwrap() error { return e.err }
+
+// WithReason tags err with an explicit failure reason, overriding classification of the wrapped error
+func WithReason(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &reasonError{reason: reason, err: err}
+}
+
+// renderFailure marks an error raised while rendering, before anything was applied
+type renderFailure struct {
+	err error
+}
+
+func (e *renderFailure) Error() string { return e.err.Error() }
+func (e *renderFailure) Unwrap() error { return e.err }
+
+// applyFailure marks an error raised while applying one rendered resource
+type applyFailure struct {
+	kind string
+	err  error
+}
+
+func (e *applyFailure) Error() string { return e.err.Error() }
+func (e *applyFailure) Unwrap() error { return e.err }
+
+// FailedKind returns the kind of the resource whose apply raised err, or "" when err did not come from applying a
+// resource
+func FailedKind(err error) string {
+	var af *applyFailure
+	if stderrors.As(err, &af) {
+		return af.kind
+	}
+	return ""
+}
+
+// IsRenderFailure reports whether err came from rendering a class rather than from applying its resources
+func IsRenderFailure(err error) bool {
+	var rf *renderFailure
+	return stderrors.As(err, &rf)
+}
+
+// IsNamespaceTerminating reports whether err is the API server refusing to create content in a namespace that is
+// being deleted. Such failures resolve themselves once the namespace is gone and are not sync errors.
+func IsNamespaceTerminating(err error) bool {
+	return errors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+}
+
+var (
+	// webhookDenial matches the message of a request denied by a validating or mutating webhook
+	webhookDenial = regexp.MustCompile(`admission webhook "([^"]+)" denied the request`)
+	// policyDenial matches the message of a request denied by a ValidatingAdmissionPolicy
+	policyDenial = regexp.MustCompile(`ValidatingAdmissionPolicy '([^']+)' with binding '([^']+)' denied request`)
+)
+
+// AdmissionDenier names the admission webhook or ValidatingAdmissionPolicy that denied the request err reports, or
+// returns "" when err is not an admission denial
+func AdmissionDenier(err error) string {
+	msg := err.Error()
+	if m := webhookDenial.FindStringSubmatch(msg); m != nil {
+		return fmt.Sprintf("admission webhook %q", m[1])
+	}
+	if m := policyDenial.FindStringSubmatch(msg); m != nil {
+		return fmt.Sprintf("ValidatingAdmissionPolicy %q (binding %q)", m[1], m[2])
+	}
+	return ""
+}
+
+// ClassifyError maps an error to one of the machine-readable failure reasons
+func ClassifyError(err error) string {
+	var re *reasonError
+	if stderrors.As(err, &re) {
+		return re.reason
+	}
+
+	msg := err.Error()
+	switch {
+	case meta.IsNoMatchError(err):
+		return ReasonMissingCRD
+	case AdmissionDenier(err) != "":
+		return ReasonAdmissionDenied
+	case errors.IsForbidden(err) && strings.Contains(msg, "exceeded quota"):
+		return ReasonQuotaExceeded
+	case errors.IsForbidden(err) || errors.IsUnauthorized(err):
+		return ReasonRBACDenied
+	case errors.IsConflict(err) || errors.IsAlreadyExists(err):
+		return ReasonConflict
+	case errors.IsInvalid(err) || errors.IsBadRequest(err):
+		// The class produced an object the API server refuses to accept
+		return ReasonRenderError
+	case errors.IsTimeout(err) || errors.IsServerTimeout(err) || errors.IsTooManyRequests(err) ||
+		stderrors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout
+	}
+	return ReasonUnknown
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Pruner is implemented by appliers that need custom deletion, e.g. a propagation policy
+type Pruner interface {
+	Delete(ctx context.Context, obj *unstructured.Unstructured) error
+}
+
+// StrategyFactory builds the Applier for one kind around the default applier
+type StrategyFactory func(c client.Client, base Applier) Applier
+
+var (
+	strategiesMu sync.Mutex
+	strategies   = map[schema.GroupVersionKind]StrategyFactory{}
+)
+
+// RegisterStrategy makes every Registry built afterwards use factory for gvk. Downstream builds call it from an init
+// function to change how special kinds are applied and pruned; a later registration replaces an earlier one.
+func RegisterStrategy(gvk schema.GroupVersionKind, factory StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[gvk] = factory
+}
+
+// Registry dispatches each resource to the Applier registered for its GroupVersionKind, falling back to Default
+type Registry struct {
+	Default Applier
+	// Client deletes resources whose applier is not a Pruner
+	Client client.Client
+
+	appliers map[schema.GroupVersionKind]Applier
+}
+
+var _ Applier = &Registry{}
+var _ Pruner = &Registry{}
+
+// NewRegistry returns a Registry with every strategy registered through RegisterStrategy
+func NewRegistry(c client.Client, base Applier) *Registry {
+	r := &Registry{Default: base, Client: c, appliers: map[schema.GroupVersionKind]Applier{}}
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	for gvk, factory := range strategies {
+		r.appliers[gvk] = factory(c, base)
+	}
+	return r
+}
+
+// Register sets the Applier used for gvk by this registry only
+func (r *Registry) Register(gvk schema.GroupVersionKind, a Applier) {
+	if r.appliers == nil {
+		r.appliers = map[schema.GroupVersionKind]Applier{}
+	}
+	r.appliers[gvk] = a
+}
+
+// RegisterBase makes base the Applier for gvk by this registry only, wrapped by the strategy registered for gvk
+// through RegisterStrategy, if any, in place of the default applier
+func (r *Registry) RegisterBase(gvk schema.GroupVersionKind, base Applier) {
+	strategiesMu.Lock()
+	factory, ok := strategies[gvk]
+	strategiesMu.Unlock()
+	if ok {
+		base = factory(r.Client, base)
+	}
+	r.Register(gvk, base)
+}
+
+// For returns the Applier responsible for gvk
+func (r *Registry) For(gvk schema.GroupVersionKind) Applier {
+	if a, ok := r.appliers[gvk]; ok {
+		return a
+	}
+	return r.Default
+}
+
+// Apply implements Applier
+func (r *Registry) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	return r.For(res.Object.GroupVersionKind()).Apply(ctx, res)
+}
+
+// Delete implements Pruner
+func (r *Registry) Delete(ctx context.Context, obj *unstructured.Unstructured) error {
+	if p, ok := r.For(obj.GroupVersionKind()).(Pruner); ok {
+		return p.Delete(ctx, obj)
+	}
+	return r.Client.Delete(ctx, obj)
+}
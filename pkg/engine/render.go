@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+)
+
+// TemplateRenderer renders the templates of a class as they are written, with the operator's metadata added
+type TemplateRenderer struct {
+	// Templates caches decoded class templates across renders; nil disables caching
+	Templates *TemplateCache
+	// Renders caches the rendered resources of each namespace across renders; nil disables caching
+	Renders *RenderCache
+	// AnnotateSource stamps each resource with the class generation it was rendered from. Every generation bump then
+	// rewrites all resources of the class.
+	AnnotateSource bool
+	// LabelGeneration labels each resource with the class generation it was rendered from (ClassGenerationLabel),
+	// which lets PruneStale find leftovers of failed rollouts. Like AnnotateSource, every generation bump then
+	// rewrites all resources of the class.
+	LabelGeneration bool
+	// DenyCRDs fails the render of classes with CustomResourceDefinition templates, for operators whose policy
+	// does not let classes install cluster-wide APIs
+	DenyCRDs bool
+	// ForbiddenKinds fail the render of classes that template them
+	ForbiddenKinds []schema.GroupKind
+	// Protected fail the render of classes that template them, checked once names are substituted
+	Protected []ProtectedObject
+	// Instance, when set, is recorded on each resource in AppliedByAnnotation, so resources written by several
+	// operator instances can be told apart
+	Instance string
+	// Profile describes the cluster to template conditions; nil means an empty profile
+	Profile ProfileSource
+}
+
+var _ Renderer = &TemplateRenderer{}
+
+// Render turns the templates of a NamespaceClass into the objects to apply in the target Namespace
+func (t *TemplateRenderer) Render(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) ([]Resource, error) {
+	var rendered []Resource
+
+	var profile map[string]string
+	if t.Profile != nil {
+		var err error
+		if profile, err = t.Profile.Profile(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read cluster profile: %w", err)
+		}
+	}
+	if cached, ok := t.Renders.lookup(ns, nsClass, profile); ok {
+		return cached, nil
+	}
+
+	decoded, err := t.Templates.DecodedTemplates(nsClass)
+	if err != nil {
+		return nil, WithReason(ReasonRenderError, err)
+	}
+
+	values := classValues(ns, nsClass)
+	for i, tmpl := range nsClass.Spec.Resources {
+		if decoded[i] == nil {
+			continue
+		}
+		if tmpl.Condition != "" {
+			ok, err := EvaluateCondition(tmpl.Condition, ns, profile)
+			if err != nil {
+				return nil, WithReason(ReasonRenderError, fmt.Errorf("invalid condition on %s/%s: %w", decoded[i].GetKind(), decoded[i].GetName(), err))
+			}
+			if !ok {
+				continue
+			}
+		}
+		if t.DenyCRDs && isCRD(decoded[i].GroupVersionKind()) {
+			return nil, WithReason(ReasonRenderError, fmt.Errorf("CustomResourceDefinition %s is not allowed in class templates", decoded[i].GetName()))
+		}
+		if gk := decoded[i].GroupVersionKind().GroupKind(); slices.Contains(t.ForbiddenKinds, gk) {
+			return nil, WithReason(ReasonRenderError, fmt.Errorf("%s %s is not allowed in class templates", gk, decoded[i].GetName()))
+		}
+		// Cached templates are shared, so work on a copy
+		obj := decoded[i].DeepCopy()
+		// Go templates run first, so value overrides set by namespace annotations are never executed as templates
+		if tmpl.GoTemplate {
+			if err := executeGoTemplates(obj.Object, ns, values); err != nil {
+				return nil, WithReason(ReasonRenderError, fmt.Errorf("invalid template %s/%s: %w", obj.GetKind(), obj.GetName(), err))
+			}
+		}
+		if err := substituteValues(obj.Object, values); err != nil {
+			return nil, WithReason(ReasonRenderError, fmt.Errorf("invalid template %s/%s: %w", obj.GetKind(), obj.GetName(), err))
+		}
+		if err := checkProtected(t.Protected, obj); err != nil {
+			return nil, WithReason(ReasonRenderError, err)
+		}
+
+		// Drop fields owned by someone else before the controller adds its own metadata
+		for _, field := range tmpl.IgnoreFields {
+			if err := removeJSONPointer(obj.Object, field); err != nil {
+				return nil, WithReason(ReasonRenderError, fmt.Errorf("invalid ignoreFields entry for %s/%s: %w", obj.GetKind(), obj.GetName(), err))
+			}
+		}
+
+		t.decorate(obj, ns, nsClass)
+		if tmpl.OmitOwnerReference {
+			obj.SetOwnerReferences(nil)
+		}
+		rendered = append(rendered, Resource{
+			Object:       obj,
+			UpdatePolicy: tmpl.UpdatePolicy,
+			AppendHash:   tmpl.AppendHash,
+			Protected:    tmpl.DeletionProtection,
+			// Quotas and similar guardrails must not be overridable by whoever else writes the same fields
+			ForceOwnership: slices.Contains(nsClass.Spec.ForceOwnershipKinds, obj.GetKind()),
+		})
+	}
+
+	bindings, err := delegationBindings(ns, nsClass)
+	if err != nil {
+		return nil, WithReason(ReasonRenderError, err)
+	}
+	for _, obj := range bindings {
+		t.decorate(obj, ns, nsClass)
+		rendered = append(rendered, Resource{Object: obj})
+	}
+	rendered = withoutKinds(rendered, skippedKinds(ctx, ns, nsClass))
+
+	if err := appendContentHashes(rendered); err != nil {
+		return nil, WithReason(ReasonRenderError, err)
+	}
+	if err := checkDuplicateIdentities(rendered); err != nil {
+		return nil, WithReason(ReasonRenderError, err)
+	}
+	if err := checkResourceBudget(nsClass.Spec.ResourceBudget, rendered); err != nil {
+		return nil, WithReason(ReasonBudgetExceeded, err)
+	}
+	t.Renders.store(ns, nsClass, profile, rendered)
+	return rendered, nil
+}
+
+// decorate adds the operator's metadata to a rendered object; the controller's own labels always win
+func (t *TemplateRenderer) decorate(obj *unstructured.Unstructured, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) {
+	obj.SetNamespace(ns.Name)
+	labels := mergeMissing(obj.GetLabels(), nsClass.Spec.CommonLabels)
+	labels[ManagedByLabel] = ManagerName
+	labels[SourceClassLabel] = nsClass.Name
+	if t.LabelGeneration {
+		labels[ClassGenerationLabel] = strconv.FormatInt(nsClass.Generation, 10)
+	}
+	obj.SetLabels(labels)
+	if len(nsClass.Spec.CommonAnnotations) > 0 {
+		obj.SetAnnotations(mergeMissing(obj.GetAnnotations(), nsClass.Spec.CommonAnnotations))
+	}
+	if t.AnnotateSource {
+		annotations := mergeMissing(obj.GetAnnotations(), nil)
+		annotations[SourceGenerationAnnotation] = strconv.FormatInt(nsClass.Generation, 10)
+		obj.SetAnnotations(annotations)
+	}
+	if t.Instance != "" {
+		annotations := mergeMissing(obj.GetAnnotations(), nil)
+		annotations[AppliedByAnnotation] = t.Instance
+		obj.SetAnnotations(annotations)
+	}
+
+	// Set OwnerReference to Namespace for garbage collection
+	ownerRef := metav1.OwnerReference{
+		APIVersion:         "v1",
+		Kind:               "Namespace",
+		Name:               ns.Name,
+		UID:                ns.UID,
+		BlockOwnerDeletion: pointer.Bool(true),
+		Controller:         pointer.Bool(true),
+	}
+	obj.SetOwnerReferences([]metav1.OwnerReference{ownerRef})
+}
+
+// checkResourceBudget rejects a render that exceeds the per-namespace budget of the class
+func checkResourceBudget(budget *akuityv1.ResourceBudget, rendered []Resource) error {
+	if budget == nil {
+		return nil
+	}
+	if budget.MaxObjects != nil && len(rendered) > int(*budget.MaxObjects) {
+		return fmt.Errorf("class renders %d objects, exceeding the budget of %d", len(rendered), *budget.MaxObjects)
+	}
+	if budget.MaxTotalSize != nil {
+		var total int64
+		for _, res := range rendered {
+			b, err := res.Object.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			total += int64(len(b))
+		}
+		if limit := budget.MaxTotalSize.Value(); total > limit {
+			return fmt.Errorf("class renders %d bytes, exceeding the budget of %s", total, budget.MaxTotalSize.String())
+		}
+	}
+	return nil
+}
+
+// mergeMissing returns dst (allocated if nil) with the entries of defaults it does not already define
+func mergeMissing(dst, defaults map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(defaults))
+	}
+	for k, v := range defaults {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+// removeJSONPointer deletes the field addressed by an RFC 6901 pointer; a path that does not exist is not an error
+func removeJSONPointer(obj map[string]interface{}, pointer string) error {
+	if !strings.HasPrefix(pointer, "/") {
+		return fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	switch pointer {
+	case "/apiVersion", "/kind", "/metadata", "/metadata/name":
+		return fmt.Errorf("JSON pointer %q addresses the object identity", pointer)
+	}
+
+	var cur interface{} = obj
+	for i, token := range tokens {
+		last := i == len(tokens)-1
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			if last {
+				delete(node, token)
+				return nil
+			}
+			cur = node[token]
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 {
+				return fmt.Errorf("JSON pointer %q has non-numeric array index %q", pointer, token)
+			}
+			if idx >= len(node) {
+				return nil
+			}
+			if last {
+				return fmt.Errorf("JSON pointer %q cannot remove an array element", pointer)
+			}
+			cur = node[idx]
+		default:
+			return nil
+		}
+	}
+	return nil
+}
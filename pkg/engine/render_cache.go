@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RenderCache holds what each class last rendered into each namespace so repeat reconciles of an unchanged namespace
+// skip rendering. Entries are kept per class, so the additional classes of a namespace are cached alongside the one
+// in its label. An entry is reused only while the class generation, the namespace labels and annotations and the
+// cluster profile values all match the ones it was rendered from.
+type RenderCache struct {
+	mu      sync.Mutex
+	entries map[renderCacheSlot]renderCacheEntry
+	// ignore lists namespace annotations that never affect rendering, so updating them keeps the entry valid
+	ignore map[string]bool
+}
+
+// renderCacheSlot identifies the entry of one class in one namespace
+type renderCacheSlot struct {
+	namespace types.UID
+	class     types.UID
+}
+
+type renderCacheKey struct {
+	class      types.UID
+	generation int64
+	metadata   string
+	values     string
+}
+
+type renderCacheEntry struct {
+	key       renderCacheKey
+	resources []Resource
+}
+
+// NewRenderCache returns an empty RenderCache. The operator's inventory annotations, and any ignoreAnnotations,
+// are left out of the namespace metadata hash.
+func NewRenderCache(ignoreAnnotations ...string) *RenderCache {
+	ignore := map[string]bool{InventoryAnnotation: true, InventoryVersionAnnotation: true}
+	for _, key := range ignoreAnnotations {
+		ignore[key] = true
+	}
+	return &RenderCache{entries: make(map[renderCacheSlot]renderCacheEntry), ignore: ignore}
+}
+
+// key returns the cache key for rendering nsClass into ns with the given profile, or false when the render
+// cannot be cached
+func (c *RenderCache) key(ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, profile map[string]string) (renderCacheKey, bool) {
+	if ns.UID == "" || nsClass.UID == "" {
+		return renderCacheKey{}, false
+	}
+	annotations := make(map[string]string, len(ns.Annotations))
+	for k, v := range ns.Annotations {
+		if !c.ignore[k] {
+			annotations[k] = v
+		}
+	}
+	metadata, err := hashJSON(map[string]interface{}{"name": ns.Name, "labels": ns.Labels, "annotations": annotations})
+	if err != nil {
+		return renderCacheKey{}, false
+	}
+	values, err := hashJSON(profile)
+	if err != nil {
+		return renderCacheKey{}, false
+	}
+	return renderCacheKey{class: nsClass.UID, generation: nsClass.Generation, metadata: metadata, values: values}, true
+}
+
+// lookup returns a copy of the resources cached for the render, if any. A nil cache never hits.
+func (c *RenderCache) lookup(ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, profile map[string]string) ([]Resource, bool) {
+	if c == nil {
+		return nil, false
+	}
+	key, ok := c.key(ns, nsClass, profile)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[renderCacheSlot{namespace: ns.UID, class: nsClass.UID}]
+	c.mu.Unlock()
+	if !ok || entry.key != key {
+		return nil, false
+	}
+	return copyResources(entry.resources), true
+}
+
+// store records the resources nsClass rendered for ns, replacing whatever the class had cached for the namespace
+func (c *RenderCache) store(ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass, profile map[string]string, resources []Resource) {
+	if c == nil {
+		return
+	}
+	key, ok := c.key(ns, nsClass, profile)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.entries[renderCacheSlot{namespace: ns.UID, class: nsClass.UID}] = renderCacheEntry{key: key, resources: copyResources(resources)}
+	c.mu.Unlock()
+}
+
+// Retain drops entries for namespaces that no longer exist and returns the number of cached resources
+func (c *RenderCache) Retain(live map[types.UID]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := 0
+	for slot, entry := range c.entries {
+		if !live[slot.namespace] {
+			delete(c.entries, slot)
+			continue
+		}
+		total += len(entry.resources)
+	}
+	return total
+}
+
+// copyResources deep-copies rendered resources; appliers mutate the objects they are given
+func copyResources(resources []Resource) []Resource {
+	out := make([]Resource, len(resources))
+	for i, res := range resources {
+		out[i] = res
+		out[i].Object = res.Object.DeepCopy()
+	}
+	return out
+}
+
+// hashJSON returns a hex sha256 of v's JSON encoding, which orders map keys
+func hashJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
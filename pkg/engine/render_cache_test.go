@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func testClass(name string, generation int64) *akuityv1.NamespaceClass {
+	return &akuityv1.NamespaceClass{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid"), Generation: generation}}
+}
+
+func testResources(names ...string) []Resource {
+	var out []Resource
+	for _, name := range names {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetName(name)
+		out = append(out, Resource{Object: u})
+	}
+	return out
+}
+
+func TestRenderCache(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", UID: "ns-uid", Labels: map[string]string{"tier": "gold"}}}
+	base, extra := testClass("base", 1), testClass("extra", 1)
+
+	tests := []struct {
+		name    string
+		ns      func(*corev1.Namespace)
+		class   *akuityv1.NamespaceClass
+		profile map[string]string
+		want    []string
+	}{
+		{name: "hit for unchanged namespace and class", class: base, want: []string{"base"}},
+		{name: "second class keeps its own entry", class: extra, want: []string{"extra"}},
+		{name: "miss on class generation change", class: testClass("base", 2)},
+		{name: "miss on namespace label change", class: base, ns: func(ns *corev1.Namespace) { ns.Labels["tier"] = "silver" }},
+		{
+			name:  "hit when only an ignored annotation changes",
+			class: base,
+			ns:    func(ns *corev1.Namespace) { ns.Annotations = map[string]string{InventoryAnnotation: "[]"} },
+			want:  []string{"base"},
+		},
+		{name: "miss on profile change", class: base, profile: map[string]string{"region": "eu"}},
+		{name: "miss for a namespace without UID", class: base, ns: func(ns *corev1.Namespace) { ns.UID = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewRenderCache()
+			c.store(ns, base, nil, testResources("base"))
+			c.store(ns, extra, nil, testResources("extra"))
+
+			target := ns.DeepCopy()
+			if tt.ns != nil {
+				tt.ns(target)
+			}
+			got, ok := c.lookup(target, tt.class, tt.profile)
+			if ok != (tt.want != nil) {
+				t.Fatalf("hit = %v, want %v", ok, tt.want != nil)
+			}
+			var names []string
+			for _, res := range got {
+				names = append(names, res.Object.GetName())
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("resources = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderCacheRetain(t *testing.T) {
+	c := NewRenderCache()
+	live := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "live", UID: "live-uid"}}
+	gone := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "gone", UID: "gone-uid"}}
+	c.store(live, testClass("base", 1), nil, testResources("a", "b"))
+	c.store(live, testClass("extra", 1), nil, testResources("c"))
+	c.store(gone, testClass("base", 1), nil, testResources("d"))
+
+	if got := c.Retain(map[types.UID]bool{live.UID: true}); got != 3 {
+		t.Errorf("Retain = %d, want 3", got)
+	}
+	if _, ok := c.lookup(gone, testClass("base", 1), nil); ok {
+		t.Error("entry of a deleted namespace survived Retain")
+	}
+}
+
+func TestRenderCacheCopies(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", UID: "ns-uid"}}
+	c := NewRenderCache()
+	c.store(ns, testClass("base", 1), nil, testResources("a"))
+
+	got, _ := c.lookup(ns, testClass("base", 1), nil)
+	got[0].Object.SetName("mutated")
+	again, _ := c.lookup(ns, testClass("base", 1), nil)
+	if name := again[0].Object.GetName(); name != "a" {
+		t.Errorf("cached resource name = %s after the caller mutated its copy, want a", name)
+	}
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ParseGroupKinds parses comma-separated kinds, written as Kind.group or Kind for core kinds, e.g.
+// "PersistentVolumeClaim,StatefulSet.apps"
+func ParseGroupKinds(list string) ([]schema.GroupKind, error) {
+	var kinds []schema.GroupKind
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		gk := schema.ParseGroupKind(entry)
+		if gk.Kind == "" {
+			return nil, fmt.Errorf("invalid kind %q: expected Kind or Kind.group", entry)
+		}
+		kinds = append(kinds, gk)
+	}
+	return kinds, nil
+}
+
+// retains reports whether item is of a kind the engine never deletes, see Engine.RetainKinds
+func (e *Engine) retains(item InventoryItem) bool {
+	if len(e.RetainKinds) == 0 {
+		return false
+	}
+	return slices.Contains(e.RetainKinds, schema.FromAPIVersionAndKind(item.APIVersion, item.Kind).GroupKind())
+}
+
+// Retained returns the resources in old that are not in keep but that Prune leaves in place because of their kind
+func (e *Engine) Retained(old, keep []InventoryItem) []InventoryItem {
+	keepMap := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		keepMap[k.Key()] = true
+	}
+	var retained []InventoryItem
+	for _, item := range old {
+		if !keepMap[item.Key()] && e.retains(item) {
+			retained = append(retained, item)
+		}
+	}
+	return retained
+}
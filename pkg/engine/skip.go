@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"context"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// SkipKindsAnnotation on a namespace lists comma-separated kinds it opts out of, e.g. NetworkPolicy,LimitRange.
+// Only kinds the class lists in spec.skippableKinds are honored.
+const SkipKindsAnnotation = "namespaceclass.akuity.io/skip-kinds"
+
+// skippedKinds returns the kinds ns opts out of that nsClass allows to be skipped
+func skippedKinds(ctx context.Context, ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) map[string]bool {
+	requested := ns.Annotations[SkipKindsAnnotation]
+	if requested == "" {
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, kind := range nsClass.Spec.SkippableKinds {
+		allowed[kind] = true
+	}
+	skipped := map[string]bool{}
+	var denied []string
+	for _, kind := range strings.Split(requested, ",") {
+		if kind = strings.TrimSpace(kind); kind == "" {
+			continue
+		}
+		if allowed[kind] || allowed["*"] {
+			skipped[kind] = true
+		} else {
+			denied = append(denied, kind)
+		}
+	}
+	if len(denied) > 0 {
+		log.FromContext(ctx).Info("Ignoring skipped kinds the class does not allow", "class", nsClass.Name, "kinds", denied)
+	}
+	return skipped
+}
+
+// withoutKinds drops the resources whose kind is in kinds
+func withoutKinds(resources []Resource, kinds map[string]bool) []Resource {
+	if len(kinds) == 0 {
+		return resources
+	}
+	kept := resources[:0]
+	for _, res := range resources {
+		if !kinds[res.Object.GetKind()] {
+			kept = append(kept, res)
+		}
+	}
+	return kept
+}
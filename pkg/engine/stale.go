@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ClassGenerationLabel records the class generation a resource was last applied from. Unlike
+// SourceGenerationAnnotation it can be selected on, which PruneStale uses to find leftovers of earlier rollouts.
+const ClassGenerationLabel = "namespaceclass.akuity.io/class-generation"
+
+// MergePartial adds the items of a failed, partial apply that old does not track yet, so resources created before
+// the failure are pruned like any other once the class stops rendering them
+func MergePartial(old, partial []InventoryItem) []InventoryItem {
+	seen := make(map[string]bool, len(old))
+	for _, item := range old {
+		seen[item.Key()] = true
+	}
+	merged := append([]InventoryItem(nil), old...)
+	for _, item := range partial {
+		if !seen[item.Key()] {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// PruneStale deletes resources of class in namespace that carry ClassGenerationLabel from another generation than
+// generation and are not in keep: leftovers of a rollout that failed or crashed before its inventory was written.
+// Only the kinds of the items in kinds are searched. Resources without the label, such as adopted ones, and
+// resources of RetainKinds are never touched.
+func (e *Engine) PruneStale(ctx context.Context, namespace, class string, generation int64, keep, kinds []InventoryItem) ([]InventoryItem, error) {
+	gen := strconv.FormatInt(generation, 10)
+	hasGeneration, err := labels.NewRequirement(ClassGenerationLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	otherGeneration, err := labels.NewRequirement(ClassGenerationLabel, selection.NotEquals, []string{gen})
+	if err != nil {
+		return nil, err
+	}
+	selector := labels.SelectorFromSet(labels.Set{ManagedByLabel: ManagerName, SourceClassLabel: class}).Add(*hasGeneration, *otherGeneration)
+
+	keepMap := make(map[string]bool, len(keep))
+	for _, item := range keep {
+		keepMap[item.Key()] = true
+	}
+	labeled, err := e.listLabeled(ctx, namespace, selector, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale resources: %w", err)
+	}
+	var stale []InventoryItem
+	for _, item := range labeled {
+		if !keepMap[item.Key()] && !e.retains(item) {
+			stale = append(stale, item)
+		}
+	}
+
+	var pruned []InventoryItem
+	for _, item := range pruneOrder(stale) {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(item.APIVersion)
+		u.SetKind(item.Kind)
+		u.SetName(item.Name)
+		u.SetNamespace(item.Namespace)
+		log.FromContext(ctx).Info("Pruning resource left behind by an earlier rollout", "kind", item.Kind, "name", item.Name, "generation", gen)
+		if err := e.delete(ctx, u); err != nil && !errors.IsNotFound(err) {
+			return pruned, err
+		}
+		pruned = append(pruned, item)
+	}
+	return pruned, nil
+}
+
+// listLabeled returns the resources of the kinds of the items in kinds in namespace that match selector
+func (e *Engine) listLabeled(ctx context.Context, namespace string, selector labels.Selector, kinds []InventoryItem) ([]InventoryItem, error) {
+	searched := map[schema.GroupVersionKind]bool{}
+	var found []InventoryItem
+	for _, kind := range kinds {
+		gvk := schema.FromAPIVersionAndKind(kind.APIVersion, kind.Kind)
+		if searched[gvk] {
+			continue
+		}
+		searched[gvk] = true
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := e.Client.List(ctx, list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			if meta.IsNoMatchError(err) {
+				// The API was removed; nothing of that kind is left
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s: %w", gvk.Kind, err)
+		}
+		for i := range list.Items {
+			found = append(found, ItemFor(&list.Items[i]))
+		}
+	}
+	return found, nil
+}
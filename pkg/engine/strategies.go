@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func init() {
+	RegisterStrategy(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, func(c client.Client, base Applier) Applier {
+		return &RecreateApplier{Client: c, Base: base, ImmutableFields: [][]string{{"spec", "template"}, {"spec", "selector"}}}
+	})
+	// roleRef is immutable, so a delegation switching ClusterRoles replaces its RoleBinding
+	RegisterStrategy(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"}, func(c client.Client, base Applier) Applier {
+		return &RecreateApplier{Client: c, Base: base, ImmutableFields: [][]string{{"roleRef"}}}
+	})
+	RegisterStrategy(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, func(c client.Client, base Applier) Applier {
+		return &EstablishedApplier{Client: c, Base: base}
+	})
+	RegisterStrategy(schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}, func(c client.Client, base Applier) Applier {
+		return &NoResizeApplier{Client: c, Base: base}
+	})
+}
+
+// RecreateApplier deletes and re-creates a resource when the API server rejects a change to immutable fields,
+// as it does for the pod template of a Job. Pruning deletes dependents in the background instead of orphaning them.
+type RecreateApplier struct {
+	Client client.Client
+	Base   Applier
+	// ImmutableFields are the field paths the API server refuses to change. When set, an invalid apply only recreates
+	// the resource when the rendered value of one of them differs from the live object; any other invalid apply, such
+	// as a broken template, fails and leaves the live object alone.
+	ImmutableFields [][]string
+}
+
+// Apply implements Applier
+func (a *RecreateApplier) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	outcome, err := a.Base.Apply(ctx, res)
+	if err == nil || !errors.IsInvalid(err) {
+		return outcome, err
+	}
+
+	obj := res.Object
+	if len(a.ImmutableFields) > 0 {
+		// Reuse the live object the base applier read, if it did
+		live, ok := liveObjectOf(err)
+		if !ok {
+			live = &unstructured.Unstructured{}
+			live.SetGroupVersionKind(obj.GroupVersionKind())
+			if getErr := a.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); errors.IsNotFound(getErr) {
+				live = nil
+			} else if getErr != nil {
+				return outcome, fmt.Errorf("%w; failed to get %s/%s to compare immutable fields: %w", err, obj.GetKind(), obj.GetName(), getErr)
+			}
+		}
+		if live == nil || !immutableFieldsChanged(live, obj, a.ImmutableFields) {
+			return outcome, err
+		}
+	}
+	log.FromContext(ctx).Info("Recreating resource with changed immutable fields", "kind", obj.GetKind(), "name", obj.GetName())
+	if err := a.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to delete %s/%s for recreation: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	// The old object lingers until its finalizers run; apply again on the next reconcile if it is still there
+	return a.Base.Apply(ctx, res)
+}
+
+// immutableFieldsChanged reports whether obj sets one of fields to a value the live object does not have. Only what
+// obj sets is compared, so values the API server defaulted into live do not count as changes.
+func immutableFieldsChanged(live, obj *unstructured.Unstructured, fields [][]string) bool {
+	for _, path := range fields {
+		want, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+		if !found {
+			continue
+		}
+		have, _, _ := unstructured.NestedFieldNoCopy(live.Object, path...)
+		if !subsetOf(want, have) {
+			return true
+		}
+	}
+	return false
+}
+
+// subsetOf reports whether have holds every value want sets; lists must match element by element. Quantities are
+// compared by value, as the API server normalizes them, e.g. cpu: 1000m is stored as "1".
+func subsetOf(want, have interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		h, ok := have.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range w {
+			if !subsetOf(value, h[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		h, ok := have.([]interface{})
+		if !ok || len(h) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !subsetOf(w[i], h[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		if equality.Semantic.DeepEqual(want, have) {
+			return true
+		}
+		wantQ, ok := asQuantity(want)
+		if !ok {
+			return false
+		}
+		haveQ, ok := asQuantity(have)
+		return ok && wantQ.Cmp(haveQ) == 0
+	}
+}
+
+// asQuantity parses a scalar of an unstructured object as a resource quantity
+func asQuantity(v interface{}) (resource.Quantity, bool) {
+	switch v := v.(type) {
+	case string:
+		q, err := resource.ParseQuantity(v)
+		return q, err == nil
+	case int64:
+		return *resource.NewQuantity(v, resource.DecimalSI), true
+	case float64:
+		q, err := resource.ParseQuantity(strconv.FormatFloat(v, 'f', -1, 64))
+		return q, err == nil
+	}
+	return resource.Quantity{}, false
+}
+
+// Delete implements Pruner
+func (a *RecreateApplier) Delete(ctx context.Context, obj *unstructured.Unstructured) error {
+	return a.Client.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+}
+
+// EstablishedApplier waits for an applied CustomResourceDefinition to be established and for its kind to resolve
+// through the client's RESTMapper, so resources of the new type rendered after it in the same class can be applied
+// in the same pass
+type EstablishedApplier struct {
+	Client client.Client
+	Base   Applier
+	// Timeout bounds the wait; defaults to 30s
+	Timeout time.Duration
+}
+
+// Apply implements Applier
+func (a *EstablishedApplier) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	outcome, err := a.Base.Apply(ctx, res)
+	if err != nil || outcome == OutcomeAbsent {
+		return outcome, err
+	}
+
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	obj := res.Object
+	err = wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := a.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+		for _, c := range conditions {
+			cond, ok := c.(map[string]interface{})
+			if ok && cond["type"] == "Established" && cond["status"] == "True" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("CustomResourceDefinition %s was not established: %w", obj.GetName(), err)
+	}
+
+	// The mapper reloads a group on a miss, but discovery can trail the Established condition briefly
+	gk, versions := crdKind(obj)
+	err = wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(context.Context) (bool, error) {
+		_, err := a.Client.RESTMapper().RESTMapping(gk, versions...)
+		return err == nil, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("kind %s of CustomResourceDefinition %s did not become discoverable: %w", gk, obj.GetName(), err)
+	}
+	return outcome, nil
+}
+
+// crdKind returns the group kind a CustomResourceDefinition defines and its served versions
+func crdKind(crd *unstructured.Unstructured) (schema.GroupKind, []string) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var served []string
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["served"] != true {
+			continue
+		}
+		if name, ok := version["name"].(string); ok {
+			served = append(served, name)
+		}
+	}
+	return schema.GroupKind{Group: group, Kind: kind}, served
+}
+
+// NoResizeApplier never changes the requested storage of an existing PersistentVolumeClaim. Shrinking is
+// rejected by the API server and growing depends on the storage class, so a class edit only affects new claims.
+type NoResizeApplier struct {
+	Client client.Client
+	Base   Applier
+}
+
+// Apply implements Applier
+func (a *NoResizeApplier) Apply(ctx context.Context, res Resource) (Outcome, error) {
+	obj := res.Object
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := a.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if errors.IsNotFound(err) {
+			return a.Base.Apply(ctx, res)
+		}
+		return "", fmt.Errorf("failed to get resource %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	current, found, _ := unstructured.NestedString(live.Object, "spec", "resources", "requests", "storage")
+	wanted, wantFound, _ := unstructured.NestedString(obj.Object, "spec", "resources", "requests", "storage")
+	if found && wantFound && !quantityEqual(current, wanted) {
+		log.FromContext(ctx).V(1).Info("Keeping storage request of existing claim", "name", obj.GetName(),
+			"current", current, "rendered", wanted)
+		obj = obj.DeepCopy()
+		if err := unstructured.SetNestedField(obj.Object, current, "spec", "resources", "requests", "storage"); err != nil {
+			return "", err
+		}
+		res.Object = obj
+	}
+	return a.Base.Apply(ctx, res)
+}
+
+// quantityEqual compares two quantity strings by value, falling back to string comparison if either does not parse
+func quantityEqual(a, b string) bool {
+	qa, errA := resource.ParseQuantity(a)
+	qb, errB := resource.ParseQuantity(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return qa.Cmp(qb) == 0
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// invalidApplier rejects every apply the way the API server rejects an invalid object
+type invalidApplier struct{}
+
+func (invalidApplier) Apply(_ context.Context, res Resource) (Outcome, error) {
+	return "", errors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, res.Object.GetName(),
+		field.ErrorList{field.Invalid(field.NewPath("spec", "backoffLimit"), -1, "must be greater than or equal to 0")})
+}
+
+func job(template map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"spec":       map[string]interface{}{"template": template},
+	}}
+}
+
+func podTemplate(image string, resources map[string]interface{}) map[string]interface{} {
+	container := map[string]interface{}{"name": "main", "image": image}
+	if resources != nil {
+		container["resources"] = resources
+	}
+	return map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{container}}}
+}
+
+func TestImmutableFieldsChanged(t *testing.T) {
+	jobFields := [][]string{{"spec", "template"}, {"spec", "selector"}}
+	tests := []struct {
+		name string
+		live map[string]interface{}
+		obj  map[string]interface{}
+		want bool
+	}{
+		{
+			name: "unchanged",
+			live: podTemplate("busybox:1", nil),
+			obj:  podTemplate("busybox:1", nil),
+		},
+		{
+			name: "changed image",
+			live: podTemplate("busybox:1", nil),
+			obj:  podTemplate("busybox:2", nil),
+			want: true,
+		},
+		{
+			name: "fields defaulted by the API server",
+			live: func() map[string]interface{} {
+				tpl := podTemplate("busybox:1", nil)
+				tpl["spec"].(map[string]interface{})["restartPolicy"] = "Never"
+				return tpl
+			}(),
+			obj: podTemplate("busybox:1", nil),
+		},
+		{
+			name: "quantities normalized by the API server",
+			live: podTemplate("busybox:1", map[string]interface{}{
+				"requests": map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+				"limits":   map[string]interface{}{"cpu": "2", "memory": "128Mi"},
+			}),
+			obj: podTemplate("busybox:1", map[string]interface{}{
+				"requests": map[string]interface{}{"cpu": "1000m", "memory": "1073741824"},
+				"limits":   map[string]interface{}{"cpu": int64(2), "memory": "134217728"},
+			}),
+		},
+		{
+			name: "changed quantity",
+			live: podTemplate("busybox:1", map[string]interface{}{"requests": map[string]interface{}{"cpu": "1"}}),
+			obj:  podTemplate("busybox:1", map[string]interface{}{"requests": map[string]interface{}{"cpu": "500m"}}),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := immutableFieldsChanged(job(tt.live), job(tt.obj), jobFields); got != tt.want {
+				t.Errorf("immutableFieldsChanged = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecreateApplierKeepsJobWithNormalizedQuantities(t *testing.T) {
+	ctx := context.Background()
+	tpl := podTemplate("busybox:1", map[string]interface{}{"requests": map[string]interface{}{"cpu": "1"}})
+	live := job(tpl)
+	live.SetName("migrate")
+	live.SetNamespace("team-a")
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(live).Build()
+
+	// The template is broken elsewhere; its cpu request only differs from the live one in notation
+	obj := job(podTemplate("busybox:1", map[string]interface{}{"requests": map[string]interface{}{"cpu": "1000m"}}))
+	obj.SetName("migrate")
+	obj.SetNamespace("team-a")
+	a := &RecreateApplier{Client: c, Base: invalidApplier{}, ImmutableFields: [][]string{{"spec", "template"}, {"spec", "selector"}}}
+	if _, err := a.Apply(ctx, Resource{Object: obj}); !errors.IsInvalid(err) {
+		t.Fatalf("Apply error = %v, want the Invalid error of the apply", err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "migrate"}, &batchv1.Job{}); err != nil {
+		t.Errorf("live Job: %v", err)
+	}
+}
+
+func TestRecreateApplierReportsFailedGet(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+			return errors.NewForbidden(schema.GroupResource{Group: "batch", Resource: "jobs"}, "migrate", nil)
+		},
+	}).Build()
+	obj := job(podTemplate("busybox:1", nil))
+	obj.SetName("migrate")
+	obj.SetNamespace("team-a")
+	a := &RecreateApplier{Client: c, Base: invalidApplier{}, ImmutableFields: [][]string{{"spec", "template"}}}
+	_, err := a.Apply(context.Background(), Resource{Object: obj})
+	// The apply error still classifies the failure; the get error explains why no recreate was attempted
+	if !errors.IsInvalid(err) || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Apply error = %v, want the Invalid apply and the Forbidden get", err)
+	}
+}
+
+func TestRecreateApplierReusesLiveObject(t *testing.T) {
+	ctx := context.Background()
+	gets := 0
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			gets++
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+	obj := job(podTemplate("busybox:1", nil))
+	obj.SetName("migrate")
+	obj.SetNamespace("team-a")
+	base := func(context.Context, Resource) (Outcome, error) {
+		return "", &liveObjectError{err: errors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, "migrate", nil), live: obj}
+	}
+	a := &RecreateApplier{Client: c, Base: applierFunc(base), ImmutableFields: [][]string{{"spec", "template"}}}
+	if _, err := a.Apply(ctx, Resource{Object: obj}); !errors.IsInvalid(err) {
+		t.Fatalf("Apply error = %v, want the Invalid error of the apply", err)
+	}
+	if gets != 0 {
+		t.Errorf("RecreateApplier read the live object %d times, want it to reuse the one the base applier read", gets)
+	}
+}
+
+// applierFunc adapts a function to Applier
+type applierFunc func(context.Context, Resource) (Outcome, error)
+
+func (f applierFunc) Apply(ctx context.Context, res Resource) (Outcome, error) { return f(ctx, res) }
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StuckResourcesAnnotation records, as JSON, the resources of a namespace that pruning could not remove, so the
+// wedged objects are visible without reading the operator's logs
+const StuckResourcesAnnotation = "namespaceclass.akuity.io/stuck-resources"
+
+// ReasonFinalizerPending is the StuckResource reason of an object whose deletion waits for its finalizers
+const ReasonFinalizerPending = "FinalizerPending"
+
+// StuckResource is a resource Prune could not remove, and why
+type StuckResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Reason is ReasonFinalizerPending or the failure reason of the delete, as classified by ClassifyError
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	// Since is when the object started terminating, or when deleting it first failed
+	Since metav1.Time `json:"since"`
+}
+
+// PruneError reports every resource a Prune left behind. Prune carries on past a resource it cannot remove, so
+// one wedged object does not hold up the others.
+type PruneError struct {
+	Stuck []StuckResource
+	errs  []error
+}
+
+func (e *PruneError) Error() string {
+	names := make([]string, len(e.Stuck))
+	for i, s := range e.Stuck {
+		names[i] = fmt.Sprintf("%s/%s: %s", s.Kind, s.Name, s.Message)
+	}
+	return "stuck resources: " + strings.Join(names, "; ")
+}
+
+// Unwrap returns the delete errors, so classification sees the API server's reasons
+func (e *PruneError) Unwrap() []error { return e.errs }
+
+// StuckResources returns the resources err reports as stuck, or nil if err did not come from pruning
+func StuckResources(err error) []StuckResource {
+	var pe *PruneError
+	if stderrors.As(err, &pe) {
+		return pe.Stuck
+	}
+	return nil
+}
+
+// RecordedStuckResources decodes StuckResourcesAnnotation of ns
+func RecordedStuckResources(ns *corev1.Namespace) ([]StuckResource, error) {
+	raw := ns.Annotations[StuckResourcesAnnotation]
+	if raw == "" {
+		return nil, nil
+	}
+	var stuck []StuckResource
+	if err := json.Unmarshal([]byte(raw), &stuck); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", StuckResourcesAnnotation, err)
+	}
+	return stuck, nil
+}
+
+// add records that item could not be pruned because of err
+func (e *PruneError) add(item InventoryItem, err error) {
+	e.errs = append(e.errs, err)
+	e.Stuck = append(e.Stuck, StuckResource{
+		APIVersion: item.APIVersion,
+		Kind:       item.Kind,
+		Name:       item.Name,
+		Reason:     ClassifyError(err),
+		Message:    err.Error(),
+		Since:      metav1.Now(),
+	})
+}
+
+// terminating returns the object obj names when it still exists after being deleted, i.e. finalizers hold it
+func (e *Engine) terminating(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := e.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if live.GetDeletionTimestamp() == nil || len(live.GetFinalizers()) == 0 {
+		return nil, nil
+	}
+	return live, nil
+}
+
+// addTerminating records that item is held by the finalizers of live
+func (e *PruneError) addTerminating(item InventoryItem, live *unstructured.Unstructured) {
+	err := fmt.Errorf("deletion is waiting for finalizers %s", strings.Join(live.GetFinalizers(), ", "))
+	e.errs = append(e.errs, err)
+	e.Stuck = append(e.Stuck, StuckResource{
+		APIVersion: item.APIVersion,
+		Kind:       item.Kind,
+		Name:       item.Name,
+		Reason:     ReasonFinalizerPending,
+		Message:    err.Error(),
+		Since:      *live.GetDeletionTimestamp(),
+	})
+}
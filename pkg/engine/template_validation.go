@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TemplatePolicy restricts the kinds class templates may render. The zero value only checks that templates are
+// well-formed.
+type TemplatePolicy struct {
+	// Mapper resolves whether a templated kind is namespaced; nil skips the scope check. Kinds it does not know,
+	// e.g. of a CRD installed later, pass.
+	Mapper meta.RESTMapper
+	// ForbiddenKinds may not be templated at all
+	ForbiddenKinds []schema.GroupKind
+	// Protected may not be templated; names set by Go templates or values are only checked when rendered
+	Protected []ProtectedObject
+}
+
+// ValidateTemplates rejects resource templates that would fail every render or apply, so a broken class is refused
+// when it is written instead of failing each namespace it is attached to: templates that are not a JSON object,
+// lack apiVersion or kind, set metadata.namespace, which the operator sets to the namespace it renders for, or
+// render a forbidden kind or a protected resource. Cluster-scoped kinds cannot carry the ownerReference to their
+// namespace, so only CustomResourceDefinitions and templates with omitOwnerReference may render them.
+func ValidateTemplates(nsClass *akuityv1.NamespaceClass, policy TemplatePolicy) error {
+	for i, tmpl := range nsClass.Spec.Resources {
+		if err := validateTemplate(tmpl, policy); err != nil {
+			return fmt.Errorf("resources[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateTemplate(tmpl akuityv1.ResourceTemplate, policy TemplatePolicy) error {
+	obj, ok := tmpl.Template.Object.(*unstructured.Unstructured)
+	if !ok {
+		if len(tmpl.Template.Raw) == 0 {
+			return fmt.Errorf("template is empty")
+		}
+		obj = &unstructured.Unstructured{}
+		if err := json.Unmarshal(tmpl.Template.Raw, &obj.Object); err != nil {
+			return fmt.Errorf("template is not a JSON object: %w", err)
+		}
+	}
+	if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+		return fmt.Errorf("template must set apiVersion and kind")
+	}
+	gvk := obj.GroupVersionKind()
+	if _, err := schema.ParseGroupVersion(obj.GetAPIVersion()); err != nil {
+		return fmt.Errorf("invalid apiVersion %q: %w", obj.GetAPIVersion(), err)
+	}
+	if obj.GetNamespace() != "" {
+		return fmt.Errorf("%s/%s sets metadata.namespace; resources are created in the namespace the class is attached to", gvk.Kind, obj.GetName())
+	}
+	if slices.Contains(policy.ForbiddenKinds, gvk.GroupKind()) {
+		return fmt.Errorf("%s is not allowed in class templates", gvk.GroupKind())
+	}
+	if err := checkProtected(policy.Protected, obj); err != nil {
+		return err
+	}
+	if policy.Mapper == nil || tmpl.OmitOwnerReference || isCRD(gvk) {
+		return nil
+	}
+	mapping, err := policy.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		// Unknown kinds are checked when the class is applied, once their CRD may be installed
+		return nil
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fmt.Errorf("%s/%s is cluster-scoped; set omitOwnerReference to template cluster-scoped resources", gvk.Kind, obj.GetName())
+	}
+	return nil
+}
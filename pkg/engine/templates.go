@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TemplateCache holds the decoded templates of each class generation so repeat reconciles skip JSON decoding
+type TemplateCache struct {
+	mu      sync.Mutex
+	entries map[types.UID]templateCacheEntry
+}
+
+type templateCacheEntry struct {
+	generation int64
+	objects    []*unstructured.Unstructured
+}
+
+// NewTemplateCache returns an empty TemplateCache
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{entries: make(map[types.UID]templateCacheEntry)}
+}
+
+// DecodedTemplates returns the decoded templates of nsClass, index-aligned with spec.resources.
+// Entries are shared and must be deep-copied before mutation. A nil cache decodes on every call.
+func (c *TemplateCache) DecodedTemplates(nsClass *akuityv1.NamespaceClass) ([]*unstructured.Unstructured, error) {
+	if c != nil {
+		c.mu.Lock()
+		entry, ok := c.entries[nsClass.UID]
+		c.mu.Unlock()
+		if ok && entry.generation == nsClass.Generation {
+			return entry.objects, nil
+		}
+	}
+
+	objects := make([]*unstructured.Unstructured, len(nsClass.Spec.Resources))
+	for i, tmpl := range nsClass.Spec.Resources {
+		if tmpl.Template.Object != nil {
+			// Templates built in-process carry a decoded object; only unstructured ones are supported
+			if u, ok := tmpl.Template.Object.(*unstructured.Unstructured); ok {
+				objects[i] = u
+			}
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(tmpl.Template.Raw, obj); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal resource template: %w", err)
+		}
+		objects[i] = obj
+	}
+
+	if c != nil && nsClass.UID != "" {
+		c.mu.Lock()
+		c.entries[nsClass.UID] = templateCacheEntry{generation: nsClass.Generation, objects: objects}
+		c.mu.Unlock()
+	}
+	return objects, nil
+}
+
+// Retain drops entries for classes that no longer exist and returns the number of cached templates
+func (c *TemplateCache) Retain(live map[types.UID]bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := 0
+	for uid, entry := range c.entries {
+		if !live[uid] {
+			delete(c.entries, uid)
+			continue
+		}
+		total += len(entry.objects)
+	}
+	return total
+}
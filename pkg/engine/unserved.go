@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// served reports whether the API server still serves the kind of item. Only a definite no-match counts as not
+// served; lookup failures are left to the delete to report.
+func (e *Engine) served(item InventoryItem) bool {
+	gvk := schema.FromAPIVersionAndKind(item.APIVersion, item.Kind)
+	_, err := e.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	return !meta.IsNoMatchError(err)
+}
+
+// Unserved returns the resources in old that are not in keep and whose API version is no longer served, e.g. after a
+// cluster upgrade removed policy/v1beta1. Prune drops them from the inventory without deleting anything, since the
+// API server has nothing left to delete them through.
+func (e *Engine) Unserved(old, keep []InventoryItem) []InventoryItem {
+	keepMap := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		keepMap[k.Key()] = true
+	}
+	var unserved []InventoryItem
+	for _, item := range old {
+		if !keepMap[item.Key()] && !e.served(item) {
+			unserved = append(unserved, item)
+		}
+	}
+	return unserved
+}
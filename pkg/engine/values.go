@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValueOverridePrefix prefixes the namespace annotations overriding a value of the class, e.g.
+// values.namespaceclass.akuity.io/registry
+const ValueOverridePrefix = "values.namespaceclass.akuity.io/"
+
+// valueReference matches a $(values.<key>) placeholder in a template string
+var valueReference = regexp.MustCompile(`\$\(values\.([^)]*)\)`)
+
+// ValidateValues checks that the value keys of a class can be overridden by annotation and that every placeholder in
+// its templates references a declared key
+func ValidateValues(nsClass *akuityv1.NamespaceClass) error {
+	for key := range nsClass.Spec.Values {
+		if errs := validation.IsQualifiedName(ValueOverridePrefix + key); len(errs) > 0 {
+			return fmt.Errorf("invalid values key %q: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	for i, tmpl := range nsClass.Spec.Resources {
+		for _, m := range valueReference.FindAllSubmatch(tmpl.Template.Raw, -1) {
+			if _, ok := nsClass.Spec.Values[string(m[1])]; !ok {
+				return fmt.Errorf("resources[%d] references undeclared value %q", i, m[1])
+			}
+		}
+	}
+	return nil
+}
+
+// classValues returns the values of nsClass with the overrides ns sets through ValueOverridePrefix annotations.
+// Annotations for keys the class does not declare are ignored.
+func classValues(ns *corev1.Namespace, nsClass *akuityv1.NamespaceClass) map[string]string {
+	if len(nsClass.Spec.Values) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(nsClass.Spec.Values))
+	for key, value := range nsClass.Spec.Values {
+		if override, ok := ns.Annotations[ValueOverridePrefix+key]; ok {
+			value = override
+		}
+		values[key] = value
+	}
+	return values
+}
+
+// substituteValues replaces the $(values.<key>) placeholders in every string of obj, map keys excluded
+func substituteValues(obj map[string]interface{}, values map[string]string) error {
+	var missing []string
+	walkStrings(obj, func(s string) string {
+		return valueReference.ReplaceAllStringFunc(s, func(ref string) string {
+			key := valueReference.FindStringSubmatch(ref)[1]
+			value, ok := values[key]
+			if !ok {
+				missing = append(missing, key)
+			}
+			return value
+		})
+	})
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("undeclared values referenced: %s", strings.Join(slices.Compact(missing), ", "))
+	}
+	return nil
+}
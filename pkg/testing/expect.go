@@ -0,0 +1,64 @@
+package testing
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TB is the subset of testing.TB the assertions need, so they also work with other test frameworks
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
+
+// ExpectObjects fails the test unless the sync produced exactly the given "Kind/name" objects
+func ExpectObjects(t TB, res *Result, want ...string) {
+	t.Helper()
+	got := make([]string, 0, len(res.Objects))
+	for key := range res.Objects {
+		got = append(got, key)
+	}
+	want = append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected objects [%s], got [%s]", strings.Join(want, ", "), strings.Join(got, ", "))
+	}
+}
+
+// ExpectObject fails the test unless the sync produced the "Kind/name" object, and returns it for further checks
+func ExpectObject(t TB, res *Result, key string) *unstructured.Unstructured {
+	t.Helper()
+	obj, ok := res.Objects[key]
+	if !ok {
+		t.Fatalf("expected object %s to be applied", key)
+	}
+	return obj
+}
+
+// ExpectField fails the test unless the field at path of the "Kind/name" object equals want
+func ExpectField(t TB, res *Result, key string, want any, path ...string) {
+	t.Helper()
+	obj := ExpectObject(t, res, key)
+	got, found, err := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	if err != nil || !found {
+		t.Fatalf("%s has no field %s", key, strings.Join(path, "."))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s field %s: expected %v, got %v", key, strings.Join(path, "."), want, got)
+	}
+}
+
+// ExpectPruned fails the test unless the sync removed the "Kind/name" object
+func ExpectPruned(t TB, res *Result, key string) {
+	t.Helper()
+	for _, item := range res.Pruned {
+		if item.Kind+"/"+item.Name == key {
+			return
+		}
+	}
+	t.Fatalf("expected object %s to be pruned", key)
+}
@@ -0,0 +1,20 @@
+package query
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardHTML is a single static page that renders the query API. It holds no data itself: the browser asks for
+// a bearer token and sends it with each API call, so the page is subject to the same authorization as the API.
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// serveDashboard serves the dashboard page
+func serveDashboard(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	_, _ = w.Write(dashboardHTML)
+}
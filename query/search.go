@@ -0,0 +1,78 @@
+package query
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResourceMatch is one managed resource found by SearchResources
+type ResourceMatch struct {
+	Namespace  string `json:"namespace"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Generation is the class generation the resource was last rendered from
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// ResourceSearch answers which namespaces hold resources managed by a class, e.g. to size the blast radius of a
+// template change
+type ResourceSearch struct {
+	Class string `json:"class"`
+	Kind  string `json:"kind,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Namespaces are the namespaces holding at least one match
+	Namespaces []string        `json:"namespaces"`
+	Resources  []ResourceMatch `json:"resources"`
+}
+
+// SearchResources looks up the resources class manages according to the inventories of namespaces, keeping those
+// of kind (case-insensitive) and name when set. Results are sorted by namespace, kind and name.
+func SearchResources(ctx context.Context, c client.Reader, namespaces []corev1.Namespace, class, kind, name string) ResourceSearch {
+	out := ResourceSearch{Class: class, Kind: kind, Name: name, Namespaces: []string{}, Resources: []ResourceMatch{}}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		if ns.Annotations[controllers.AttachedClassAnnotation] != class {
+			continue
+		}
+		items, err := engine.ReadInventory(ctx, c, ns)
+		if err != nil {
+			continue
+		}
+		found := false
+		for _, item := range items {
+			if (kind != "" && !strings.EqualFold(item.Kind, kind)) || (name != "" && item.Name != name) {
+				continue
+			}
+			out.Resources = append(out.Resources, ResourceMatch{
+				Namespace:  ns.Name,
+				APIVersion: item.APIVersion,
+				Kind:       item.Kind,
+				Name:       item.Name,
+				Generation: item.Generation,
+			})
+			found = true
+		}
+		if found {
+			out.Namespaces = append(out.Namespaces, ns.Name)
+		}
+	}
+	sort.Strings(out.Namespaces)
+	sort.Slice(out.Resources, func(i, j int) bool {
+		a, b := out.Resources[i], out.Resources[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return out
+}
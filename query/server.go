@@ -0,0 +1,382 @@
+// Package query serves a read-only HTTP API over the operator's sync state, answered from the informer cache so
+// portals don't need label-selector list calls against the Kubernetes API.
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"time"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/config/crd"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Class summarizes a NamespaceClass
+type Class struct {
+	Name               string                  `json:"name"`
+	Generation         int64                   `json:"generation"`
+	DeletionPolicy     akuityv1.DeletionPolicy `json:"deletionPolicy,omitempty"`
+	Resources          int                     `json:"resources"`
+	AttachedNamespaces int                     `json:"attachedNamespaces"`
+	SyncedNamespaces   int                     `json:"syncedNamespaces"`
+}
+
+// Namespace is the sync state of one namespace attached to a class
+type Namespace struct {
+	Name               string       `json:"name"`
+	Class              string       `json:"class"`
+	Synced             bool         `json:"synced"`
+	Reason             string       `json:"reason,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	Resources          int          `json:"resources"`
+	// Drifted counts inventory entries written from an older class generation
+	Drifted int `json:"drifted"`
+	// Stuck are the resources pruning could not remove, e.g. because a finalizer blocks their deletion
+	Stuck []StuckResource `json:"stuck,omitempty"`
+}
+
+// StuckResource is a resource pruning could not remove, with how long it has been stuck
+type StuckResource struct {
+	engine.StuckResource
+	Age string `json:"age"`
+}
+
+// StuckCleanup is a namespace whose cleanup has been blocked longer than the stuck cleanup threshold
+type StuckCleanup struct {
+	Namespace string `json:"namespace"`
+	Class     string `json:"class,omitempty"`
+	// Cause is prune when resources pruning could not remove block it, or finalizer when the namespace is
+	// terminating and still held by the operator's cleanup finalizer
+	Cause string      `json:"cause"`
+	Since metav1.Time `json:"since"`
+	Age   string      `json:"age"`
+	// Stuck are the resources pruning could not remove
+	Stuck []StuckResource `json:"stuck,omitempty"`
+}
+
+// NamespaceResources lists the resources the operator manages in a namespace
+type NamespaceResources struct {
+	Namespace string                      `json:"namespace"`
+	Class     string                      `json:"class,omitempty"`
+	Resources []controllers.InventoryItem `json:"resources"`
+}
+
+// Server serves GET /classes, /classes/{name}, /classes/{name}/namespaces, /classes/{name}/resources,
+// /namespaces/{name}/resources, /errors, /stuck, /schemas and /schemas/{kind}. Every request must carry a bearer token whose user is allowed to get the
+// path as a non-resource URL.
+// With Dashboard set it also serves a static page under /ui/ that renders these endpoints.
+type Server struct {
+	// Reader answers queries, typically the manager's cache
+	Reader client.Reader
+	// Client creates the TokenReviews and SubjectAccessReviews used to authenticate requests
+	Client client.Client
+	// Addr is the address to listen on, e.g. :8443
+	Addr string
+	// CertDir holds tls.crt and tls.key; without it the API is served over plain HTTP
+	CertDir string
+	// Dashboard serves the read-only status page under /ui/
+	Dashboard bool
+	// StuckCleanupThreshold is how long the cleanup of a namespace must have been blocked to be listed by /stuck
+	StuckCleanupThreshold time.Duration
+}
+
+// NeedLeaderElection lets every replica serve queries
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("query-api")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /classes", s.handle(s.listClasses))
+	mux.HandleFunc("GET /classes/{name}", s.handle(s.getClass))
+	mux.HandleFunc("GET /classes/{name}/namespaces", s.handle(s.listClassNamespaces))
+	mux.HandleFunc("GET /classes/{name}/resources", s.handle(s.searchClassResources))
+	mux.HandleFunc("GET /namespaces/{name}/resources", s.handle(s.listNamespaceResources))
+	mux.HandleFunc("GET /errors", s.handle(s.listErrors))
+	mux.HandleFunc("GET /stuck", s.handle(s.listStuck))
+	mux.HandleFunc("GET /schemas", s.handle(s.listSchemas))
+	mux.HandleFunc("GET /schemas/{kind}", s.handle(s.getSchema))
+	if s.Dashboard {
+		mux.HandleFunc("GET /ui/", serveDashboard)
+	}
+
+	srv := &http.Server{
+		Addr:              s.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Serving query API", "addr", s.Addr, "tls", s.CertDir != "")
+	var err error
+	if s.CertDir != "" {
+		err = srv.ListenAndServeTLS(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// handle authorizes the request and writes the handler's result as JSON
+func (s *Server) handle(fn func(ctx context.Context, req *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if status, err := authorize(ctx, s.Client, req); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		out, err := fn(ctx, req)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if apierrors.IsNotFound(err) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			log.FromContext(ctx).Error(err, "failed to write query response")
+		}
+	}
+}
+
+func (s *Server) listClasses(ctx context.Context, _ *http.Request) (any, error) {
+	var classList akuityv1.NamespaceClassList
+	if err := s.Reader.List(ctx, &classList); err != nil {
+		return nil, err
+	}
+	var nsList corev1.NamespaceList
+	if err := s.Reader.List(ctx, &nsList, client.HasLabels{controllers.NamespaceClassLabel}); err != nil {
+		return nil, err
+	}
+	classes := make([]Class, 0, len(classList.Items))
+	for i := range classList.Items {
+		classes = append(classes, summarizeClass(ctx, s.Reader, &classList.Items[i], nsList.Items))
+	}
+	return classes, nil
+}
+
+func (s *Server) getClass(ctx context.Context, req *http.Request) (any, error) {
+	var nsClass akuityv1.NamespaceClass
+	if err := s.Reader.Get(ctx, types.NamespacedName{Name: req.PathValue("name")}, &nsClass); err != nil {
+		return nil, err
+	}
+	var nsList corev1.NamespaceList
+	if err := s.Reader.List(ctx, &nsList, client.MatchingLabels{controllers.NamespaceClassLabel: nsClass.Name}); err != nil {
+		return nil, err
+	}
+	return summarizeClass(ctx, s.Reader, &nsClass, nsList.Items), nil
+}
+
+func (s *Server) listClassNamespaces(ctx context.Context, req *http.Request) (any, error) {
+	name := req.PathValue("name")
+	var nsClass akuityv1.NamespaceClass
+	if err := s.Reader.Get(ctx, types.NamespacedName{Name: name}, &nsClass); err != nil {
+		return nil, err
+	}
+	var nsList corev1.NamespaceList
+	if err := s.Reader.List(ctx, &nsList, client.MatchingLabels{controllers.NamespaceClassLabel: name}); err != nil {
+		return nil, err
+	}
+	namespaces := make([]Namespace, 0, len(nsList.Items))
+	for i := range nsList.Items {
+		namespaces = append(namespaces, namespaceState(ctx, s.Reader, &nsList.Items[i], nsClass.Generation))
+	}
+	return namespaces, nil
+}
+
+// searchClassResources returns the resources the class manages across namespaces, filtered by the kind and name
+// query parameters
+func (s *Server) searchClassResources(ctx context.Context, req *http.Request) (any, error) {
+	name := req.PathValue("name")
+	var nsClass akuityv1.NamespaceClass
+	if err := s.Reader.Get(ctx, types.NamespacedName{Name: name}, &nsClass); err != nil {
+		return nil, err
+	}
+	// The inventory belongs to the attached class, which a relabeled namespace may not carry in its label yet
+	var nsList corev1.NamespaceList
+	if err := s.Reader.List(ctx, &nsList); err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	return SearchResources(ctx, s.Reader, nsList.Items, name, query.Get("kind"), query.Get("name")), nil
+}
+
+// listSchemas returns the kinds and versions the operator serves schemas for
+func (s *Server) listSchemas(context.Context, *http.Request) (any, error) {
+	return crd.Schemas()
+}
+
+// getSchema returns the JSON Schema of a kind in the version query parameter, or in its storage version
+func (s *Server) getSchema(_ context.Context, req *http.Request) (any, error) {
+	kind := req.PathValue("kind")
+	found, err := crd.Lookup(kind, req.URL.Query().Get("version"))
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "schemas"}, kind)
+	}
+	return found.JSONSchema(), nil
+}
+
+// listErrors returns the namespaces whose last sync failed, most recent first
+func (s *Server) listErrors(ctx context.Context, _ *http.Request) (any, error) {
+	var nsList corev1.NamespaceList
+	if err := s.Reader.List(ctx, &nsList, client.HasLabels{controllers.NamespaceClassLabel}); err != nil {
+		return nil, err
+	}
+	failed := []Namespace{}
+	for i := range nsList.Items {
+		if state := namespaceState(ctx, s.Reader, &nsList.Items[i], 0); !state.Synced && state.Reason != "" {
+			failed = append(failed, state)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[j].LastTransitionTime.Before(failed[i].LastTransitionTime)
+	})
+	return failed, nil
+}
+
+// listStuck returns the namespaces whose cleanup has been blocked longer than StuckCleanupThreshold, longest first.
+// Terminating namespaces are included whether or not they still carry a class label.
+func (s *Server) listStuck(ctx context.Context, _ *http.Request) (any, error) {
+	var nsList corev1.NamespaceList
+	if err := s.Reader.List(ctx, &nsList); err != nil {
+		return nil, err
+	}
+	blocked := []StuckCleanup{}
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		cause, since := controllers.CleanupBlocked(ns)
+		if cause == "" || time.Since(since) < s.StuckCleanupThreshold {
+			continue
+		}
+		entry := StuckCleanup{
+			Namespace: ns.Name,
+			Class:     ns.Annotations[controllers.AttachedClassAnnotation],
+			Cause:     cause,
+			Since:     metav1.NewTime(since),
+			Age:       time.Since(since).Round(time.Second).String(),
+		}
+		if stuck, err := engine.RecordedStuckResources(ns); err == nil {
+			for _, s := range stuck {
+				entry.Stuck = append(entry.Stuck, StuckResource{StuckResource: s, Age: time.Since(s.Since.Time).Round(time.Second).String()})
+			}
+		}
+		blocked = append(blocked, entry)
+	}
+	sort.Slice(blocked, func(i, j int) bool {
+		return blocked[i].Since.Before(&blocked[j].Since)
+	})
+	return blocked, nil
+}
+
+func (s *Server) listNamespaceResources(ctx context.Context, req *http.Request) (any, error) {
+	var ns corev1.Namespace
+	if err := s.Reader.Get(ctx, types.NamespacedName{Name: req.PathValue("name")}, &ns); err != nil {
+		return nil, err
+	}
+	items, err := engine.ReadInventory(ctx, s.Reader, &ns)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []controllers.InventoryItem{}
+	}
+	return NamespaceResources{
+		Namespace: ns.Name,
+		Class:     ns.Annotations[controllers.AttachedClassAnnotation],
+		Resources: items,
+	}, nil
+}
+
+// summarizeClass counts the namespaces attached to nsClass among namespaces
+func summarizeClass(ctx context.Context, c client.Reader, nsClass *akuityv1.NamespaceClass, namespaces []corev1.Namespace) Class {
+	out := Class{
+		Name:           nsClass.Name,
+		Generation:     nsClass.Generation,
+		DeletionPolicy: nsClass.Spec.DeletionPolicy,
+		Resources:      len(nsClass.Spec.Resources),
+	}
+	for i := range namespaces {
+		if namespaces[i].Labels[controllers.NamespaceClassLabel] != nsClass.Name {
+			continue
+		}
+		out.AttachedNamespaces++
+		if namespaceState(ctx, c, &namespaces[i], nsClass.Generation).Synced {
+			out.SyncedNamespaces++
+		}
+	}
+	return out
+}
+
+// namespaceState reads the sync condition and inventory of ns; entries older than classGeneration, or than the
+// generation of the additional class they were rendered from, count as drifted
+func namespaceState(ctx context.Context, c client.Reader, ns *corev1.Namespace, classGeneration int64) Namespace {
+	out := Namespace{Name: ns.Name, Class: ns.Labels[controllers.NamespaceClassLabel]}
+	for _, cond := range ns.Status.Conditions {
+		if cond.Type != controllers.NamespaceClassSyncedCondition {
+			continue
+		}
+		out.Synced = cond.Status == corev1.ConditionTrue
+		out.Reason = cond.Reason
+		out.Message = cond.Message
+		lastTransition := cond.LastTransitionTime
+		out.LastTransitionTime = &lastTransition
+	}
+	if items, err := engine.ReadInventory(ctx, c, ns); err == nil {
+		out.Resources = len(items)
+		generations := map[string]int64{}
+		for _, item := range items {
+			generation := classGeneration
+			if item.Class != "" && classGeneration != 0 {
+				// Resources of additional classes are compared with the generation of their own class
+				if _, ok := generations[item.Class]; !ok {
+					// A class that cannot be read leaves generation zero, which counts nothing as drifted
+					var additional akuityv1.NamespaceClass
+					_ = c.Get(ctx, types.NamespacedName{Name: item.Class}, &additional)
+					generations[item.Class] = additional.Generation
+				}
+				generation = generations[item.Class]
+			}
+			if item.Generation != 0 && item.Generation < generation {
+				out.Drifted++
+			}
+		}
+	}
+	if stuck, err := engine.RecordedStuckResources(ns); err == nil {
+		for _, s := range stuck {
+			out.Stuck = append(out.Stuck, StuckResource{StuckResource: s, Age: time.Since(s.Since.Time).Round(time.Second).String()})
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/validate--v1-namespace,mutating=false,failurePolicy=ignore,sideEffects=None,groups="",resources=namespaces,verbs=create;update;delete,versions=v1,name=vnamespace.namespaceclass.akuity.io,admissionReviewVersions=v1
+
+// NamespaceValidator rejects namespaces that attach a NamespaceClass whose allowedNamespaces excludes them or that
+// are on the operator's deny list, and warns about (or denies) deleting namespaces that hold protected class
+// resources
+type NamespaceValidator struct {
+	Client client.Reader
+	// Denylist names namespaces that cannot attach any class; nil denies nothing
+	Denylist *controllers.NamespaceDenylist
+	// DenyProtectedDeletion rejects deleting a namespace with protected resources unless it carries the override
+	// annotation, instead of only warning
+	DenyProtectedDeletion bool
+}
+
+var _ admission.CustomValidator = &NamespaceValidator{}
+
+// SetupWebhookWithManager registers the namespace validator with the manager's webhook server
+func (v *NamespaceValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&corev1.Namespace{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *NamespaceValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got %T", obj)
+	}
+	if _, err := controllers.NamespaceDeletionPolicy(ns, akuityv1.DeletionPolicyCascade); err != nil {
+		return nil, err
+	}
+	return nil, v.validateClass(ctx, ns)
+}
+
+// ValidateUpdate implements admission.CustomValidator. Only label, additional class and deletion policy changes are
+// checked so
+// namespaces attached before a class was tightened can still be updated; the reconciler cleans those up.
+func (v *NamespaceValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldNs, ok := oldObj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got %T", oldObj)
+	}
+	ns, ok := newObj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got %T", newObj)
+	}
+	if oldNs.Annotations[controllers.DeletionPolicyAnnotation] != ns.Annotations[controllers.DeletionPolicyAnnotation] {
+		if _, err := controllers.NamespaceDeletionPolicy(ns, akuityv1.DeletionPolicyCascade); err != nil {
+			return nil, err
+		}
+	}
+	if maps.Equal(oldNs.Labels, ns.Labels) &&
+		oldNs.Annotations[controllers.AdditionalClassesAnnotation] == ns.Annotations[controllers.AdditionalClassesAnnotation] {
+		return nil, nil
+	}
+	return nil, v.validateClass(ctx, ns)
+}
+
+// ValidateDelete implements admission.CustomValidator. Deleting a namespace removes the resources in it whatever
+// the class says, so resources marked deletionProtection are called out before that happens.
+func (v *NamespaceValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got %T", obj)
+	}
+	protected, err := controllers.ProtectedResources(ctx, v.Client, ns)
+	if err != nil || len(protected) == 0 || controllers.NamespaceDeletionAllowed(ns) {
+		// An unreadable inventory must not block the delete
+		return nil, nil
+	}
+	names := make([]string, 0, len(protected))
+	for _, item := range protected {
+		names = append(names, item.Kind+"/"+item.Name)
+	}
+	msg := fmt.Sprintf("namespace %s contains resources protected by NamespaceClass %s: %s",
+		ns.Name, ns.Annotations[controllers.AttachedClassAnnotation], strings.Join(names, ", "))
+	if v.DenyProtectedDeletion {
+		return nil, fmt.Errorf("%s; annotate the namespace with %s=%s to delete it",
+			msg, controllers.AllowDeletionAnnotation, ns.Name)
+	}
+	return admission.Warnings{msg}, nil
+}
+
+// validateClass checks the class referenced by the namespace label, if any, and every class listed in its
+// additional classes annotation
+func (v *NamespaceValidator) validateClass(ctx context.Context, ns *corev1.Namespace) error {
+	className := ns.Labels[controllers.NamespaceClassLabel]
+	classes := engine.AdditionalClasses(ns, className)
+	if className != "" {
+		classes = append([]string{className}, classes...)
+	}
+	for _, name := range classes {
+		if err := v.validateAttachment(ctx, ns, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAttachment checks that ns may attach the class called className
+func (v *NamespaceValidator) validateAttachment(ctx context.Context, ns *corev1.Namespace, className string) error {
+	if pattern := v.Denylist.Denies(ns.Name); pattern != "" {
+		return fmt.Errorf("namespace %s matches the operator's deny pattern %s and cannot attach NamespaceClass %s", ns.Name, pattern, className)
+	}
+	var nsClass akuityv1.NamespaceClass
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: className}, &nsClass); err != nil {
+		if errors.IsNotFound(err) {
+			// A missing class is reported by the reconciler rather than blocking the namespace
+			return nil
+		}
+		return err
+	}
+	allowed, err := controllers.NamespaceAllowed(&nsClass, ns)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("namespace %s is not allowed to attach NamespaceClass %s", ns.Name, className)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	akuityv1 "github.com/lixu/namespaceclass-operator/api/v1"
+	"github.com/lixu/namespaceclass-operator/controllers"
+	"github.com/lixu/namespaceclass-operator/pkg/engine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:path=/validate-core-akuity-io-v1-namespaceclass,mutating=false,failurePolicy=ignore,sideEffects=None,groups=core.akuity.io,resources=namespaceclasses,verbs=create;update;delete,versions=v1,name=vnamespaceclass.namespaceclass.akuity.io,admissionReviewVersions=v1
+
+// NamespaceClassValidator rejects malformed resource templates, invalid delegation blocks, resources defined more
+// than once, classes failing their own spec.tests and deleting a class with deletionProtection unless deletion has
+// been confirmed
+type NamespaceClassValidator struct {
+	// KubernetesMinor is the minor version of the cluster that templates using removed APIs are checked against;
+	// zero when unknown
+	KubernetesMinor int
+	// Templates restricts the kinds templates may render
+	Templates engine.TemplatePolicy
+}
+
+var _ admission.CustomValidator = &NamespaceClassValidator{}
+
+// SetupWebhookWithManager registers the class validator and, since v1beta1 is in the manager's scheme, the /convert
+// conversion webhook between v1beta1 and the v1 hub
+func (v *NamespaceClassValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&akuityv1.NamespaceClass{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *NamespaceClassValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.deprecationWarnings(obj), v.validateSpec(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *NamespaceClassValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return v.deprecationWarnings(newObj), v.validateSpec(newObj)
+}
+
+// deprecationWarnings warns about templates using API versions Kubernetes removes, so they are fixed before an
+// upgrade breaks the class
+func (v *NamespaceClassValidator) deprecationWarnings(obj runtime.Object) admission.Warnings {
+	nsClass, ok := obj.(*akuityv1.NamespaceClass)
+	if !ok {
+		return nil
+	}
+	findings, _ := engine.DeprecatedTemplates(nsClass, v.KubernetesMinor)
+	return findings
+}
+
+// validateSpec checks the parts of a class spec that the CRD schema cannot
+func (v *NamespaceClassValidator) validateSpec(obj runtime.Object) error {
+	nsClass, ok := obj.(*akuityv1.NamespaceClass)
+	if !ok {
+		return fmt.Errorf("expected a NamespaceClass but got %T", obj)
+	}
+	return ValidateClassSpec(nsClass, v.Templates)
+}
+
+// ValidateClassSpec runs the checks the webhook applies to a class on top of its CRD schema, for tools that
+// validate classes before they reach the cluster
+func ValidateClassSpec(nsClass *akuityv1.NamespaceClass, templates engine.TemplatePolicy) error {
+	// Checked first, as the checks after it decode the templates and would fail on a malformed one without saying which
+	if err := engine.ValidateTemplates(nsClass, templates); err != nil {
+		return fmt.Errorf("invalid spec.resources: %w", err)
+	}
+	if err := engine.ValidateDelegation(nsClass.Spec.Delegation); err != nil {
+		return fmt.Errorf("invalid spec.delegation: %w", err)
+	}
+	if err := engine.ValidateResourceIdentities(nsClass); err != nil {
+		return fmt.Errorf("invalid spec.resources: %w", err)
+	}
+	if err := engine.ValidateGoTemplates(nsClass); err != nil {
+		return fmt.Errorf("invalid spec.resources: %w", err)
+	}
+	if err := engine.ValidateNamespaceMetadata(nsClass.Spec.NamespaceMetadata); err != nil {
+		return fmt.Errorf("invalid spec.namespaceMetadata: %w", err)
+	}
+	if sel := nsClass.Spec.NamespaceSelector; sel != nil {
+		// An empty selector would attach the class to every namespace of the cluster
+		if len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0 {
+			return fmt.Errorf("spec.namespaceSelector must set matchLabels or matchExpressions")
+		}
+		if _, err := metav1.LabelSelectorAsSelector(sel); err != nil {
+			return fmt.Errorf("invalid spec.namespaceSelector: %w", err)
+		}
+	}
+	if err := engine.ValidateValues(nsClass); err != nil {
+		return fmt.Errorf("invalid spec.values: %w", err)
+	}
+	if nsClass.Spec.FallbackClass == nsClass.Name {
+		return fmt.Errorf("spec.fallbackClass cannot name the class itself")
+	}
+	for i, parent := range nsClass.Spec.InheritFrom {
+		if parent == nsClass.Name {
+			return fmt.Errorf("spec.inheritFrom cannot name the class itself")
+		}
+		if slices.Contains(nsClass.Spec.InheritFrom[:i], parent) {
+			return fmt.Errorf("spec.inheritFrom lists class %s more than once", parent)
+		}
+	}
+	names := map[string]bool{}
+	for _, test := range nsClass.Spec.Tests {
+		if names[test.Name] {
+			return fmt.Errorf("invalid spec.tests: test %s is defined more than once", test.Name)
+		}
+		names[test.Name] = true
+	}
+	// Templates are rendered for the sample namespaces only, without touching the cluster, so the tests of a class
+	// inheriting from others are left to kubectl nsclass test, which can read its parents
+	if len(nsClass.Spec.InheritFrom) > 0 {
+		return nil
+	}
+	if err := engine.ClassTestsError(engine.RunClassTests(context.Background(), nsClass)); err != nil {
+		return fmt.Errorf("spec.tests: %w", err)
+	}
+	return nil
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *NamespaceClassValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	nsClass, ok := obj.(*akuityv1.NamespaceClass)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceClass but got %T", obj)
+	}
+	if !controllers.DeletionAllowed(nsClass) {
+		return nil, fmt.Errorf("NamespaceClass %s has deletionProtection enabled; set annotation %s=%s to delete it",
+			nsClass.Name, controllers.AllowDeletionAnnotation, nsClass.Name)
+	}
+	return nil, nil
+}